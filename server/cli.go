@@ -0,0 +1,376 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"Nibstash_v2_server/config"
+	"Nibstash_v2_server/database"
+	"Nibstash_v2_server/internal/model"
+	"Nibstash_v2_server/internal/repository"
+	"Nibstash_v2_server/internal/util"
+)
+
+const usage = `用法:
+  server                        启动服务器（自动执行未执行的迁移）
+  server migrate status         查看迁移状态
+  server migrate up             执行所有未执行的迁移
+  server migrate down [步数]    回滚最近的迁移（默认 1 步）
+  server migrate dry-run        在数据库副本上试运行未执行的迁移
+  server passwd <用户名> <新密码> 重置用户密码（忘记密码或被锁定时使用）
+  server rotate-key             生成新的服务器密钥并重新加密两步验证密钥等数据（请先停止服务器）
+  server hibp import <文件或目录> 导入 Have I Been Pwned 泄露密码数据集（SHA-1）
+  server hibp status            查看已导入的泄露密码数量
+  server hibp clear             删除泄露密码数据集
+  server psl refresh [网址或文件] 下载新的 Public Suffix List 并重新计算顶级域名（默认从 publicsuffix.org 下载）
+  server psl status             查看当前使用的 Public Suffix List`
+
+// runCommand 执行命令行子命令（数据库已初始化）
+func runCommand(args []string) error {
+	switch args[0] {
+	case "migrate":
+		return runMigrate(args[1:])
+	case "passwd":
+		return runPasswd(args[1:])
+	case "rotate-key":
+		return runRotateKey(args[1:])
+	case "hibp":
+		return runHIBP(args[1:])
+	case "psl":
+		return runPSL(args[1:])
+	case "help", "-h", "--help":
+		fmt.Println(usage)
+		return nil
+	default:
+		return fmt.Errorf("未知命令 %q\n%s", args[0], usage)
+	}
+}
+
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("缺少 migrate 子命令\n%s", usage)
+	}
+
+	switch args[0] {
+	case "status":
+		states, err := database.Status()
+		if err != nil {
+			return err
+		}
+		current, err := database.CurrentVersion()
+		if err != nil {
+			return err
+		}
+		latest, err := database.LatestVersion()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("数据库版本: %d，程序版本: %d\n", current, latest)
+		for _, state := range states {
+			status := "待执行"
+			switch {
+			case state.Unknown:
+				status = "未知（数据库比程序新）"
+			case state.AppliedAt != "":
+				status = "已执行于 " + state.AppliedAt
+			}
+			fmt.Printf("  %04d_%-30s %s\n", state.Version, state.Name, status)
+		}
+		return nil
+
+	case "up":
+		return database.Migrate()
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("步数必须是正整数: %s", args[1])
+			}
+			steps = n
+		}
+		return database.MigrateDown(steps)
+
+	case "dry-run":
+		pending, err := database.DryRun()
+		if err != nil {
+			return fmt.Errorf("试运行失败: %w", err)
+		}
+		if len(pending) == 0 {
+			fmt.Println("没有待执行的迁移")
+			return nil
+		}
+		fmt.Println("试运行成功，将执行以下迁移：")
+		for _, mig := range pending {
+			fmt.Printf("  %04d_%s\n", mig.Version, mig.Name)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("未知的 migrate 子命令 %q\n%s", args[0], usage)
+	}
+}
+
+// runPasswd 重置用户密码，退出该用户的所有会话并解除账号锁定
+func runPasswd(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("用法: server passwd <用户名> <新密码>")
+	}
+	username, password := args[0], args[1]
+	if len(password) < 4 {
+		return fmt.Errorf("密码至少 4 位")
+	}
+	if password == config.DefaultPassword {
+		return fmt.Errorf("不能使用默认密码")
+	}
+
+	if err := database.Migrate(); err != nil {
+		return err
+	}
+	userRepo := repository.NewUserRepository()
+	user, err := userRepo.GetByUsername(username)
+	if err != nil {
+		return fmt.Errorf("用户不存在: %s", username)
+	}
+	if err := userRepo.UpdatePassword(user.ID, password); err != nil {
+		return err
+	}
+	if _, err := repository.NewSessionRepository().DeleteAll(user.ID); err != nil {
+		return err
+	}
+	if err := repository.NewSecurityRepository().Record(model.SecurityEventPasswordChange, user.ID, username, "", "cli"); err != nil {
+		return err
+	}
+	fmt.Printf("已重置用户 %s 的密码，所有会话已退出\n", username)
+	return nil
+}
+
+// runRotateKey 生成新的服务器密钥写入配置文件，并用新密钥重新加密两步验证密钥和未设置主密码的用户的凭证
+// 旧密钥保留在配置中，无法解密的数据会逐条列出
+func runRotateKey(args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("用法: server rotate-key")
+	}
+	if err := database.Migrate(); err != nil {
+		return err
+	}
+
+	key, err := config.RandomEncryptKey()
+	if err != nil {
+		return err
+	}
+	kid := time.Now().UTC().Format("20060102150405")
+	if config.App.EncryptKeys == nil {
+		config.App.EncryptKeys = map[string]string{}
+	}
+	config.App.EncryptKeys[kid] = key
+	config.App.EncryptKeyID = kid
+
+	// 先保存配置再重新加密：重新加密失败时新旧密钥都在配置中，数据仍然可读
+	if err := config.Save(configPath); err != nil {
+		return fmt.Errorf("保存配置失败: %w", err)
+	}
+	if err := util.InitCrypto(config.App.EncryptKey, config.App.EncryptKeys, config.App.EncryptKeyID); err != nil {
+		return err
+	}
+
+	report, err := repository.NewEncryptionRepository().ReencryptServerData()
+	if err != nil {
+		return fmt.Errorf("重新加密失败（已回滚）: %w", err)
+	}
+	fmt.Printf("已生成新密钥 %s，重新加密 %d 条数据\n", report.KeyID, report.Reencrypted)
+	if len(report.Failed) > 0 {
+		fmt.Printf("以下 %d 条数据无法解密，保持原样：\n", len(report.Failed))
+		for _, f := range report.Failed {
+			fmt.Printf("  %s #%d: %s\n", f.Table, f.ID, f.Error)
+		}
+	}
+	fmt.Println("旧密钥仍保留在 config.json 中，已设置主密码的用户可在设置中轮换各自的保险库密钥")
+	return nil
+}
+
+// runHIBP 管理本地泄露密码数据集
+// 支持按哈希排序的完整文件（每行 "SHA1:次数"）和按 5 位前缀拆分的范围文件目录（文件名为前缀，每行 "后 35 位:次数"）
+func runHIBP(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("缺少 hibp 子命令\n%s", usage)
+	}
+	if err := database.Migrate(); err != nil {
+		return err
+	}
+	breachRepo := repository.NewBreachRepository()
+
+	switch args[0] {
+	case "import":
+		if len(args) != 2 {
+			return fmt.Errorf("用法: server hibp import <文件或目录>")
+		}
+		start := time.Now()
+		total, err := importHIBP(breachRepo, args[1])
+		if err != nil {
+			return fmt.Errorf("导入失败（已导入 %d 条）: %w", total, err)
+		}
+		fmt.Printf("导入完成，共 %d 条，用时 %s\n", total, time.Since(start).Round(time.Second))
+		fmt.Println("已保存的凭证会在下次生成保险库健康报告时重新检查")
+		return nil
+
+	case "status":
+		total, err := breachRepo.Stats()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("已导入 %d 条泄露密码哈希\n", total)
+		return nil
+
+	case "clear":
+		if err := breachRepo.Clear(); err != nil {
+			return err
+		}
+		fmt.Println("已删除泄露密码数据集")
+		return nil
+
+	default:
+		return fmt.Errorf("未知的 hibp 子命令 %q\n%s", args[0], usage)
+	}
+}
+
+// importHIBP 导入单个文件或范围文件目录
+func importHIBP(breachRepo *repository.BreachRepository, path string) (int, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	progress := func(n int) { fmt.Printf("已导入 %d 条...\n", n) }
+
+	if !info.IsDir() {
+		f, err := os.Open(path)
+		if err != nil {
+			return 0, err
+		}
+		defer f.Close()
+		return breachRepo.Import(f, "", progress)
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, entry := range entries {
+		prefix := strings.ToUpper(strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name())))
+		if entry.IsDir() || len(prefix) != 5 {
+			continue
+		}
+		f, err := os.Open(filepath.Join(path, entry.Name()))
+		if err != nil {
+			return total, err
+		}
+		n, err := breachRepo.Import(f, prefix, nil)
+		f.Close()
+		total += n
+		if err != nil {
+			return total, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+	}
+	return total, nil
+}
+
+// exitOnError 子命令失败时输出错误并退出
+func exitOnError(err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func runPSL(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("缺少 psl 子命令\n%s", usage)
+	}
+
+	switch args[0] {
+	case "refresh":
+		if len(args) > 2 {
+			return fmt.Errorf("用法: server psl refresh [网址或文件]")
+		}
+		source := util.PublicSuffixListURL
+		if len(args) == 2 {
+			source = args[1]
+		}
+		content, err := readPublicSuffixList(source)
+		if err != nil {
+			return fmt.Errorf("获取 Public Suffix List 失败: %w", err)
+		}
+		list, err := util.ParsePublicSuffixList(string(content))
+		if err != nil {
+			return err
+		}
+
+		// 先写临时文件再替换，避免写入中断后留下不完整的列表
+		path := config.App.PublicSuffixList()
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path+".tmp", content, 0644); err != nil {
+			return err
+		}
+		if err := os.Rename(path+".tmp", path); err != nil {
+			return err
+		}
+		fmt.Printf("已保存到 %s，共 %d 条规则\n", path, list.Len())
+
+		if err := database.Migrate(); err != nil {
+			return err
+		}
+		if err := util.LoadPublicSuffixList(path); err != nil {
+			return err
+		}
+		n, err := repository.NewDomainRepository().SyncTopDomains(true)
+		if err != nil {
+			return fmt.Errorf("重新计算顶级域名失败: %w", err)
+		}
+		bookmarks, err := repository.NewBookmarkRepository().SyncDomains(true)
+		if err != nil {
+			return fmt.Errorf("重新计算书签域名失败: %w", err)
+		}
+		fmt.Printf("已重新计算 %d 个域名和 %d 个书签的顶级域名，重启服务器后新列表生效\n", n, bookmarks)
+		return nil
+
+	case "status":
+		list := util.CurrentPublicSuffixList()
+		fmt.Printf("当前使用: %s，共 %d 条规则\n", list.Source, list.Len())
+		if info, err := os.Stat(config.App.PublicSuffixList()); err == nil {
+			fmt.Printf("更新时间: %s\n", info.ModTime().Format("2006-01-02 15:04:05"))
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("未知的 psl 子命令 %q\n%s", args[0], usage)
+	}
+}
+
+// readPublicSuffixList 从 http/https 网址下载或读取本地文件
+func readPublicSuffixList(source string) ([]byte, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return os.ReadFile(source)
+	}
+
+	client := &http.Client{Timeout: time.Minute}
+	resp, err := client.Get(source)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	// 官方列表约 250 KB，限制大小避免下载到异常内容
+	return io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+}
@@ -1,18 +1,135 @@
 package config
 
 import (
+	"crypto/rand"
+	"encoding/base64"
 	"encoding/json"
+	"log"
 	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultTrashRetentionDays 回收站默认保留天数
+const DefaultTrashRetentionDays = 30
+
+// DefaultVaultTimeoutMinutes 保险库默认自动锁定时间（分钟）
+const DefaultVaultTimeoutMinutes = 15
+
+// DefaultCredentialHistoryDepth 每个凭证默认保留的历史密码数量
+const DefaultCredentialHistoryDepth = 10
+
+// DefaultFaviconConcurrency 服务器获取网站图标的默认并发数
+const DefaultFaviconConcurrency = 4
+
+// DefaultPassword 旧版本写入配置文件的默认密码，仍在使用时拒绝启动（除非显式允许）
+const DefaultPassword = "nibstash"
+
+// 旧版本配置文件中公开的默认密钥
+const (
+	legacyJWTSecret  = "nibstash-jwt-secret-change-me-32bytes!"
+	legacyEncryptKey = "nibstash-encrypt-key-32-bytes!!!"
 )
 
 type Config struct {
 	Port       int    `json:"port"`
-	Password   string `json:"password"`
+	Password   string `json:"password,omitempty"` // 默认管理员的初始密码，为空时创建管理员时随机生成
 	JWTSecret  string `json:"jwt_secret"`
 	DBPath     string `json:"db_path"`
 	BaseURL    string `json:"base_url"`
 	AppName    string `json:"app_name"`
 	EncryptKey string `json:"encrypt_key"` // AES-GCM 加密密钥 (32字节)
+
+	// 轮换后新增的服务器密钥（密钥 ID → 32 字节密钥），旧密钥保留以便解密尚未重新加密的数据
+	EncryptKeys map[string]string `json:"encrypt_keys,omitempty"`
+	// 当前用于加密的服务器密钥 ID，为空表示使用 encrypt_key
+	EncryptKeyID string `json:"encrypt_key_id,omitempty"`
+
+	// 回收站保留天数，到期自动永久删除；未设置或为 0 时使用默认值，小于 0 表示永久保留
+	TrashRetentionDays int `json:"trash_retention_days"`
+
+	// 保险库无操作多少分钟后自动锁定；未设置或为 0 时使用默认值，小于 0 表示不自动锁定（重启后仍需解锁）
+	VaultTimeoutMinutes int `json:"vault_timeout_minutes"`
+
+	// 每个凭证保留的历史密码数量；未设置或为 0 时使用默认值，小于 0 表示不保留历史密码
+	CredentialHistoryDepth int `json:"credential_history_depth"`
+
+	// 是否允许不使用邀请码直接注册，默认关闭（只能通过管理员创建的邀请码注册）
+	AllowRegistration bool `json:"allow_registration"`
+
+	// 是否允许管理员继续使用默认密码，默认关闭（检测到默认密码时拒绝启动）
+	AllowDefaultPassword bool `json:"allow_default_password"`
+
+	// 受信任的反向代理地址（IP 或 CIDR），只有来自这些地址的 X-Forwarded-For 才用于识别客户端 IP
+	TrustedProxies []string `json:"trusted_proxies"`
+
+	// `server psl refresh` 下载的 Public Suffix List 保存位置，为空时保存在数据库所在目录；文件不存在时使用内置列表
+	PublicSuffixListPath string `json:"public_suffix_list_path,omitempty"`
+
+	// 服务器后台获取网站图标的并发数；未设置或为 0 时使用默认值，小于 0 表示不在服务器获取图标
+	FaviconConcurrency int `json:"favicon_concurrency"`
+
+	// 是否允许获取图标时访问回环和内网地址，默认关闭（避免书签被用来探测内网），内网部署的网站需要图标时开启
+	FaviconAllowPrivate bool `json:"favicon_allow_private,omitempty"`
+
+	// 书签图标地址的签名密钥，未设置时随机生成；更换后已保存的图标地址失效
+	FaviconSecret string `json:"favicon_secret"`
+}
+
+// TrashRetention 回收站保留期限，返回 0 表示永久保留
+func (c Config) TrashRetention() time.Duration {
+	days := c.TrashRetentionDays
+	if days == 0 {
+		days = DefaultTrashRetentionDays
+	}
+	if days < 0 {
+		return 0
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// VaultTimeout 保险库自动锁定时间，返回 0 表示不自动锁定
+func (c Config) VaultTimeout() time.Duration {
+	minutes := c.VaultTimeoutMinutes
+	if minutes == 0 {
+		minutes = DefaultVaultTimeoutMinutes
+	}
+	if minutes < 0 {
+		return 0
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// CredentialHistory 每个凭证保留的历史密码数量，返回 0 表示不保留
+func (c Config) CredentialHistory() int {
+	depth := c.CredentialHistoryDepth
+	if depth == 0 {
+		depth = DefaultCredentialHistoryDepth
+	}
+	if depth < 0 {
+		return 0
+	}
+	return depth
+}
+
+// FaviconWorkers 获取网站图标的并发数，返回 0 表示不在服务器获取图标
+func (c Config) FaviconWorkers() int {
+	n := c.FaviconConcurrency
+	if n == 0 {
+		n = DefaultFaviconConcurrency
+	}
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
+// PublicSuffixList 下载的 Public Suffix List 路径
+func (c Config) PublicSuffixList() string {
+	if c.PublicSuffixListPath != "" {
+		return c.PublicSuffixListPath
+	}
+	return filepath.Join(filepath.Dir(c.DBPath), "public_suffix_list.dat")
 }
 
 var App Config
@@ -20,28 +137,76 @@ var App Config
 func Load(path string) error {
 	file, err := os.Open(path)
 	if err != nil {
-		// 使用默认配置
+		// 使用默认配置，密钥随机生成；不写入初始密码，由 EnsureDefaultUser 创建管理员时随机生成并输出一次
 		App = Config{
-			Port:       8080,
-			Password:   "nibstash",
-			JWTSecret:  "nibstash-jwt-secret-change-me-32bytes!",
-			DBPath:     "data/nibstash.db",
-			BaseURL:    "http://localhost:8080",
-			AppName:    "囤囤鼠",
-			EncryptKey: "nibstash-encrypt-key-32-bytes!!!", // 32字节
+			Port:    8080,
+			DBPath:  "data/nibstash.db",
+			BaseURL: "http://localhost:8080",
+			AppName: "囤囤鼠",
+
+			TrashRetentionDays:     DefaultTrashRetentionDays,
+			VaultTimeoutMinutes:    DefaultVaultTimeoutMinutes,
+			CredentialHistoryDepth: DefaultCredentialHistoryDepth,
+			FaviconConcurrency:     DefaultFaviconConcurrency,
+		}
+		if _, err := App.fillSecrets(); err != nil {
+			return err
 		}
 		return Save(path)
 	}
-	defer file.Close()
 
-	decoder := json.NewDecoder(file)
-	err = decoder.Decode(&App)
+	err = json.NewDecoder(file).Decode(&App)
+	file.Close()
+	if err != nil {
+		return err
+	}
+
+	changed, err := App.fillSecrets()
 	if err != nil {
 		return err
 	}
+	if changed {
+		return Save(path)
+	}
 	return nil
 }
 
+// fillSecrets 随机生成配置中缺少的 JWT 密钥、服务器加密密钥和图标地址签名密钥；仍在使用旧版本公开的默认 JWT 密钥时也更换
+// （已签发的访问令牌随之失效，需要重新登录）。加密密钥已用于加密数据，不能直接更换，只提示执行 rotate-key
+func (c *Config) fillSecrets() (bool, error) {
+	changed := false
+	if c.JWTSecret == "" || c.JWTSecret == legacyJWTSecret {
+		secret, err := randomString(32)
+		if err != nil {
+			return false, err
+		}
+		if c.JWTSecret != "" {
+			log.Printf("jwt_secret 是公开的默认值，已更换为随机密钥，请重新登录")
+		}
+		c.JWTSecret = secret
+		changed = true
+	}
+	if c.EncryptKey == "" {
+		key, err := RandomEncryptKey()
+		if err != nil {
+			return false, err
+		}
+		c.EncryptKey = key
+		changed = true
+	} else if c.EncryptKey == legacyEncryptKey && c.EncryptKeyID == "" {
+		log.Printf("encrypt_key 是公开的默认值，请停止服务器后执行 `server rotate-key` 更换")
+	}
+	if c.FaviconSecret == "" {
+		secret, err := randomString(32)
+		if err != nil {
+			return false, err
+		}
+		c.FaviconSecret = secret
+		changed = true
+	}
+	return changed, nil
+}
+
 func Save(path string) error {
 	file, err := os.Create(path)
 	if err != nil {
@@ -53,3 +218,22 @@ func Save(path string) error {
 	encoder.SetIndent("", "  ")
 	return encoder.Encode(App)
 }
+
+// RandomPassword 生成随机初始密码
+func RandomPassword() (string, error) {
+	return randomString(12)
+}
+
+// RandomEncryptKey 生成 32 字节的随机服务器密钥
+func RandomEncryptKey() (string, error) {
+	return randomString(24)
+}
+
+// randomString 生成 n 字节随机数的 base64url 编码
+func randomString(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
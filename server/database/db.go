@@ -19,14 +19,14 @@ func Init(dbPath string) error {
 		return err
 	}
 
+	// 启用外键约束（通过 DSN 设置，连接池中的每个连接都会生效）
 	var err error
-	DB, err = sql.Open("sqlite", dbPath)
+	DB, err = sql.Open("sqlite", dbPath+"?_pragma=foreign_keys(1)")
 	if err != nil {
 		return err
 	}
 
-	// 启用外键约束
-	if _, err := DB.Exec("PRAGMA foreign_keys = ON"); err != nil {
+	if err := DB.Ping(); err != nil {
 		return err
 	}
 
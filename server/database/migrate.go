@@ -0,0 +1,330 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// 迁移文件命名：NNNN_名称.up.sql / NNNN_名称.down.sql，编号从 1 开始连续递增
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+var migrationFileRe = regexp.MustCompile(`^(\d{4})_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration 一个版本的迁移
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationState 迁移状态（用于 migrate status）
+type MigrationState struct {
+	Version   int
+	Name      string
+	AppliedAt string // 为空表示尚未执行
+	Unknown   bool   // 数据库中有记录但程序中没有对应文件（数据库比程序新）
+}
+
+// loadMigrations 读取内嵌的迁移文件，按版本号排序
+func loadMigrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		m := migrationFileRe.FindStringSubmatch(entry.Name())
+		if m == nil {
+			return nil, fmt.Errorf("迁移文件名不合法: %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(m[1])
+		content, err := migrationFiles.ReadFile("migrations/" + entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("迁移版本 %d 存在多个名称: %s, %s", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(content)
+		} else {
+			mig.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" || mig.Down == "" {
+			return nil, fmt.Errorf("迁移 %04d_%s 缺少 up 或 down 文件", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	for i, mig := range migrations {
+		if mig.Version != i+1 {
+			return nil, fmt.Errorf("迁移版本不连续: 缺少版本 %d", i+1)
+		}
+	}
+	return migrations, nil
+}
+
+// LatestVersion 程序内置的最新迁移版本
+func LatestVersion() (int, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return 0, err
+	}
+	return len(migrations), nil
+}
+
+// CurrentVersion 数据库当前的迁移版本（未执行过任何迁移时为 0）
+func CurrentVersion() (int, error) {
+	if !tableExists("schema_migrations") {
+		return 0, nil
+	}
+	var version int
+	err := DB.QueryRow(`SELECT IFNULL(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	return version, err
+}
+
+// ensureMigrationTable 创建迁移记录表
+// 旧版数据库（有业务表但没有迁移记录）会先经过 prepareLegacySchema 升级，然后从 0001 开始正常执行
+func ensureMigrationTable() error {
+	if tableExists("schema_migrations") {
+		return nil
+	}
+
+	if tableExists("bookmarks") {
+		if err := prepareLegacySchema(); err != nil {
+			return fmt.Errorf("升级旧版数据库失败: %w", err)
+		}
+	}
+
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	return err
+}
+
+// checkVersion 数据库版本高于程序时拒绝继续，避免旧程序写坏新结构
+func checkVersion(migrations []Migration) (int, error) {
+	current, err := CurrentVersion()
+	if err != nil {
+		return 0, err
+	}
+	if current > len(migrations) {
+		return 0, fmt.Errorf("数据库版本 %d 高于程序支持的版本 %d，请升级程序", current, len(migrations))
+	}
+	return current, nil
+}
+
+// Migrate 执行所有未执行的迁移，每个版本一个事务
+func Migrate() error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+	if err := ensureMigrationTable(); err != nil {
+		return err
+	}
+	current, err := checkVersion(migrations)
+	if err != nil {
+		return err
+	}
+
+	for _, mig := range migrations[current:] {
+		err := runInTx(func(tx *sql.Tx) error {
+			if _, err := tx.Exec(mig.Up); err != nil {
+				return err
+			}
+			_, err := tx.Exec(`INSERT INTO schema_migrations (version, name) VALUES (?, ?)`, mig.Version, mig.Name)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("执行迁移 %04d_%s 失败: %w", mig.Version, mig.Name, err)
+		}
+		log.Printf("已执行迁移 %04d_%s", mig.Version, mig.Name)
+	}
+
+	log.Printf("数据库迁移完成，当前版本 %d", len(migrations))
+	return nil
+}
+
+// MigrateDown 回滚最近的 steps 个迁移，每个版本一个事务
+func MigrateDown(steps int) error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+	current, err := checkVersion(migrations)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < steps && current > 0; i++ {
+		mig := migrations[current-1]
+		err := runInTx(func(tx *sql.Tx) error {
+			if _, err := tx.Exec(mig.Down); err != nil {
+				return err
+			}
+			_, err := tx.Exec(`DELETE FROM schema_migrations WHERE version = ?`, mig.Version)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("回滚迁移 %04d_%s 失败: %w", mig.Version, mig.Name, err)
+		}
+		log.Printf("已回滚迁移 %04d_%s", mig.Version, mig.Name)
+		current--
+	}
+	return nil
+}
+
+// DryRun 在数据库副本上试运行所有未执行的迁移（包括旧版数据库升级），返回将要执行的迁移
+// 副本位于临时目录，结束后删除，原数据库不受影响
+func DryRun() ([]Migration, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	current, err := checkVersion(migrations)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "nibstash-dry-run-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	copyPath := filepath.Join(dir, "nibstash.db")
+	if _, err := DB.Exec(`VACUUM INTO ?`, copyPath); err != nil {
+		return nil, fmt.Errorf("复制数据库失败: %w", err)
+	}
+
+	original := DB
+	defer func() { DB = original }()
+	if err := Init(copyPath); err != nil {
+		return nil, err
+	}
+	defer DB.Close()
+
+	if err := Migrate(); err != nil {
+		return nil, err
+	}
+	return migrations[current:], nil
+}
+
+// Status 列出所有迁移及其执行状态
+func Status() ([]MigrationState, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[int]MigrationState)
+	if !tableExists("schema_migrations") {
+		return mergeStates(migrations, applied), nil
+	}
+	rows, err := DB.Query(`SELECT version, name, applied_at FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var state MigrationState
+		if err := rows.Scan(&state.Version, &state.Name, &state.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied[state.Version] = state
+	}
+	return mergeStates(migrations, applied), nil
+}
+
+// mergeStates 合并程序内置的迁移和数据库中的执行记录
+func mergeStates(migrations []Migration, applied map[int]MigrationState) []MigrationState {
+	var states []MigrationState
+	for _, mig := range migrations {
+		state, ok := applied[mig.Version]
+		if !ok {
+			state = MigrationState{Version: mig.Version, Name: mig.Name}
+		}
+		delete(applied, mig.Version)
+		states = append(states, state)
+	}
+	for _, state := range applied {
+		state.Unknown = true
+		states = append(states, state)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Version < states[j].Version })
+	return states
+}
+
+// runInTx 在独占连接上执行事务
+// 迁移期间关闭外键，重建表时 DROP TABLE 才不会级联删除关联数据；提交前用 foreign_key_check 校验
+func runInTx(fn func(tx *sql.Tx) error) error {
+	ctx := context.Background()
+	conn, err := DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `PRAGMA foreign_keys = OFF`); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, `PRAGMA foreign_keys = ON`)
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	var table string
+	var rowID sql.NullInt64
+	var parent string
+	var fkid int
+	err = tx.QueryRow(`PRAGMA foreign_key_check`).Scan(&table, &rowID, &parent, &fkid)
+	if err == nil {
+		return fmt.Errorf("外键校验失败: 表 %s 第 %d 行引用的 %s 不存在", table, rowID.Int64, parent)
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// tableExists 判断表是否存在
+func tableExists(name string) bool {
+	var count int
+	DB.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, name).Scan(&count)
+	return count > 0
+}
@@ -0,0 +1,212 @@
+package database
+
+import (
+	"context"
+	"log"
+	"strings"
+)
+
+// prepareLegacySchema 升级引入版本化迁移之前创建的数据库
+// 旧版 Migrate 只会 CREATE TABLE IF NOT EXISTS，这里补齐 0001_baseline 无法用可重复执行的 SQL 表达的变化，
+// 之后再照常执行 0001_baseline。此处代码只对应旧版结构，不要随新迁移修改
+func prepareLegacySchema() error {
+	// 拼音索引列
+	if err := addColumnIfNotExists("bookmarks", "title_pinyin", "TEXT"); err != nil {
+		return err
+	}
+	if err := addColumnIfNotExists("tags", "pinyin", "TEXT"); err != nil {
+		return err
+	}
+
+	// 旧版 folder_path 字符串转换为 folders 表
+	if _, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS folders (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			parent_id INTEGER REFERENCES folders(id) ON DELETE CASCADE,
+			name TEXT NOT NULL,
+			position INTEGER DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`); err != nil {
+		return err
+	}
+	if err := migrateFolderPaths(); err != nil {
+		return err
+	}
+
+	// 旧版忽略了建索引的错误，可能残留重复书签，保留最早的一条以便创建唯一索引
+	if !indexExists("idx_bookmarks_url_folder") {
+		if _, err := DB.Exec(`
+			DELETE FROM bookmarks WHERE id NOT IN (
+				SELECT MIN(id) FROM bookmarks GROUP BY url, IFNULL(folder_id, 0)
+			)
+		`); err != nil {
+			return err
+		}
+	}
+
+	// 旧版全文索引不含拼音列，删除后由 0001_baseline 重建
+	var ftsSQL string
+	DB.QueryRow(`SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'bookmarks_fts'`).Scan(&ftsSQL)
+	if ftsSQL != "" && !strings.Contains(ftsSQL, "title_pinyin") {
+		for _, stmt := range []string{
+			`DROP TRIGGER IF EXISTS bookmarks_fts_ai`,
+			`DROP TRIGGER IF EXISTS bookmarks_fts_ad`,
+			`DROP TRIGGER IF EXISTS bookmarks_fts_au`,
+			`DROP TABLE IF EXISTS bookmarks_fts`,
+		} {
+			if _, err := DB.Exec(stmt); err != nil {
+				return err
+			}
+		}
+	}
+
+	log.Println("已升级旧版数据库结构")
+	return nil
+}
+
+// bookmarksColumns 书签表结构（与 0001_baseline 一致，仅用于重建旧表）
+const bookmarksColumns = `
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			url TEXT NOT NULL,
+			title TEXT NOT NULL,
+			description TEXT DEFAULT '',
+			folder_id INTEGER REFERENCES folders(id) ON DELETE CASCADE,
+			favicon TEXT DEFAULT '',
+			title_pinyin TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		`
+
+// 旧版用于保留空文件夹的占位书签 URL 前缀
+const folderPlaceholderPrefix = "nibstash://folder-placeholder/"
+
+// migrateFolderPaths 将旧版 bookmarks.folder_path 字符串转换为 folders 表
+// 占位书签只用来保留空文件夹，转换后直接丢弃；书签 ID 保持不变
+func migrateFolderPaths() error {
+	if !columnExists("bookmarks", "folder_path") {
+		return nil
+	}
+
+	ctx := context.Background()
+	conn, err := DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// 重建表期间必须关闭外键，否则 DROP TABLE 会级联删除 bookmark_tags
+	if _, err := conn.ExecContext(ctx, `PRAGMA foreign_keys = OFF`); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, `PRAGMA foreign_keys = ON`)
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// 1. 收集所有文件夹路径（包括占位书签保留的空文件夹）
+	rows, err := tx.Query(`SELECT DISTINCT folder_path FROM bookmarks WHERE folder_path != ''`)
+	if err != nil {
+		return err
+	}
+	var paths []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err == nil {
+			paths = append(paths, path)
+		}
+	}
+	rows.Close()
+
+	// 2. 逐级创建文件夹，记录 路径 -> ID
+	folderIDs := make(map[string]int64)
+	for _, path := range paths {
+		var parentID interface{}
+		currentPath := ""
+		for _, name := range strings.Split(path, "/") {
+			if name == "" {
+				continue
+			}
+			if currentPath != "" {
+				currentPath += "/"
+			}
+			currentPath += name
+
+			id, ok := folderIDs[currentPath]
+			if !ok {
+				result, err := tx.Exec(`INSERT INTO folders (parent_id, name) VALUES (?, ?)`, parentID, name)
+				if err != nil {
+					return err
+				}
+				if id, err = result.LastInsertId(); err != nil {
+					return err
+				}
+				folderIDs[currentPath] = id
+			}
+			parentID = id
+		}
+		folderIDs[path] = folderIDs[currentPath]
+	}
+
+	if _, err := tx.Exec(`CREATE TEMP TABLE folder_map (path TEXT PRIMARY KEY, id INTEGER)`); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, `DROP TABLE IF EXISTS temp.folder_map`)
+	for path, id := range folderIDs {
+		if _, err := tx.Exec(`INSERT INTO folder_map (path, id) VALUES (?, ?)`, path, id); err != nil {
+			return err
+		}
+	}
+
+	// 3. 重建书签表：folder_path 换成 folder_id，丢弃占位书签
+	statements := []string{
+		`CREATE TABLE bookmarks_new (` + bookmarksColumns + `)`,
+		`INSERT INTO bookmarks_new (id, url, title, description, folder_id, favicon, title_pinyin, created_at, updated_at)
+			SELECT b.id, b.url, b.title, b.description, m.id, b.favicon, b.title_pinyin, b.created_at, b.updated_at
+			FROM bookmarks b LEFT JOIN folder_map m ON m.path = b.folder_path
+			WHERE b.url NOT LIKE '` + folderPlaceholderPrefix + `%'`,
+		`DROP TABLE bookmarks`,
+		`ALTER TABLE bookmarks_new RENAME TO bookmarks`,
+		`DELETE FROM bookmark_tags WHERE bookmark_id NOT IN (SELECT id FROM bookmarks)`,
+		// 旧表上的全文索引触发器随表删除，删除后由 0001_baseline 重新创建并重建索引
+		`DROP TABLE IF EXISTS bookmarks_fts`,
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	log.Printf("已将 %d 个文件夹路径迁移到 folders 表", len(paths))
+	return nil
+}
+
+// addColumnIfNotExists 为已有表补充新列（CREATE TABLE IF NOT EXISTS 不会修改旧表）
+func addColumnIfNotExists(table, column, definition string) error {
+	if columnExists(table, column) {
+		return nil
+	}
+	_, err := DB.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + column + ` ` + definition)
+	return err
+}
+
+// columnExists 判断表中是否存在指定列
+func columnExists(table, column string) bool {
+	var count int
+	DB.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&count)
+	return count > 0
+}
+
+// indexExists 判断索引是否存在
+func indexExists(name string) bool {
+	var count int
+	DB.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = ?`, name).Scan(&count)
+	return count > 0
+}
@@ -0,0 +1,14 @@
+-- 删除全部表（数据不可恢复）
+DROP TRIGGER IF EXISTS bookmarks_fts_au;
+DROP TRIGGER IF EXISTS bookmarks_fts_ad;
+DROP TRIGGER IF EXISTS bookmarks_fts_ai;
+DROP TABLE IF EXISTS bookmarks_fts;
+DROP TABLE IF EXISTS domains;
+DROP TABLE IF EXISTS settings;
+DROP TABLE IF EXISTS credentials;
+DROP TABLE IF EXISTS bookmark_tags;
+DROP TABLE IF EXISTS tags;
+DROP TABLE IF EXISTS bookmarks;
+DROP VIEW IF EXISTS folder_paths;
+DROP TABLE IF EXISTS folders;
+DROP TABLE IF EXISTS users;
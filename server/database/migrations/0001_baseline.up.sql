@@ -0,0 +1,124 @@
+-- 基线结构：与引入版本化迁移之前的最终结构一致
+-- 旧数据库由 prepareLegacySchema 先补齐列和 folders 表，因此这里的语句都可重复执行
+
+-- 用户表
+CREATE TABLE IF NOT EXISTS users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	username TEXT NOT NULL UNIQUE DEFAULT 'admin',
+	password TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 文件夹表（parent_id 为 NULL 表示根目录下的文件夹）
+CREATE TABLE IF NOT EXISTS folders (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	parent_id INTEGER REFERENCES folders(id) ON DELETE CASCADE,
+	name TEXT NOT NULL,
+	position INTEGER DEFAULT 0,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_folders_parent_name ON folders(IFNULL(parent_id, 0), name);
+CREATE INDEX IF NOT EXISTS idx_folders_parent ON folders(parent_id);
+
+-- 文件夹完整路径视图（递归拼接，如 "工作/后台"）
+CREATE VIEW IF NOT EXISTS folder_paths AS
+WITH RECURSIVE tree(id, path) AS (
+	SELECT id, name FROM folders WHERE parent_id IS NULL
+	UNION ALL
+	SELECT f.id, tree.path || '/' || f.name FROM folders f JOIN tree ON f.parent_id = tree.id
+)
+SELECT id, path FROM tree;
+
+-- 书签表（folder_id 为 NULL 表示未分类）
+CREATE TABLE IF NOT EXISTS bookmarks (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	url TEXT NOT NULL,
+	title TEXT NOT NULL,
+	description TEXT DEFAULT '',
+	folder_id INTEGER REFERENCES folders(id) ON DELETE CASCADE,
+	favicon TEXT DEFAULT '',
+	title_pinyin TEXT,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_bookmarks_url ON bookmarks(url);
+CREATE INDEX IF NOT EXISTS idx_bookmarks_created ON bookmarks(created_at DESC);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_bookmarks_url_folder ON bookmarks(url, IFNULL(folder_id, 0));
+CREATE INDEX IF NOT EXISTS idx_bookmarks_folder ON bookmarks(folder_id);
+
+-- 标签表
+CREATE TABLE IF NOT EXISTS tags (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL UNIQUE,
+	color TEXT DEFAULT '#3b82f6',
+	pinyin TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_tags_name ON tags(name);
+
+-- 书签-标签关联表
+CREATE TABLE IF NOT EXISTS bookmark_tags (
+	bookmark_id INTEGER NOT NULL,
+	tag_id INTEGER NOT NULL,
+	PRIMARY KEY (bookmark_id, tag_id),
+	FOREIGN KEY (bookmark_id) REFERENCES bookmarks(id) ON DELETE CASCADE,
+	FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+);
+
+-- 凭证表（密码加密存储）
+CREATE TABLE IF NOT EXISTS credentials (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	domain TEXT NOT NULL,
+	title TEXT DEFAULT '',
+	username TEXT DEFAULT '',
+	password TEXT DEFAULT '',
+	notes TEXT DEFAULT '',
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_credentials_domain ON credentials(domain);
+
+-- 系统配置表
+CREATE TABLE IF NOT EXISTS settings (
+	key TEXT PRIMARY KEY,
+	value TEXT DEFAULT ''
+);
+
+-- 域名表（持久化存储域名，不随书签删除而消失）
+CREATE TABLE IF NOT EXISTS domains (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	domain TEXT NOT NULL UNIQUE,
+	top_domain TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_domains_domain ON domains(domain);
+CREATE INDEX IF NOT EXISTS idx_domains_top_domain ON domains(top_domain);
+
+-- 书签全文索引（FTS5 外部内容表 + 同步触发器）
+-- trigram 分词器使中文和英文都能按子串匹配；title_pinyin 使标题支持拼音搜索
+CREATE VIRTUAL TABLE IF NOT EXISTS bookmarks_fts USING fts5(
+	title, url, description, title_pinyin,
+	content='bookmarks',
+	content_rowid='id',
+	tokenize='trigram'
+);
+
+CREATE TRIGGER IF NOT EXISTS bookmarks_fts_ai AFTER INSERT ON bookmarks BEGIN
+	INSERT INTO bookmarks_fts(rowid, title, url, description, title_pinyin)
+	VALUES (new.id, new.title, new.url, new.description, new.title_pinyin);
+END;
+
+CREATE TRIGGER IF NOT EXISTS bookmarks_fts_ad AFTER DELETE ON bookmarks BEGIN
+	INSERT INTO bookmarks_fts(bookmarks_fts, rowid, title, url, description, title_pinyin)
+	VALUES ('delete', old.id, old.title, old.url, old.description, old.title_pinyin);
+END;
+
+CREATE TRIGGER IF NOT EXISTS bookmarks_fts_au AFTER UPDATE OF title, url, description, title_pinyin ON bookmarks BEGIN
+	INSERT INTO bookmarks_fts(bookmarks_fts, rowid, title, url, description, title_pinyin)
+	VALUES ('delete', old.id, old.title, old.url, old.description, old.title_pinyin);
+	INSERT INTO bookmarks_fts(rowid, title, url, description, title_pinyin)
+	VALUES (new.id, new.title, new.url, new.description, new.title_pinyin);
+END;
+
+-- 为已有书签建立索引（新库为空操作）
+INSERT INTO bookmarks_fts(bookmarks_fts) VALUES ('rebuild');
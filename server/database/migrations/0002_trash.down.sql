@@ -0,0 +1,28 @@
+-- 回收站中的数据会被永久删除（迁移期间外键关闭，需手动清理关联数据）
+DELETE FROM bookmarks WHERE deleted_at IS NOT NULL
+	OR folder_id IN (SELECT id FROM folders WHERE deleted_at IS NOT NULL);
+DELETE FROM bookmark_tags WHERE bookmark_id NOT IN (SELECT id FROM bookmarks);
+DELETE FROM folders WHERE deleted_at IS NOT NULL;
+DELETE FROM credentials WHERE deleted_at IS NOT NULL;
+
+DROP VIEW folder_paths;
+CREATE VIEW folder_paths AS
+WITH RECURSIVE tree(id, path) AS (
+	SELECT id, name FROM folders WHERE parent_id IS NULL
+	UNION ALL
+	SELECT f.id, tree.path || '/' || f.name FROM folders f JOIN tree ON f.parent_id = tree.id
+)
+SELECT id, path FROM tree;
+
+DROP INDEX idx_bookmarks_deleted;
+DROP INDEX idx_folders_deleted;
+DROP INDEX idx_credentials_deleted;
+
+DROP INDEX idx_bookmarks_url_folder;
+CREATE UNIQUE INDEX idx_bookmarks_url_folder ON bookmarks(url, IFNULL(folder_id, 0));
+DROP INDEX idx_folders_parent_name;
+CREATE UNIQUE INDEX idx_folders_parent_name ON folders(IFNULL(parent_id, 0), name);
+
+ALTER TABLE bookmarks DROP COLUMN deleted_at;
+ALTER TABLE folders DROP COLUMN deleted_at;
+ALTER TABLE credentials DROP COLUMN deleted_at;
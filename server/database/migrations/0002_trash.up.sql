@@ -0,0 +1,25 @@
+-- 回收站：书签、文件夹、凭证改为软删除，deleted_at 不为空表示已移入回收站
+-- 同一次删除操作中的记录使用相同的 deleted_at，恢复时据此一起恢复
+ALTER TABLE bookmarks ADD COLUMN deleted_at DATETIME;
+ALTER TABLE folders ADD COLUMN deleted_at DATETIME;
+ALTER TABLE credentials ADD COLUMN deleted_at DATETIME;
+
+-- 唯一约束只作用于未删除的记录，回收站中的书签和文件夹不妨碍重新创建
+DROP INDEX idx_bookmarks_url_folder;
+CREATE UNIQUE INDEX idx_bookmarks_url_folder ON bookmarks(url, IFNULL(folder_id, 0)) WHERE deleted_at IS NULL;
+DROP INDEX idx_folders_parent_name;
+CREATE UNIQUE INDEX idx_folders_parent_name ON folders(IFNULL(parent_id, 0), name) WHERE deleted_at IS NULL;
+
+CREATE INDEX idx_bookmarks_deleted ON bookmarks(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_folders_deleted ON folders(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_credentials_deleted ON credentials(deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- 路径视图附带删除时间，便于回收站显示原位置
+DROP VIEW folder_paths;
+CREATE VIEW folder_paths AS
+WITH RECURSIVE tree(id, path, deleted_at) AS (
+	SELECT id, name, deleted_at FROM folders WHERE parent_id IS NULL
+	UNION ALL
+	SELECT f.id, tree.path || '/' || f.name, f.deleted_at FROM folders f JOIN tree ON f.parent_id = tree.id
+)
+SELECT id, path, deleted_at FROM tree;
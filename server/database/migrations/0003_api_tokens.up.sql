@@ -0,0 +1,14 @@
+-- 个人 API Token（用于脚本和浏览器扩展）
+-- 只保存 Token 的 SHA-256 哈希，明文仅在创建时返回一次
+CREATE TABLE api_tokens (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	name TEXT NOT NULL,
+	token_hash TEXT NOT NULL UNIQUE,
+	prefix TEXT NOT NULL,
+	scopes TEXT NOT NULL DEFAULT '',
+	expires_at DATETIME,
+	last_used_at DATETIME,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_api_tokens_user ON api_tokens(user_id);
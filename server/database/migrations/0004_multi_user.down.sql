@@ -0,0 +1,68 @@
+-- 只保留管理员（最早创建的用户）的数据，其他用户及其数据被删除
+-- 迁移期间外键关闭，需手动清理关联数据
+DELETE FROM bookmark_tags WHERE bookmark_id IN (
+	SELECT id FROM bookmarks WHERE user_id != (SELECT MIN(id) FROM users)
+);
+DELETE FROM bookmarks WHERE user_id != (SELECT MIN(id) FROM users);
+DELETE FROM folders WHERE user_id != (SELECT MIN(id) FROM users);
+DELETE FROM credentials WHERE user_id != (SELECT MIN(id) FROM users);
+DELETE FROM bookmark_tags WHERE tag_id IN (
+	SELECT id FROM tags WHERE user_id != (SELECT MIN(id) FROM users)
+);
+DROP TABLE invitations;
+DELETE FROM api_tokens WHERE user_id != (SELECT MIN(id) FROM users);
+DELETE FROM users WHERE id != (SELECT MIN(id) FROM users);
+
+DROP VIEW folder_paths;
+CREATE VIEW folder_paths AS
+WITH RECURSIVE tree(id, path, deleted_at) AS (
+	SELECT id, name, deleted_at FROM folders WHERE parent_id IS NULL
+	UNION ALL
+	SELECT f.id, tree.path || '/' || f.name, f.deleted_at FROM folders f JOIN tree ON f.parent_id = tree.id
+)
+SELECT id, path, deleted_at FROM tree;
+
+CREATE TABLE settings_old (
+	key TEXT PRIMARY KEY,
+	value TEXT DEFAULT ''
+);
+INSERT INTO settings_old (key, value) SELECT key, value FROM settings WHERE user_id = (SELECT MIN(id) FROM users);
+DROP TABLE settings;
+ALTER TABLE settings_old RENAME TO settings;
+
+CREATE TABLE domains_old (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	domain TEXT NOT NULL UNIQUE,
+	top_domain TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+INSERT INTO domains_old (id, domain, top_domain, created_at)
+	SELECT id, domain, top_domain, created_at FROM domains WHERE user_id = (SELECT MIN(id) FROM users);
+DROP TABLE domains;
+ALTER TABLE domains_old RENAME TO domains;
+CREATE INDEX idx_domains_domain ON domains(domain);
+CREATE INDEX idx_domains_top_domain ON domains(top_domain);
+
+CREATE TABLE tags_old (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL UNIQUE,
+	color TEXT DEFAULT '#3b82f6',
+	pinyin TEXT
+);
+INSERT INTO tags_old (id, name, color, pinyin)
+	SELECT id, name, color, pinyin FROM tags WHERE user_id = (SELECT MIN(id) FROM users);
+DROP TABLE tags;
+ALTER TABLE tags_old RENAME TO tags;
+CREATE INDEX idx_tags_name ON tags(name);
+
+DROP INDEX idx_bookmarks_user_created;
+DROP INDEX idx_credentials_user_domain;
+DROP INDEX idx_bookmarks_url_folder;
+CREATE UNIQUE INDEX idx_bookmarks_url_folder ON bookmarks(url, IFNULL(folder_id, 0)) WHERE deleted_at IS NULL;
+DROP INDEX idx_folders_parent_name;
+CREATE UNIQUE INDEX idx_folders_parent_name ON folders(IFNULL(parent_id, 0), name) WHERE deleted_at IS NULL;
+
+ALTER TABLE folders DROP COLUMN user_id;
+ALTER TABLE bookmarks DROP COLUMN user_id;
+ALTER TABLE credentials DROP COLUMN user_id;
+ALTER TABLE users DROP COLUMN role;
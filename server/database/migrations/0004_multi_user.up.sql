@@ -0,0 +1,83 @@
+-- 多用户：所有业务表增加 user_id，唯一约束改为按用户生效
+-- 已有数据归属于最早创建的用户（原单用户模式下的 admin），该用户成为管理员
+
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'user';
+UPDATE users SET role = 'admin' WHERE id = (SELECT MIN(id) FROM users);
+
+-- 迁移期间外键关闭，默认值 0 只用于填充旧数据；之后插入时漏填 user_id 会因外键校验失败
+ALTER TABLE folders ADD COLUMN user_id INTEGER NOT NULL DEFAULT 0 REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE bookmarks ADD COLUMN user_id INTEGER NOT NULL DEFAULT 0 REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE credentials ADD COLUMN user_id INTEGER NOT NULL DEFAULT 0 REFERENCES users(id) ON DELETE CASCADE;
+UPDATE folders SET user_id = (SELECT MIN(id) FROM users);
+UPDATE bookmarks SET user_id = (SELECT MIN(id) FROM users);
+UPDATE credentials SET user_id = (SELECT MIN(id) FROM users);
+
+DROP INDEX idx_folders_parent_name;
+CREATE UNIQUE INDEX idx_folders_parent_name ON folders(user_id, IFNULL(parent_id, 0), name) WHERE deleted_at IS NULL;
+DROP INDEX idx_bookmarks_url_folder;
+CREATE UNIQUE INDEX idx_bookmarks_url_folder ON bookmarks(user_id, url, IFNULL(folder_id, 0)) WHERE deleted_at IS NULL;
+CREATE INDEX idx_bookmarks_user_created ON bookmarks(user_id, created_at DESC);
+CREATE INDEX idx_credentials_user_domain ON credentials(user_id, domain);
+
+-- 标签、域名、系统配置的唯一约束写在表定义中，需要重建表
+CREATE TABLE tags_new (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	name TEXT NOT NULL,
+	color TEXT DEFAULT '#3b82f6',
+	pinyin TEXT,
+	UNIQUE(user_id, name)
+);
+INSERT INTO tags_new (id, user_id, name, color, pinyin)
+	SELECT id, (SELECT MIN(id) FROM users), name, color, pinyin FROM tags;
+DROP TABLE tags;
+ALTER TABLE tags_new RENAME TO tags;
+CREATE INDEX idx_tags_name ON tags(name);
+
+CREATE TABLE domains_new (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	domain TEXT NOT NULL,
+	top_domain TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE(user_id, domain)
+);
+INSERT INTO domains_new (id, user_id, domain, top_domain, created_at)
+	SELECT id, (SELECT MIN(id) FROM users), domain, top_domain, created_at FROM domains;
+DROP TABLE domains;
+ALTER TABLE domains_new RENAME TO domains;
+CREATE INDEX idx_domains_domain ON domains(domain);
+CREATE INDEX idx_domains_top_domain ON domains(top_domain);
+
+CREATE TABLE settings_new (
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	key TEXT NOT NULL,
+	value TEXT DEFAULT '',
+	PRIMARY KEY (user_id, key)
+);
+INSERT INTO settings_new (user_id, key, value)
+	SELECT (SELECT MIN(id) FROM users), key, value FROM settings;
+DROP TABLE settings;
+ALTER TABLE settings_new RENAME TO settings;
+
+-- 邀请码（管理员生成，注册时使用；只保存哈希）
+CREATE TABLE invitations (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	code_hash TEXT NOT NULL UNIQUE,
+	prefix TEXT NOT NULL,
+	created_by INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	expires_at DATETIME,
+	used_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+	used_at DATETIME,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 路径视图附带所属用户
+DROP VIEW folder_paths;
+CREATE VIEW folder_paths AS
+WITH RECURSIVE tree(id, user_id, path, deleted_at) AS (
+	SELECT id, user_id, name, deleted_at FROM folders WHERE parent_id IS NULL
+	UNION ALL
+	SELECT f.id, f.user_id, tree.path || '/' || f.name, f.deleted_at FROM folders f JOIN tree ON f.parent_id = tree.id
+)
+SELECT id, user_id, path, deleted_at FROM tree;
@@ -0,0 +1,16 @@
+-- 两步验证（TOTP）
+-- totp_secret 为 AES-GCM 加密后的密钥，开启前先保存待确认的密钥（totp_enabled = 0）
+-- totp_last_step 为最近一次通过验证的时间步，同一验证码不能重复使用
+ALTER TABLE users ADD COLUMN totp_secret TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN totp_enabled INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN totp_last_step INTEGER NOT NULL DEFAULT 0;
+
+-- 恢复码：只保存 SHA-256 哈希，每个只能使用一次
+CREATE TABLE recovery_codes (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	code_hash TEXT NOT NULL,
+	used_at DATETIME,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_recovery_codes_user ON recovery_codes(user_id);
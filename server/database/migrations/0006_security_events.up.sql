@@ -0,0 +1,14 @@
+-- 安全事件（登录成功/失败、两步验证失败、账号锁定）
+-- 登录失败时用户可能不存在，user_id 为空，只记录提交的用户名
+CREATE TABLE security_events (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+	username TEXT NOT NULL DEFAULT '',
+	event TEXT NOT NULL,
+	ip TEXT NOT NULL DEFAULT '',
+	user_agent TEXT NOT NULL DEFAULT '',
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_security_events_user ON security_events(user_id, created_at);
+CREATE INDEX idx_security_events_username ON security_events(username, created_at);
+CREATE INDEX idx_security_events_ip ON security_events(ip, created_at);
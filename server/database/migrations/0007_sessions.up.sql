@@ -0,0 +1,16 @@
+-- 登录会话：每次登录创建一条，刷新 Token 只保存 SHA-256 哈希，每次刷新后更换
+-- 删除会话即退出登录，访问 Token 每次请求都校验会话是否存在
+-- step_up_at 为该会话最近一次通过两步验证的时间
+CREATE TABLE sessions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	refresh_hash TEXT NOT NULL UNIQUE,
+	user_agent TEXT NOT NULL DEFAULT '',
+	ip TEXT NOT NULL DEFAULT '',
+	step_up_at DATETIME,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	last_seen_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	expires_at DATETIME NOT NULL
+);
+CREATE INDEX idx_sessions_user ON sessions(user_id);
+CREATE INDEX idx_sessions_expires ON sessions(expires_at);
@@ -0,0 +1,2 @@
+-- 注意：已设置主密码的用户的凭证由保险库密钥加密，回滚后旧版本无法解密，请先导出凭证
+DROP TABLE vaults;
@@ -0,0 +1,13 @@
+-- 保险库：每个用户一个随机的保险库密钥，用于加密凭证密码
+-- 保险库密钥由主密码经 Argon2id 派生的密钥包装（AES-GCM）后保存，服务器不保存主密码
+-- 设置主密码时，已有凭证从服务器密钥（config.json 的 encrypt_key）重新加密为保险库密钥
+CREATE TABLE vaults (
+	user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+	kdf_salt TEXT NOT NULL,
+	kdf_time INTEGER NOT NULL,
+	kdf_memory INTEGER NOT NULL,
+	kdf_threads INTEGER NOT NULL,
+	wrapped_key TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
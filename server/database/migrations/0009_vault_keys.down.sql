@@ -0,0 +1,15 @@
+-- 只能还原用保险库当前密钥和 encrypt_key 加密的数据，用其他密钥加密的数据回滚后无法解密
+ALTER TABLE vaults ADD COLUMN wrapped_key TEXT NOT NULL DEFAULT '';
+UPDATE vaults SET wrapped_key = (
+	SELECT wrapped_key FROM vault_keys k WHERE k.user_id = vaults.user_id AND k.kid = vaults.current_kid
+);
+
+UPDATE credentials SET password = substr(password, length(
+		(SELECT 'v1:' || current_kid || ':' FROM vaults v WHERE v.user_id = credentials.user_id)
+	) + 1)
+	WHERE password LIKE (SELECT 'v1:' || current_kid || ':%' FROM vaults v WHERE v.user_id = credentials.user_id);
+UPDATE credentials SET password = substr(password, length('v1:default:') + 1) WHERE password LIKE 'v1:default:%';
+UPDATE users SET totp_secret = substr(totp_secret, length('v1:default:') + 1) WHERE totp_secret LIKE 'v1:default:%';
+
+DROP TABLE vault_keys;
+ALTER TABLE vaults DROP COLUMN current_kid;
@@ -0,0 +1,19 @@
+-- 密文改为带版本和密钥 ID 的格式：v1:<密钥 ID>:<base64>
+-- 保险库支持多个密钥：轮换后新数据使用 current_kid，旧密钥保留到不再有数据使用为止
+-- 已有的保险库密钥记为 k1，已有的保险库密文补上前缀（密文本身不变）
+CREATE TABLE vault_keys (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL REFERENCES vaults(user_id) ON DELETE CASCADE,
+	kid TEXT NOT NULL,
+	wrapped_key TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (user_id, kid)
+);
+INSERT INTO vault_keys (user_id, kid, wrapped_key, created_at)
+	SELECT user_id, 'k1', wrapped_key, created_at FROM vaults;
+
+ALTER TABLE vaults ADD COLUMN current_kid TEXT NOT NULL DEFAULT 'k1';
+ALTER TABLE vaults DROP COLUMN wrapped_key;
+
+UPDATE credentials SET password = 'v1:k1:' || password
+	WHERE password != '' AND user_id IN (SELECT user_id FROM vaults);
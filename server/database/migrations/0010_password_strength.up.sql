@@ -0,0 +1,2 @@
+-- 凭证密码强度评分（0-4），保存或导入凭证时计算；为空表示尚未评估，生成健康报告时补齐
+ALTER TABLE credentials ADD COLUMN password_strength INTEGER;
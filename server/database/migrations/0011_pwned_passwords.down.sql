@@ -0,0 +1,2 @@
+ALTER TABLE credentials DROP COLUMN breach_count;
+DROP TABLE pwned_passwords;
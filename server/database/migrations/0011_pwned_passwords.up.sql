@@ -0,0 +1,8 @@
+-- 本地导入的 Have I Been Pwned 泄露密码数据集（SHA-1 哈希和出现次数），通过 server hibp import 导入
+CREATE TABLE pwned_passwords (
+	hash BLOB PRIMARY KEY,
+	count INTEGER NOT NULL
+) WITHOUT ROWID;
+
+-- 凭证密码在泄露数据集中出现的次数：为空表示未检查（未导入数据集），0 表示未泄露
+ALTER TABLE credentials ADD COLUMN breach_count INTEGER;
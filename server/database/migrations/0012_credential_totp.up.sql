@@ -0,0 +1,2 @@
+-- 凭证的 TOTP 密钥（otpauth:// URI 或 Base32），与密码一样加密保存
+ALTER TABLE credentials ADD COLUMN totp_secret TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,4 @@
+-- 已加密的备注无法在 SQL 中解密，回滚后保持密文
+DROP TABLE credential_fields;
+ALTER TABLE credentials DROP COLUMN notes_encrypted;
+ALTER TABLE credentials DROP COLUMN type;
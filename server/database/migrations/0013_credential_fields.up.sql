@@ -0,0 +1,16 @@
+-- 凭证类型：login 为网站登录，note 为不关联域名的安全笔记（domain 为空）
+ALTER TABLE credentials ADD COLUMN type TEXT NOT NULL DEFAULT 'login';
+
+-- 备注改为用保险库密钥加密；已有的明文备注 notes_encrypted 为 0，在用户下次解锁保险库时加密
+ALTER TABLE credentials ADD COLUMN notes_encrypted INTEGER NOT NULL DEFAULT 0;
+
+-- 凭证的自定义字段，type 为 text、hidden、url、email，hidden 类型的值加密保存
+CREATE TABLE credential_fields (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	credential_id INTEGER NOT NULL REFERENCES credentials(id) ON DELETE CASCADE,
+	position INTEGER NOT NULL DEFAULT 0,
+	name TEXT NOT NULL,
+	type TEXT NOT NULL,
+	value TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX idx_credential_fields_credential ON credential_fields(credential_id, position);
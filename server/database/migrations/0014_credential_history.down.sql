@@ -0,0 +1,2 @@
+DROP TABLE credential_history;
+ALTER TABLE credentials DROP COLUMN password_changed_at;
@@ -0,0 +1,13 @@
+-- 当前密码的设置时间（修改标题、备注等不影响），已有凭证以 updated_at 为准
+ALTER TABLE credentials ADD COLUMN password_changed_at DATETIME;
+UPDATE credentials SET password_changed_at = COALESCE(updated_at, created_at, CURRENT_TIMESTAMP);
+
+-- 凭证的历史密码（与当前密码一样加密），valid_from 为设置时间，replaced_at 为被替换的时间
+CREATE TABLE credential_history (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	credential_id INTEGER NOT NULL REFERENCES credentials(id) ON DELETE CASCADE,
+	password TEXT NOT NULL,
+	valid_from DATETIME NOT NULL,
+	replaced_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_credential_history_credential ON credential_history(credential_id, id);
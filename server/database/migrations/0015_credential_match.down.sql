@@ -0,0 +1,2 @@
+ALTER TABLE credentials DROP COLUMN match_pattern;
+ALTER TABLE credentials DROP COLUMN match_rule;
@@ -0,0 +1,4 @@
+-- 凭证的网址匹配规则：domain（顶级域名相同）、host（主机名相同）、starts_with、regex、never
+-- match_pattern 为 starts_with 的网址前缀或 regex 的正则表达式
+ALTER TABLE credentials ADD COLUMN match_rule TEXT NOT NULL DEFAULT 'domain';
+ALTER TABLE credentials ADD COLUMN match_pattern TEXT NOT NULL DEFAULT '';
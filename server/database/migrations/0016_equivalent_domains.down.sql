@@ -0,0 +1,2 @@
+DROP TABLE excluded_equivalent_domains;
+DROP TABLE equivalent_domains;
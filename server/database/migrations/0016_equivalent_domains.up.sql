@@ -0,0 +1,16 @@
+-- 等价域名组：组内的顶级域名共用登录凭证（如 google.com 和 youtube.com）
+-- 用户自定义的组保存在 equivalent_domains，domains 为空格分隔的顶级域名
+CREATE TABLE equivalent_domains (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	domains TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_equivalent_domains_user ON equivalent_domains(user_id);
+
+-- 用户停用的内置等价域名组
+CREATE TABLE excluded_equivalent_domains (
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	group_key TEXT NOT NULL,
+	PRIMARY KEY (user_id, group_key)
+);
@@ -0,0 +1,2 @@
+-- 按 Public Suffix List 计算的顶级域名旧版本也能使用，无需回滚
+SELECT 1;
@@ -0,0 +1,3 @@
+-- 顶级域名改为按 Public Suffix List 计算（支持 github.io、com.au 等后缀和国际化域名）
+-- SQL 中无法计算，先清空，服务器启动时由 DomainRepository.SyncTopDomains 重新计算
+UPDATE domains SET top_domain = '';
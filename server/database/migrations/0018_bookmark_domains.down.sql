@@ -0,0 +1,4 @@
+DROP INDEX idx_bookmarks_user_top_domain;
+DROP INDEX idx_bookmarks_user_host;
+ALTER TABLE bookmarks DROP COLUMN top_domain;
+ALTER TABLE bookmarks DROP COLUMN host;
@@ -0,0 +1,6 @@
+-- 书签的主机名和顶级域名（由 ExtractDomain / GetTopDomain 计算，非 http/https 网址为空字符串）
+-- NULL 表示尚未计算，服务器启动时由 BookmarkRepository.SyncDomains 补全
+ALTER TABLE bookmarks ADD COLUMN host TEXT;
+ALTER TABLE bookmarks ADD COLUMN top_domain TEXT;
+CREATE INDEX idx_bookmarks_user_host ON bookmarks(user_id, host);
+CREATE INDEX idx_bookmarks_user_top_domain ON bookmarks(user_id, top_domain, host);
@@ -0,0 +1,5 @@
+-- 书签中指向服务器图标的地址一起清空，回滚后由网页端重新获取
+UPDATE bookmarks SET favicon = '' WHERE favicon LIKE '/api/favicons/host/%';
+DROP INDEX idx_bookmarks_host;
+DROP TABLE favicon_hosts;
+DROP TABLE favicon_blobs;
@@ -0,0 +1,24 @@
+-- 服务器获取的网站图标，内容按 SHA-256 去重，多个主机可以共用同一个图标
+CREATE TABLE favicon_blobs (
+	hash TEXT PRIMARY KEY,
+	content_type TEXT NOT NULL,
+	data BLOB NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 每个主机的图标（所有用户共用）和获取状态；blob_hash 为空表示还没有获取到
+-- 失败后按 attempts 递增的间隔重试，获取成功后定期刷新
+CREATE TABLE favicon_hosts (
+	host TEXT PRIMARY KEY,
+	blob_hash TEXT REFERENCES favicon_blobs(hash),
+	source_url TEXT NOT NULL DEFAULT '',
+	attempts INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	next_attempt_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	fetched_at DATETIME
+);
+CREATE INDEX idx_favicon_hosts_next ON favicon_hosts(next_attempt_at);
+CREATE INDEX idx_favicon_hosts_blob ON favicon_hosts(blob_hash);
+
+-- 获取到图标后按主机更新所有用户的书签
+CREATE INDEX idx_bookmarks_host ON bookmarks(host);
@@ -0,0 +1,3 @@
+-- 文件夹完整路径的拼音索引（util.PathPinyinIndex），用于书签搜索按拼音匹配文件夹
+-- NULL 表示尚未计算，服务器启动时由 FolderRepository.SyncPinyin 补全
+ALTER TABLE folders ADD COLUMN pinyin TEXT;
@@ -0,0 +1 @@
+UPDATE bookmarks SET favicon = substr(favicon, 1, instr(favicon, '?') - 1) WHERE favicon LIKE '/api/favicons/host/%?%';
@@ -0,0 +1,3 @@
+-- 服务器缓存图标的地址改为按用户签名（?u=<用户 ID>&sig=<签名>），清空旧的未签名地址，
+-- 图标获取服务下一轮运行时（ApplyCachedIcons）重新写入签名地址
+UPDATE bookmarks SET favicon = '' WHERE favicon LIKE '/api/favicons/host/%' AND favicon NOT LIKE '%?%';
@@ -0,0 +1 @@
+UPDATE folders SET pinyin = NULL;
@@ -0,0 +1,3 @@
+-- 文件夹拼音索引增加每级目录的全拼和首字母，按词的前缀匹配
+-- 清空后由服务器启动时的 FolderRepository.SyncPinyin 按新格式重新生成
+UPDATE folders SET pinyin = NULL;
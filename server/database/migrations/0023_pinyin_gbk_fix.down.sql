@@ -0,0 +1,3 @@
+UPDATE bookmarks SET title_pinyin = NULL WHERE title_pinyin <> '';
+UPDATE tags SET pinyin = NULL WHERE pinyin <> '';
+UPDATE folders SET pinyin = NULL WHERE pinyin <> '';
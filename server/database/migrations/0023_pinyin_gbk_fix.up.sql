@@ -0,0 +1,5 @@
+-- 修正 GBK 扩充汉字（多为繁体字）被误算出拼音的问题，清空含汉字的拼音索引
+-- 清空后由服务器启动时的 SyncPinyin 重新生成
+UPDATE bookmarks SET title_pinyin = NULL WHERE title_pinyin <> '';
+UPDATE tags SET pinyin = NULL WHERE pinyin <> '';
+UPDATE folders SET pinyin = NULL WHERE pinyin <> '';
@@ -0,0 +1,304 @@
+package favicon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/idna"
+)
+
+// 网页和图标的大小上限
+const (
+	maxPageSize = 1 << 20
+	maxIconSize = 512 << 10
+)
+
+// userAgent 部分网站拒绝没有 User-Agent 的请求
+const userAgent = "Mozilla/5.0 (compatible; Nibstash favicon fetcher)"
+
+var (
+	ErrNoIcon         = errors.New("没有找到可用的图标")
+	ErrPrivateAddress = errors.New("不允许访问内网地址")
+)
+
+// Icon 获取到的图标
+type Icon struct {
+	URL         string
+	ContentType string
+	Data        []byte
+}
+
+// Fetcher 获取主机的网站图标：依次尝试首页中 <link rel="icon"> 指定的图标、/favicon.ico 和 apple-touch-icon
+type Fetcher struct {
+	client *http.Client
+}
+
+// NewFetcher client 为空时使用 NewClient(false)；测试时可以传入连接本地替身服务器的 client
+func NewFetcher(client *http.Client) *Fetcher {
+	if client == nil {
+		client = NewClient(false)
+	}
+	return &Fetcher{client: client}
+}
+
+// NewClient 获取图标使用的 HTTP 客户端，allowPrivate 为 false 时拒绝连接 deniedPrefixes 中的地址
+// （在建立连接时检查解析后的地址，重定向和 DNS 重绑定也无法绕过）
+func NewClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil || !publicIP(addrPort.Addr()) {
+				return ErrPrivateAddress
+			}
+			return nil
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Transport: transport,
+		Timeout:   30 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("重定向次数过多")
+			}
+			return nil
+		},
+	}
+}
+
+// deniedPrefixes 不允许连接的地址段：本机、内网、运营商级 NAT、保留和文档地址、组播，
+// 以及可以内嵌 IPv4 地址的 NAT64、6to4 和 Teredo 地址段
+var deniedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("169.254.0.0/16"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("192.88.99.0/24"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("224.0.0.0/4"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+
+	netip.MustParsePrefix("::/96"), // 未指定地址、回环地址和已废弃的 IPv4 兼容地址
+	netip.MustParsePrefix("::ffff:0:0/96"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("100::/64"),
+	netip.MustParsePrefix("2001::/32"),
+	netip.MustParsePrefix("2001:db8::/32"),
+	netip.MustParsePrefix("2002::/16"),
+	netip.MustParsePrefix("fc00::/7"),
+	netip.MustParsePrefix("fe80::/10"),
+	netip.MustParsePrefix("fec0::/10"),
+	netip.MustParsePrefix("ff00::/8"),
+}
+
+// publicIP 判断是否允许连接该地址；IPv4 映射地址（::ffff:a.b.c.d）按其中的 IPv4 地址判断
+func publicIP(addr netip.Addr) bool {
+	// 带区域的地址（fe80::1%eth0）不会被任何地址段包含，先去掉区域
+	addr = addr.Unmap().WithZone("")
+	if !addr.IsValid() {
+		return false
+	}
+	for _, prefix := range deniedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// Fetch 获取主机的图标，先用 https 访问首页，连接失败时改用 http
+func (f *Fetcher) Fetch(ctx context.Context, host string) (*Icon, error) {
+	base, links, err := f.fetchPage(ctx, "https://"+hostPort(host)+"/")
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if base, links, err = f.fetchPage(ctx, "http://"+hostPort(host)+"/"); err != nil {
+			return nil, err
+		}
+	}
+
+	var lastErr error = ErrNoIcon
+	for _, candidate := range links.candidates(base) {
+		icon, err := f.fetchIcon(ctx, candidate)
+		if err == nil {
+			return icon, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// hostPort 网址中的主机部分（可以带端口）：IPv6 地址加方括号，国际化域名转换为 punycode
+func hostPort(host string) string {
+	name, port, err := net.SplitHostPort(host)
+	if err != nil {
+		// 没有端口，或者是不带方括号的 IPv6 地址
+		name, port = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]"), ""
+	}
+	if net.ParseIP(name) == nil {
+		if ascii, err := idna.Lookup.ToASCII(name); err == nil {
+			name = ascii
+		}
+	}
+	if port != "" {
+		return net.JoinHostPort(name, port)
+	}
+	if strings.Contains(name, ":") {
+		return "[" + name + "]"
+	}
+	return name
+}
+
+// iconLinks 首页 <head> 中声明的图标
+type iconLinks struct {
+	icons      []string // rel 含 icon（包括 shortcut icon）
+	touchIcons []string // apple-touch-icon、apple-touch-icon-precomposed
+}
+
+// candidates 按优先级排列的图标地址（去重），相对地址按网页的最终地址（或 <base href>）解析
+func (l iconLinks) candidates(base *url.URL) []string {
+	hrefs := append(append(append([]string{}, l.icons...), "/favicon.ico"), l.touchIcons...)
+	hrefs = append(hrefs, "/apple-touch-icon.png")
+
+	seen := make(map[string]bool)
+	var urls []string
+	for _, href := range hrefs {
+		u, err := base.Parse(strings.TrimSpace(href))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || seen[u.String()] {
+			continue
+		}
+		// SVG 图标可能包含脚本，不保存
+		if strings.HasSuffix(strings.ToLower(u.Path), ".svg") {
+			continue
+		}
+		seen[u.String()] = true
+		urls = append(urls, u.String())
+	}
+	return urls
+}
+
+// fetchPage 读取首页的图标声明，返回重定向后的最终地址（有 <base href> 时为其地址）；
+// 只有无法连接时返回错误，首页不是 200 时没有图标声明，仍可尝试默认位置的图标
+func (f *Fetcher) fetchPage(ctx context.Context, pageURL string) (*url.URL, iconLinks, error) {
+	resp, err := f.get(ctx, pageURL)
+	if err != nil {
+		return nil, iconLinks{}, err
+	}
+	defer resp.Body.Close()
+
+	base := resp.Request.URL
+	if resp.StatusCode != http.StatusOK {
+		return base, iconLinks{}, nil
+	}
+
+	var links iconLinks
+	tokenizer := html.NewTokenizer(io.LimitReader(resp.Body, maxPageSize))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return base, links, nil
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tokenizer.TagName()
+			switch string(name) {
+			case "body":
+				return base, links, nil
+			case "base":
+				if href := attrs(tokenizer, hasAttr)["href"]; href != "" {
+					if u, err := base.Parse(href); err == nil {
+						base = u
+					}
+				}
+			case "link":
+				a := attrs(tokenizer, hasAttr)
+				if a["href"] == "" || strings.Contains(a["type"], "svg") {
+					continue
+				}
+				rels := strings.Fields(strings.ToLower(a["rel"]))
+				for _, rel := range rels {
+					if rel == "apple-touch-icon" || rel == "apple-touch-icon-precomposed" {
+						links.touchIcons = append(links.touchIcons, a["href"])
+						break
+					}
+					if rel == "icon" {
+						links.icons = append(links.icons, a["href"])
+						break
+					}
+				}
+			}
+		case html.EndTagToken:
+			if name, _ := tokenizer.TagName(); string(name) == "head" {
+				return base, links, nil
+			}
+		}
+	}
+}
+
+func attrs(tokenizer *html.Tokenizer, hasAttr bool) map[string]string {
+	a := make(map[string]string)
+	for hasAttr {
+		var key, val []byte
+		key, val, hasAttr = tokenizer.TagAttr()
+		a[string(key)] = string(val)
+	}
+	return a
+}
+
+// fetchIcon 下载图标，只接受按内容识别为图片的响应（不接受 SVG）
+func (f *Fetcher) fetchIcon(ctx context.Context, iconURL string) (*Icon, error) {
+	resp, err := f.get(ctx, iconURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: HTTP %d", iconURL, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxIconSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxIconSize {
+		return nil, fmt.Errorf("%s: 图标超过 %d KB", iconURL, maxIconSize>>10)
+	}
+	contentType := http.DetectContentType(data)
+	if len(data) == 0 || !strings.HasPrefix(contentType, "image/") {
+		return nil, fmt.Errorf("%s: 不是图片", iconURL)
+	}
+	return &Icon{URL: resp.Request.URL.String(), ContentType: contentType, Data: data}, nil
+}
+
+func (f *Fetcher) get(ctx context.Context, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	return f.client.Do(req)
+}
@@ -0,0 +1,273 @@
+package favicon
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// 能被 http.DetectContentType 识别的最小图片内容
+var (
+	pngIcon   = []byte("\x89PNG\r\n\x1a\n" + "png icon")
+	icoIcon   = []byte("\x00\x00\x01\x00" + "ico icon")
+	touchIcon = []byte("\x89PNG\r\n\x1a\n" + "touch icon")
+)
+
+// site 本地替身网站：paths 为路径到响应内容的映射，没有的路径返回 404
+type site map[string]string
+
+func (s site) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, ok := s[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Write([]byte(body))
+}
+
+// standIn 启动替身服务器，返回连接到它的 client：无论请求哪个主机都连接替身服务器（https 握手失败后改用 http），
+// handlers 按请求的主机名分发，没有对应主机时返回 404
+func standIn(t *testing.T, handlers map[string]http.Handler) *http.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h, ok := handlers[r.Host]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		h.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	transport := srv.Client().Transport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, srv.Listener.Addr().String())
+	}
+	return &http.Client{Transport: transport}
+}
+
+func TestFetch(t *testing.T) {
+	tests := []struct {
+		name    string
+		site    site
+		wantURL string
+		want    []byte
+	}{
+		{
+			name: "link rel icon",
+			site: site{
+				"/":                `<html><head><link rel="shortcut icon" href="/static/icon.png"></head><body></body></html>`,
+				"/static/icon.png": string(pngIcon),
+				"/favicon.ico":     string(icoIcon),
+			},
+			wantURL: "http://example.com/static/icon.png",
+			want:    pngIcon,
+		},
+		{
+			name: "base href and svg skipped",
+			site: site{
+				"/":                `<head><base href="/assets/"><link rel="icon" type="image/svg+xml" href="icon.svg"><link rel="icon" href="icon.png"></head>`,
+				"/assets/icon.svg": `<svg xmlns="http://www.w3.org/2000/svg"></svg>`,
+				"/assets/icon.png": string(pngIcon),
+			},
+			wantURL: "http://example.com/assets/icon.png",
+			want:    pngIcon,
+		},
+		{
+			name: "favicon.ico fallback",
+			site: site{
+				"/":            `<html><head><title>no icon</title></head></html>`,
+				"/favicon.ico": string(icoIcon),
+			},
+			wantURL: "http://example.com/favicon.ico",
+			want:    icoIcon,
+		},
+		{
+			name: "favicon.ico when home page is missing",
+			site: site{
+				"/favicon.ico": string(icoIcon),
+			},
+			wantURL: "http://example.com/favicon.ico",
+			want:    icoIcon,
+		},
+		{
+			name: "broken icon link falls back",
+			site: site{
+				"/":              `<link rel="icon" href="/missing.png"><link rel="icon" href="/not-image.png">`,
+				"/not-image.png": "<html>not an image</html>",
+				"/favicon.ico":   string(icoIcon),
+			},
+			wantURL: "http://example.com/favicon.ico",
+			want:    icoIcon,
+		},
+		{
+			name: "apple-touch-icon",
+			site: site{
+				"/":          `<head><link rel="apple-touch-icon" sizes="180x180" href="/touch.png"></head>`,
+				"/touch.png": string(touchIcon),
+			},
+			wantURL: "http://example.com/touch.png",
+			want:    touchIcon,
+		},
+		{
+			name: "default apple-touch-icon",
+			site: site{
+				"/apple-touch-icon.png": string(touchIcon),
+			},
+			wantURL: "http://example.com/apple-touch-icon.png",
+			want:    touchIcon,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetcher := NewFetcher(standIn(t, map[string]http.Handler{"example.com": tt.site}))
+			icon, err := fetcher.Fetch(context.Background(), "example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if icon.URL != tt.wantURL || string(icon.Data) != string(tt.want) {
+				t.Errorf("Fetch = %s %q, want %s %q", icon.URL, icon.Data, tt.wantURL, tt.want)
+			}
+			if !strings.HasPrefix(icon.ContentType, "image/") {
+				t.Errorf("content type = %s, want image/*", icon.ContentType)
+			}
+		})
+	}
+}
+
+func TestFetchNoIcon(t *testing.T) {
+	fetcher := NewFetcher(standIn(t, map[string]http.Handler{
+		"example.com": site{"/": `<link rel="icon" href="/icon.svg">`, "/icon.svg": "<svg></svg>"},
+	}))
+	if _, err := fetcher.Fetch(context.Background(), "example.com"); err == nil {
+		t.Error("Fetch succeeded, want error")
+	}
+}
+
+func TestFetchHostWithPort(t *testing.T) {
+	srv := httptest.NewServer(site{"/favicon.ico": string(icoIcon)})
+	defer srv.Close()
+
+	host := srv.Listener.Addr().String()
+	icon, err := NewFetcher(NewClient(true)).Fetch(context.Background(), host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "http://" + host + "/favicon.ico"; icon.URL != want {
+		t.Errorf("icon URL = %s, want %s", icon.URL, want)
+	}
+}
+
+func TestFetchRejectsPrivateAddress(t *testing.T) {
+	var requested atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested.Store(true)
+		w.Write(icoIcon)
+	}))
+	defer srv.Close()
+
+	_, err := NewFetcher(nil).Fetch(context.Background(), srv.Listener.Addr().String())
+	if !errors.Is(err, ErrPrivateAddress) {
+		t.Errorf("Fetch error = %v, want %v", err, ErrPrivateAddress)
+	}
+	if requested.Load() {
+		t.Error("private address was requested")
+	}
+}
+
+// deniedAddresses 各个不允许连接的地址段中的地址
+var deniedAddresses = []string{
+	"127.0.0.1",
+	"10.0.0.1",
+	"172.16.5.4",
+	"192.168.1.1",
+	"169.254.169.254",
+	"0.0.0.0",
+	"0.1.2.3",
+	"100.64.0.1",
+	"100.127.255.254",
+	"192.0.0.8",
+	"192.0.2.1",
+	"198.18.0.1",
+	"198.19.255.255",
+	"224.0.0.1",
+	"255.255.255.255",
+	"::",
+	"::1",
+	"::127.0.0.1",
+	"::ffff:127.0.0.1",
+	"::ffff:10.0.0.1",
+	"::ffff:100.64.0.1",
+	"::ffff:169.254.169.254",
+	"64:ff9b::7f00:1",
+	"64:ff9b::a9fe:a9fe",
+	"2001:0:4136:e378:8000:63bf:3fff:fdd2",
+	"2002:7f00:1::1",
+	"fc00::1",
+	"fd12:3456::1",
+	"fe80::1",
+	"fe80::1%eth0",
+	"ff02::1",
+}
+
+func TestPublicIP(t *testing.T) {
+	for _, ip := range []string{"93.184.216.34", "100.128.0.1", "198.20.0.1", "2606:2800:220:1:248:1893:25c8:1946", "::ffff:93.184.216.34"} {
+		if !publicIP(netip.MustParseAddr(ip)) {
+			t.Errorf("publicIP(%s) = false, want true", ip)
+		}
+	}
+	for _, ip := range deniedAddresses {
+		if publicIP(netip.MustParseAddr(ip)) {
+			t.Errorf("publicIP(%s) = true, want false", ip)
+		}
+	}
+}
+
+// TestDialerRejectsDeniedAddresses 直接用客户端的拨号函数连接，在建立连接前拒绝
+func TestDialerRejectsDeniedAddresses(t *testing.T) {
+	dial := NewClient(false).Transport.(*http.Transport).DialContext
+	for _, ip := range deniedAddresses {
+		conn, err := dial(context.Background(), "tcp", net.JoinHostPort(ip, "80"))
+		if err == nil {
+			conn.Close()
+		}
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "socket" {
+			t.Logf("%s: %v", ip, err)
+			continue // 当前环境不支持该协议
+		}
+		if !errors.Is(err, ErrPrivateAddress) {
+			t.Errorf("dial %s error = %v, want %v", ip, err, ErrPrivateAddress)
+		}
+	}
+}
+
+func TestHostPort(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"example.com", "example.com"},
+		{"example.com:8080", "example.com:8080"},
+		{"127.0.0.1", "127.0.0.1"},
+		{"127.0.0.1:8080", "127.0.0.1:8080"},
+		{"::1", "[::1]"},
+		{"[::1]", "[::1]"},
+		{"[::1]:8080", "[::1]:8080"},
+		{"2001:db8::1", "[2001:db8::1]"},
+		{"例子.中国", "xn--fsqu00a.xn--fiqs8s"},
+		{"例子.中国:8443", "xn--fsqu00a.xn--fiqs8s:8443"},
+	}
+	for _, tt := range tests {
+		if got := hostPort(tt.host); got != tt.want {
+			t.Errorf("hostPort(%q) = %q, want %q", tt.host, got, tt.want)
+		}
+	}
+}
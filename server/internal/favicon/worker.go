@@ -0,0 +1,142 @@
+package favicon
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"Nibstash_v2_server/internal/model"
+	"Nibstash_v2_server/internal/repository"
+)
+
+const (
+	// 获取成功后多久重新获取一次
+	refreshInterval = 30 * 24 * time.Hour
+	// 第一次失败后的重试间隔，之后每次失败乘以 3，最长 maxRetryInterval
+	retryInterval    = 5 * time.Minute
+	maxRetryInterval = 7 * 24 * time.Hour
+	// 单个主机（包括首页和全部候选图标）的获取时限
+	hostTimeout = time.Minute
+	// 每批从队列中取出的主机数
+	batchSize = 100
+	// 队列为空时多久检查一次新书签
+	pollInterval = 30 * time.Second
+)
+
+// Worker 后台获取书签主机的图标，最多同时获取 concurrency 个主机
+type Worker struct {
+	fetcher     *Fetcher
+	repo        *repository.FaviconRepository
+	concurrency int
+
+	// 并发获取，逐个写入结果：SQLite 的写事务并发时会返回 SQLITE_BUSY
+	saveMu sync.Mutex
+}
+
+func NewWorker(fetcher *Fetcher, concurrency int) *Worker {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &Worker{
+		fetcher:     fetcher,
+		repo:        repository.NewFaviconRepository(),
+		concurrency: concurrency,
+	}
+}
+
+// Run 持续处理队列直到 ctx 取消
+func (w *Worker) Run(ctx context.Context) {
+	for {
+		if _, err := w.RunOnce(ctx); err != nil {
+			log.Printf("获取网站图标失败: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// RunOnce 把新书签的主机加入队列，处理所有已到期的主机，返回处理的主机数
+func (w *Worker) RunOnce(ctx context.Context) (int, error) {
+	if _, err := w.repo.EnqueueHosts(); err != nil {
+		return 0, err
+	}
+	if _, err := w.repo.ApplyCachedIcons(); err != nil {
+		return 0, err
+	}
+
+	processed := 0
+	seen := make(map[string]bool)
+	for ctx.Err() == nil {
+		due, err := w.repo.DueHosts(batchSize)
+		if err != nil {
+			return processed, err
+		}
+		// 结果没能保存的主机仍然到期，本轮不再重复获取
+		var hosts []model.FaviconHost
+		for _, host := range due {
+			if !seen[host.Host] {
+				seen[host.Host] = true
+				hosts = append(hosts, host)
+			}
+		}
+		if len(hosts) == 0 {
+			break
+		}
+
+		sem := make(chan struct{}, w.concurrency)
+		var wg sync.WaitGroup
+		for _, host := range hosts {
+			if ctx.Err() != nil {
+				break
+			}
+			sem <- struct{}{}
+			wg.Add(1)
+			go func(host model.FaviconHost) {
+				defer func() { <-sem; wg.Done() }()
+				w.process(ctx, host)
+			}(host)
+			processed++
+		}
+		wg.Wait()
+	}
+	return processed, nil
+}
+
+// process 获取一个主机的图标并记录结果；失败时保留已有的图标，按失败次数延后重试
+func (w *Worker) process(ctx context.Context, host model.FaviconHost) {
+	fetchCtx, cancel := context.WithTimeout(ctx, hostTimeout)
+	defer cancel()
+
+	icon, err := w.fetcher.Fetch(fetchCtx, host.Host)
+	if ctx.Err() != nil {
+		// 服务器正在关闭，不计入失败次数
+		return
+	}
+	w.saveMu.Lock()
+	defer w.saveMu.Unlock()
+	if err != nil {
+		if err := w.repo.RecordFailure(host.Host, err.Error(), retryDelay(host.Attempts)); err != nil {
+			log.Printf("记录 %s 的图标获取失败: %v", host.Host, err)
+		}
+		return
+	}
+	if err := w.repo.SaveIcon(host.Host, icon.URL, icon.ContentType, icon.Data, refreshInterval); err != nil {
+		log.Printf("保存 %s 的图标失败: %v", host.Host, err)
+	}
+}
+
+// retryDelay 已连续失败 attempts 次后下一次的重试间隔
+func retryDelay(attempts int) time.Duration {
+	delay := retryInterval
+	for i := 0; i < attempts && delay < maxRetryInterval; i++ {
+		delay *= 3
+	}
+	if delay > maxRetryInterval {
+		delay = maxRetryInterval
+	}
+	return delay
+}
@@ -0,0 +1,227 @@
+package favicon
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"Nibstash_v2_server/config"
+	"Nibstash_v2_server/database"
+	"Nibstash_v2_server/internal/repository"
+)
+
+// setupDB 在临时目录中创建已执行全部迁移的数据库
+func setupDB(t *testing.T) {
+	t.Helper()
+	if err := database.Init(filepath.Join(t.TempDir(), "nibstash.db")); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(database.Close)
+	if err := database.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	config.App.FaviconSecret = "test-secret"
+}
+
+func createUser(t *testing.T, username string) int64 {
+	t.Helper()
+	user, err := repository.NewUserRepository().Register(username, "password123", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user.ID
+}
+
+func createBookmark(t *testing.T, userID int64, url string) int64 {
+	t.Helper()
+	bookmark, err := repository.NewBookmarkRepository().Create(userID, url, url, "", "", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bookmark.ID
+}
+
+func bookmarkFavicon(t *testing.T, id int64) string {
+	t.Helper()
+	var favicon string
+	if err := database.DB.QueryRow(`SELECT favicon FROM bookmarks WHERE id = ?`, id).Scan(&favicon); err != nil {
+		t.Fatal(err)
+	}
+	return favicon
+}
+
+func runOnce(t *testing.T, w *Worker, want int) {
+	t.Helper()
+	n, err := w.RunOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != want {
+		t.Errorf("RunOnce processed %d hosts, want %d", n, want)
+	}
+}
+
+func TestWorkerDedupesBlobs(t *testing.T) {
+	setupDB(t)
+	alice, bob := createUser(t, "alice"), createUser(t, "bob")
+	aliceA := createBookmark(t, alice, "https://a.example/")
+	aliceB := createBookmark(t, alice, "https://b.example/x")
+	bobB := createBookmark(t, bob, "https://b.example/y")
+	bobC := createBookmark(t, bob, "https://c.example/")
+
+	// a.example 和 b.example 的图标内容相同
+	w := NewWorker(NewFetcher(standIn(t, map[string]http.Handler{
+		"a.example": site{"/favicon.ico": string(icoIcon)},
+		"b.example": site{"/": `<link rel="icon" href="/same.ico">`, "/same.ico": string(icoIcon)},
+		"c.example": site{"/favicon.ico": string(pngIcon)},
+	})), 2)
+	runOnce(t, w, 3)
+
+	var blobs int
+	database.DB.QueryRow(`SELECT COUNT(*) FROM favicon_blobs`).Scan(&blobs)
+	if blobs != 2 {
+		t.Errorf("favicon_blobs has %d rows, want 2", blobs)
+	}
+	var hashA, hashB string
+	database.DB.QueryRow(`SELECT blob_hash FROM favicon_hosts WHERE host = 'a.example'`).Scan(&hashA)
+	database.DB.QueryRow(`SELECT blob_hash FROM favicon_hosts WHERE host = 'b.example'`).Scan(&hashB)
+	if hashA == "" || hashA != hashB {
+		t.Errorf("a.example and b.example use blobs %q and %q, want the same blob", hashA, hashB)
+	}
+
+	// 书签的图标地址按用户签名
+	for id, want := range map[int64]string{
+		aliceA: repository.FaviconURL(alice, "a.example"),
+		aliceB: repository.FaviconURL(alice, "b.example"),
+		bobB:   repository.FaviconURL(bob, "b.example"),
+		bobC:   repository.FaviconURL(bob, "c.example"),
+	} {
+		if got := bookmarkFavicon(t, id); got != want {
+			t.Errorf("bookmark %d favicon = %q, want %q", id, got, want)
+		}
+	}
+	if repository.FaviconURL(alice, "b.example") == repository.FaviconURL(bob, "b.example") {
+		t.Error("favicon URLs of different users are the same")
+	}
+
+	// 只能取得自己书签中的主机的图标
+	faviconRepo := repository.NewFaviconRepository()
+	if icon, err := faviconRepo.GetByHost(bob, "c.example"); err != nil || string(icon.Data) != string(pngIcon) {
+		t.Errorf("GetByHost(bob, c.example) = %v, %v", icon, err)
+	}
+	if _, err := faviconRepo.GetByHost(alice, "c.example"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetByHost(alice, c.example) error = %v, want sql.ErrNoRows", err)
+	}
+
+	// 已缓存的主机不再获取，新书签直接使用缓存的图标
+	aliceC := createBookmark(t, alice, "https://c.example/new")
+	runOnce(t, w, 0)
+	if got, want := bookmarkFavicon(t, aliceC), repository.FaviconURL(alice, "c.example"); got != want {
+		t.Errorf("new bookmark favicon = %q, want %q", got, want)
+	}
+}
+
+func TestWorkerBackoff(t *testing.T) {
+	setupDB(t)
+	user := createUser(t, "alice")
+	id := createBookmark(t, user, "https://down.example/")
+
+	var up atomic.Bool
+	w := NewWorker(NewFetcher(standIn(t, map[string]http.Handler{
+		"down.example": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if up.Load() && r.URL.Path == "/favicon.ico" {
+				w.Write(icoIcon)
+				return
+			}
+			http.NotFound(w, r)
+		}),
+	})), 1)
+
+	// state 返回连续失败次数和距离下次获取的时间
+	state := func() (int, time.Duration) {
+		t.Helper()
+		var attempts int
+		var seconds float64
+		err := database.DB.QueryRow(`
+			SELECT attempts, (julianday(next_attempt_at) - julianday('now')) * 86400 FROM favicon_hosts WHERE host = 'down.example'
+		`).Scan(&attempts, &seconds)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return attempts, time.Duration(seconds) * time.Second
+	}
+	expire := func() {
+		database.DB.Exec(`UPDATE favicon_hosts SET next_attempt_at = datetime('now', '-1 second') WHERE host = 'down.example'`)
+	}
+	near := func(got, want time.Duration) bool {
+		return got > want-time.Minute && got <= want
+	}
+
+	runOnce(t, w, 1)
+	if attempts, wait := state(); attempts != 1 || !near(wait, retryInterval) {
+		t.Errorf("after first failure: attempts %d, retry in %v; want 1, %v", attempts, wait, retryInterval)
+	}
+	var lastError string
+	database.DB.QueryRow(`SELECT last_error FROM favicon_hosts WHERE host = 'down.example'`).Scan(&lastError)
+	if lastError == "" {
+		t.Error("last_error is empty")
+	}
+
+	// 未到重试时间不再获取
+	runOnce(t, w, 0)
+
+	expire()
+	runOnce(t, w, 1)
+	if attempts, wait := state(); attempts != 2 || !near(wait, 3*retryInterval) {
+		t.Errorf("after second failure: attempts %d, retry in %v; want 2, %v", attempts, wait, 3*retryInterval)
+	}
+	if got := bookmarkFavicon(t, id); got != "" {
+		t.Errorf("bookmark favicon = %q before the icon was fetched", got)
+	}
+
+	// 成功后清零失败次数，30 天后刷新
+	up.Store(true)
+	expire()
+	runOnce(t, w, 1)
+	if attempts, wait := state(); attempts != 0 || !near(wait, refreshInterval) {
+		t.Errorf("after success: attempts %d, refresh in %v; want 0, %v", attempts, wait, refreshInterval)
+	}
+	if got, want := bookmarkFavicon(t, id), repository.FaviconURL(user, "down.example"); got != want {
+		t.Errorf("bookmark favicon = %q, want %q", got, want)
+	}
+
+	// 刷新失败时保留已有的图标
+	up.Store(false)
+	expire()
+	runOnce(t, w, 1)
+	if attempts, _ := state(); attempts != 1 {
+		t.Errorf("after failed refresh: attempts %d, want 1", attempts)
+	}
+	if _, err := repository.NewFaviconRepository().GetByHost(user, "down.example"); err != nil {
+		t.Errorf("icon was dropped after a failed refresh: %v", err)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, 5 * time.Minute},
+		{1, 15 * time.Minute},
+		{2, 45 * time.Minute},
+		{5, 1215 * time.Minute},
+		{7, maxRetryInterval},
+		{100, maxRetryInterval},
+	}
+	for _, tt := range tests {
+		if got := retryDelay(tt.attempts); got != tt.want {
+			t.Errorf("retryDelay(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
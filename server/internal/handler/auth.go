@@ -1,22 +1,34 @@
 package handler
 
 import (
+	"database/sql"
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 
+	"Nibstash_v2_server/config"
 	"Nibstash_v2_server/internal/middleware"
 	"Nibstash_v2_server/internal/model"
 	"Nibstash_v2_server/internal/repository"
+	"Nibstash_v2_server/internal/util"
 
 	"github.com/gin-gonic/gin"
 )
 
 type AuthHandler struct {
-	userRepo *repository.UserRepository
+	userRepo     *repository.UserRepository
+	totpRepo     *repository.TOTPRepository
+	securityRepo *repository.SecurityRepository
+	sessionRepo  *repository.SessionRepository
 }
 
 func NewAuthHandler() *AuthHandler {
 	return &AuthHandler{
-		userRepo: repository.NewUserRepository(),
+		userRepo:     repository.NewUserRepository(),
+		totpRepo:     repository.NewTOTPRepository(),
+		securityRepo: repository.NewSecurityRepository(),
+		sessionRepo:  repository.NewSessionRepository(),
 	}
 }
 
@@ -28,34 +40,196 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	// 获取默认用户
-	user, err := h.userRepo.GetByUsername("admin")
+	// 未提供用户名时登录默认管理员（兼容旧版客户端）
+	username := req.Username
+	if username == "" {
+		username = model.DefaultUsername
+	}
+	// 账号或 IP 失败次数过多时直接拒绝，不校验密码
+	if throttled(c, h.securityRepo, username) {
+		return
+	}
+
+	// 用户不存在和密码错误返回相同的提示，避免探测用户名
+	user, err := h.userRepo.GetByUsername(username)
+	if err != nil || !h.userRepo.VerifyPassword(user, req.Password) {
+		var userID int64
+		if user != nil {
+			userID = user.ID
+		}
+		recordFailure(c, h.securityRepo, model.SecurityEventLoginFailed, userID, username)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户名或密码错误"})
+		return
+	}
+
+	// 已开启两步验证：先返回中间凭证，验证码通过后再签发 Token
+	if user.TOTPEnabled {
+		challenge, err := middleware.GenerateChallengeToken(user.ID, user.Username)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "生成Token失败"})
+			return
+		}
+		c.JSON(http.StatusOK, model.LoginChallengeResponse{TOTPRequired: true, Challenge: challenge})
+		return
+	}
+
+	h.respondLogin(c, user, false)
+}
+
+// LoginTOTP 两步验证登录的第二步：校验验证码或恢复码后签发 Token
+func (h *AuthHandler) LoginTOTP(c *gin.Context) {
+	var req model.LoginTOTPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	claims, err := middleware.ParseChallengeToken(req.Challenge)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "登录已过期，请重新输入密码"})
+		return
+	}
+
+	if throttled(c, h.securityRepo, claims.Username) {
+		return
+	}
+
+	if err := h.totpRepo.Verify(claims.UserID, req.Code); err != nil {
+		if err == repository.ErrTOTPCodeInvalid {
+			recordFailure(c, h.securityRepo, model.SecurityEventTOTPFailed, claims.UserID, claims.Username)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "验证失败"})
+		return
+	}
+
+	user, err := h.userRepo.GetByID(claims.UserID)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户不存在"})
 		return
 	}
 
-	// 验证密码
-	if !h.userRepo.VerifyPassword(user, req.Password) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "密码错误"})
+	// 刚通过两步验证，同时视为完成二次验证
+	h.respondLogin(c, user, true)
+}
+
+// respondLogin 创建会话，记录登录成功事件并返回 Token 和用户信息
+func (h *AuthHandler) respondLogin(c *gin.Context, user *model.User, stepUp bool) {
+	resp, ok := h.startSession(c, user, stepUp)
+	if !ok {
 		return
 	}
+	recordEvent(c, h.securityRepo, model.SecurityEventLoginSucceeded, user.ID, user.Username)
+	c.JSON(http.StatusOK, resp)
+}
+
+// startSession 创建会话并签发访问 Token 和刷新 Token，失败时写入响应并返回 false
+func (h *AuthHandler) startSession(c *gin.Context, user *model.User, stepUp bool) (*model.LoginResponse, bool) {
+	session, refreshToken, err := h.sessionRepo.Create(user.ID, c.ClientIP(), c.Request.UserAgent(), stepUp)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建会话失败"})
+		return nil, false
+	}
+	return h.issueTokens(c, user, session.ID, refreshToken)
+}
 
-	// 生成 Token
-	token, err := middleware.GenerateToken(user.ID, user.Username)
+// issueTokens 签发访问 Token，并把刷新 Token 写入 cookie（bookmarklet 使用）
+func (h *AuthHandler) issueTokens(c *gin.Context, user *model.User, sessionID int64, refreshToken string) (*model.LoginResponse, bool) {
+	token, err := middleware.GenerateToken(user.ID, user.Username, sessionID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成Token失败"})
+		return nil, false
+	}
+
+	setSessionCookie(c, refreshToken)
+	return &model.LoginResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(middleware.AccessTokenTTL.Seconds()),
+		User:         *user,
+	}, true
+}
+
+// setSessionCookie 设置或清除（refreshToken 为空）bookmarklet 使用的会话 cookie
+func setSessionCookie(c *gin.Context, refreshToken string) {
+	maxAge := int(repository.SessionTTL.Seconds())
+	if refreshToken == "" {
+		maxAge = -1
+	}
+	c.SetCookie("token", refreshToken, maxAge, "/", "", false, true)
+}
+
+// Refresh 用刷新 Token 换取新的访问 Token，刷新 Token 同时更换
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req model.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
 		return
 	}
 
-	// 设置 cookie（用于 bookmarklet 认证）
-	// 7天有效期，与 token 一致
-	c.SetCookie("token", token, 7*24*60*60, "/", "", false, false)
+	session, refreshToken, err := h.sessionRepo.Refresh(req.RefreshToken, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		if err == repository.ErrSessionInvalid {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "刷新会话失败"})
+		return
+	}
 
-	c.JSON(http.StatusOK, model.LoginResponse{
-		Token: token,
-		User:  *user,
-	})
+	user, err := h.userRepo.GetByID(session.UserID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户不存在"})
+		return
+	}
+
+	resp, ok := h.issueTokens(c, user, session.ID, refreshToken)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Logout 退出当前会话，访问 Token 和刷新 Token 立即失效
+func (h *AuthHandler) Logout(c *gin.Context) {
+	if err := h.sessionRepo.Delete(c.GetInt64("user_id"), c.GetInt64("session_id")); err != nil && err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "退出登录失败"})
+		return
+	}
+	util.LockVault(c.GetInt64("session_id"))
+
+	setSessionCookie(c, "")
+	c.JSON(http.StatusOK, gin.H{"message": "已退出登录"})
+}
+
+// Register 注册新用户，未开启开放注册时必须提供邀请码
+func (h *AuthHandler) Register(c *gin.Context) {
+	var req model.RegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	user, err := h.userRepo.Register(req.Username, req.Password, req.InviteCode, !config.App.AllowRegistration)
+	switch err {
+	case nil:
+	case repository.ErrUsernameTaken:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case repository.ErrInvitationInvalid:
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "注册失败"})
+		return
+	}
+
+	resp, ok := h.startSession(c, user, false)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusCreated, resp)
 }
 
 // GetMe 获取当前用户信息
@@ -91,11 +265,67 @@ func (h *AuthHandler) ChangePassword(c *gin.Context) {
 		return
 	}
 
+	if req.NewPassword == config.DefaultPassword {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不能使用默认密码"})
+		return
+	}
+
 	// 更新密码
 	if err := h.userRepo.UpdatePassword(userID, req.NewPassword); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新密码失败"})
 		return
 	}
+	recordEvent(c, h.securityRepo, model.SecurityEventPasswordChange, userID, user.Username)
+
+	// 吊销所有会话（包括其他设备）并锁定保险库，当前设备使用新会话继续登录
+	if _, err := h.sessionRepo.DeleteAll(userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "吊销会话失败"})
+		return
+	}
+	util.LockUserVaults(userID)
+	resp, ok := h.startSession(c, user, false)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "密码修改成功，其他设备已退出登录",
+		"token":         resp.Token,
+		"refresh_token": resp.RefreshToken,
+		"expires_in":    resp.ExpiresIn,
+	})
+}
+
+// throttled 检查账号和客户端 IP 是否处于退避或锁定期，是则返回 429 和需要等待的秒数
+func throttled(c *gin.Context, securityRepo *repository.SecurityRepository, username string) bool {
+	wait, err := securityRepo.LoginRetryAfter(c.ClientIP(), username)
+	if err != nil || wait <= 0 {
+		return false
+	}
+
+	seconds := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       fmt.Sprintf("尝试次数过多，请 %s后再试", formatWait(seconds)),
+		"retry_after": seconds,
+	})
+	return true
+}
+
+// formatWait 把等待秒数格式化为中文提示
+func formatWait(seconds int) string {
+	if seconds < 60 {
+		return fmt.Sprintf("%d 秒", seconds)
+	}
+	return fmt.Sprintf("%d 分钟", (seconds+59)/60)
+}
+
+// recordEvent 记录安全事件（附带客户端 IP 和 User-Agent），写入失败不影响请求
+func recordEvent(c *gin.Context, securityRepo *repository.SecurityRepository, event string, userID int64, username string) {
+	securityRepo.Record(event, userID, username, c.ClientIP(), c.Request.UserAgent())
+}
 
-	c.JSON(http.StatusOK, gin.H{"message": "密码修改成功"})
+// recordFailure 记录登录或验证码失败，计入限流次数
+func recordFailure(c *gin.Context, securityRepo *repository.SecurityRepository, event string, userID int64, username string) {
+	securityRepo.RecordFailure(event, userID, username, c.ClientIP(), c.Request.UserAgent())
 }
@@ -6,6 +6,7 @@ import (
 
 	"Nibstash_v2_server/internal/model"
 	"Nibstash_v2_server/internal/repository"
+	"Nibstash_v2_server/internal/search"
 
 	"github.com/gin-gonic/gin"
 )
@@ -36,8 +37,23 @@ func (h *BookmarkHandler) List(c *gin.Context) {
 		req.PageSize = 20
 	}
 
+	// 解析搜索语句，语法错误时返回出错位置
+	query, err := search.Parse(req.Search)
+	if err != nil {
+		if parseErr, ok := err.(*search.ParseError); ok {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":    "搜索语法错误: " + parseErr.Message,
+				"position": parseErr.Pos,
+				"token":    parseErr.Token,
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "搜索语法错误"})
+		return
+	}
+
 	bookmarks, total, err := h.bookmarkRepo.List(
-		req.Page, req.PageSize, req.TagID, req.Search,
+		c.GetInt64("user_id"), req.Page, req.PageSize, req.TagID, query,
 		req.FolderPath, req.FilterFolder, req.SortBy,
 	)
 	if err != nil {
@@ -65,14 +81,16 @@ func (h *BookmarkHandler) Create(c *gin.Context) {
 		return
 	}
 
+	userID := c.GetInt64("user_id")
+
 	// 检查是否已存在
-	if h.bookmarkRepo.Exists(req.URL, req.FolderPath) {
+	if h.bookmarkRepo.Exists(userID, req.URL, req.FolderPath) {
 		c.JSON(http.StatusConflict, gin.H{"error": "书签已存在"})
 		return
 	}
 
 	bookmark, err := h.bookmarkRepo.Create(
-		req.URL, req.Title, req.Description, req.Favicon, req.FolderPath, req.TagIDs,
+		userID, req.URL, req.Title, req.Description, req.Favicon, req.FolderPath, req.TagIDs,
 	)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建书签失败"})
@@ -90,7 +108,7 @@ func (h *BookmarkHandler) Get(c *gin.Context) {
 		return
 	}
 
-	bookmark, err := h.bookmarkRepo.GetByID(id)
+	bookmark, err := h.bookmarkRepo.GetByID(c.GetInt64("user_id"), id)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "书签不存在"})
 		return
@@ -113,8 +131,10 @@ func (h *BookmarkHandler) Update(c *gin.Context) {
 		return
 	}
 
+	userID := c.GetInt64("user_id")
+
 	// 获取现有书签
-	existing, err := h.bookmarkRepo.GetByID(id)
+	existing, err := h.bookmarkRepo.GetByID(userID, id)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "书签不存在"})
 		return
@@ -134,12 +154,12 @@ func (h *BookmarkHandler) Update(c *gin.Context) {
 		description = req.Description
 	}
 
-	if err := h.bookmarkRepo.Update(id, url, title, description, req.TagIDs); err != nil {
+	if err := h.bookmarkRepo.Update(userID, id, url, title, description, req.TagIDs); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新书签失败"})
 		return
 	}
 
-	bookmark, _ := h.bookmarkRepo.GetByID(id)
+	bookmark, _ := h.bookmarkRepo.GetByID(userID, id)
 	c.JSON(http.StatusOK, bookmark)
 }
 
@@ -151,7 +171,7 @@ func (h *BookmarkHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	if err := h.bookmarkRepo.Delete(id); err != nil {
+	if err := h.bookmarkRepo.Delete(c.GetInt64("user_id"), id); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除书签失败"})
 		return
 	}
@@ -169,12 +189,12 @@ func (h *BookmarkHandler) Batch(c *gin.Context) {
 
 	switch req.Action {
 	case "delete":
-		if err := h.bookmarkRepo.DeleteByIDs(req.IDs); err != nil {
+		if err := h.bookmarkRepo.DeleteByIDs(c.GetInt64("user_id"), req.IDs); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "批量删除失败"})
 			return
 		}
 	case "move":
-		if err := h.bookmarkRepo.MoveToFolder(req.IDs, req.Target); err != nil {
+		if err := h.bookmarkRepo.MoveToFolder(c.GetInt64("user_id"), req.IDs, req.Target); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "批量移动失败"})
 			return
 		}
@@ -188,7 +208,7 @@ func (h *BookmarkHandler) Batch(c *gin.Context) {
 
 // Export 导出书签
 func (h *BookmarkHandler) Export(c *gin.Context) {
-	bookmarks, err := h.bookmarkRepo.GetAllForExport()
+	bookmarks, err := h.bookmarkRepo.GetAllForExport(c.GetInt64("user_id"))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "导出失败"})
 		return
@@ -282,7 +302,7 @@ func escapeHTML(s string) string {
 
 // ClearAll 清空所有书签
 func (h *BookmarkHandler) ClearAll(c *gin.Context) {
-	if err := h.bookmarkRepo.DeleteAll(); err != nil {
+	if err := h.bookmarkRepo.DeleteAll(c.GetInt64("user_id")); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "清空失败"})
 		return
 	}
@@ -299,7 +319,7 @@ func (h *BookmarkHandler) ClearFolder(c *gin.Context) {
 		return
 	}
 
-	if err := h.bookmarkRepo.DeleteByFolder(req.FolderPath); err != nil {
+	if err := h.bookmarkRepo.DeleteByFolder(c.GetInt64("user_id"), req.FolderPath); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "清空失败"})
 		return
 	}
@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"net/http"
 
-	"Nibstash_v2_server/internal/middleware"
 	"Nibstash_v2_server/internal/repository"
 
 	"github.com/gin-gonic/gin"
@@ -13,12 +12,14 @@ import (
 type BookmarkletHandler struct {
 	bookmarkRepo *repository.BookmarkRepository
 	folderRepo   *repository.FolderRepository
+	sessionRepo  *repository.SessionRepository
 }
 
 func NewBookmarkletHandler() *BookmarkletHandler {
 	return &BookmarkletHandler{
 		bookmarkRepo: repository.NewBookmarkRepository(),
 		folderRepo:   repository.NewFolderRepository(),
+		sessionRepo:  repository.NewSessionRepository(),
 	}
 }
 
@@ -27,7 +28,7 @@ func (h *BookmarkletHandler) Handle(c *gin.Context) {
 	url := c.Query("url")
 	title := c.Query("title")
 
-	// 检查是否已登录
+	// 检查是否已登录（cookie 中保存的是会话的刷新 Token）
 	token, _ := c.Cookie("token")
 
 	// 验证 token
@@ -36,7 +37,7 @@ func (h *BookmarkletHandler) Handle(c *gin.Context) {
 		return
 	}
 
-	_, err := middleware.ParseToken(token)
+	session, err := h.sessionRepo.Authenticate(token)
 	if err != nil {
 		h.renderError(c, "登录已过期，请重新登录")
 		return
@@ -51,8 +52,8 @@ func (h *BookmarkletHandler) Handle(c *gin.Context) {
 		title = url
 	}
 
-	// 获取文件夹列表
-	folders := h.folderRepo.GetAllPaths()
+	// 获取文件夹列表（附带拼音索引，支持按拼音筛选）
+	folders := h.folderRepo.GetOptions(session.UserID)
 	foldersJSON, _ := json.Marshal(folders)
 
 	// 渲染表单页面
@@ -68,7 +69,7 @@ func (h *BookmarkletHandler) Save(c *gin.Context) {
 		return
 	}
 
-	_, err := middleware.ParseToken(token)
+	session, err := h.sessionRepo.Authenticate(token)
 	if err != nil {
 		h.renderResult(c, "error", "登录已过期，请重新登录", "")
 		return
@@ -89,13 +90,13 @@ func (h *BookmarkletHandler) Save(c *gin.Context) {
 	}
 
 	// 检查是否已存在
-	if h.bookmarkRepo.Exists(url, folderPath) {
+	if h.bookmarkRepo.Exists(session.UserID, url, folderPath) {
 		h.renderResult(c, "warning", "该网址已被收藏", url)
 		return
 	}
 
 	// 创建书签
-	_, err = h.bookmarkRepo.Create(url, title, description, "", folderPath, nil)
+	_, err = h.bookmarkRepo.Create(session.UserID, url, title, description, "", folderPath, nil)
 	if err != nil {
 		h.renderResult(c, "error", "收藏失败: "+err.Error(), "")
 		return
@@ -258,6 +259,9 @@ func (h *BookmarkletHandler) renderForm(c *gin.Context, url, title, foldersJSON
         .new-folder-btn:hover {
             background: #e4e7ed;
         }
+        .folder-filter {
+            margin-bottom: 8px;
+        }
         .new-folder-input {
             display: none;
             margin-top: 8px;
@@ -312,6 +316,7 @@ func (h *BookmarkletHandler) renderForm(c *gin.Context, url, title, foldersJSON
             </div>
             <div class="form-group">
                 <label class="form-label">文件夹</label>
+                <input type="text" id="folderFilter" class="form-input folder-filter" placeholder="筛选文件夹（支持拼音和首字母）">
                 <div class="folder-wrapper">
                     <select name="folder" id="folderSelect" class="form-select">
                         <option value="新收藏">新收藏</option>
@@ -332,14 +337,34 @@ func (h *BookmarkletHandler) renderForm(c *gin.Context, url, title, foldersJSON
 
         // 添加现有文件夹选项
         folders.forEach(function(folder) {
-            if (folder && folder !== '新收藏') {
+            if (folder.path && folder.path !== '新收藏') {
                 var option = document.createElement('option');
-                option.value = folder;
-                option.textContent = folder;
+                option.value = folder.path;
+                option.textContent = folder.path;
+                option.setAttribute('data-pinyin', folder.pinyin || '');
                 select.appendChild(option);
             }
         });
 
+        // 按路径或拼音筛选文件夹
+        document.getElementById('folderFilter').addEventListener('input', function() {
+            var keyword = this.value.trim().toLowerCase();
+            var firstMatch = null;
+            for (var i = 0; i < select.options.length; i++) {
+                var option = select.options[i];
+                var matched = !keyword ||
+                    option.value.toLowerCase().indexOf(keyword) !== -1 ||
+                    (option.getAttribute('data-pinyin') || '').indexOf(keyword) !== -1;
+                option.hidden = !matched;
+                if (matched && !firstMatch) {
+                    firstMatch = option;
+                }
+            }
+            if (firstMatch) {
+                select.value = firstMatch.value;
+            }
+        });
+
         function toggleNewFolder() {
             var input = document.getElementById('newFolderInput');
             input.classList.toggle('show');
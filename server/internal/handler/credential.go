@@ -2,27 +2,36 @@ package handler
 
 import (
 	"net/http"
+	"regexp"
 	"strconv"
+	"strings"
+	"time"
 
+	"Nibstash_v2_server/config"
 	"Nibstash_v2_server/internal/model"
 	"Nibstash_v2_server/internal/repository"
+	"Nibstash_v2_server/internal/util"
 
 	"github.com/gin-gonic/gin"
 )
 
 type CredentialHandler struct {
-	credRepo *repository.CredentialRepository
+	credRepo     *repository.CredentialRepository
+	vaultRepo    *repository.VaultRepository
+	securityRepo *repository.SecurityRepository
 }
 
 func NewCredentialHandler() *CredentialHandler {
 	return &CredentialHandler{
-		credRepo: repository.NewCredentialRepository(),
+		credRepo:     repository.NewCredentialRepository(),
+		vaultRepo:    repository.NewVaultRepository(),
+		securityRepo: repository.NewSecurityRepository(),
 	}
 }
 
 // List 获取凭证列表
 func (h *CredentialHandler) List(c *gin.Context) {
-	creds, err := h.credRepo.List()
+	creds, err := h.credRepo.List(c.GetInt64("user_id"))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取凭证列表失败"})
 		return
@@ -43,7 +52,45 @@ func (h *CredentialHandler) Create(c *gin.Context) {
 		return
 	}
 
-	cred, err := h.credRepo.Create(req.Domain, req.Title, req.Username, req.Password, req.Notes)
+	cred := &model.Credential{
+		Type:     req.Type,
+		Domain:   util.NormalizeHost(req.Domain),
+		Title:    req.Title,
+		Username: req.Username,
+		Password: req.Password,
+		Notes:    req.Notes,
+		Fields:   req.Fields,
+
+		MatchRule:    req.MatchRule,
+		MatchPattern: req.MatchPattern,
+	}
+	switch cred.Type {
+	case model.CredentialTypeNote:
+		if cred.Title == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "安全笔记的标题不能为空"})
+			return
+		}
+		if cred.Domain != "" || cred.Username != "" || cred.Password != "" || req.TOTPSecret != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "安全笔记不能包含域名、用户名、密码或 TOTP 密钥"})
+			return
+		}
+	default:
+		cred.Type = model.CredentialTypeLogin
+		if cred.Domain == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "域名不能为空"})
+			return
+		}
+	}
+
+	var ok bool
+	if cred.TOTPSecret, ok = normalizeTOTPSecret(c, req.TOTPSecret); !ok {
+		return
+	}
+	if !validateMatchRule(c, cred) {
+		return
+	}
+
+	cred, err := h.credRepo.Create(c.GetInt64("user_id"), cred)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建凭证失败"})
 		return
@@ -60,7 +107,7 @@ func (h *CredentialHandler) Get(c *gin.Context) {
 		return
 	}
 
-	cred, err := h.credRepo.GetByID(id)
+	cred, err := h.credRepo.GetByID(c.GetInt64("user_id"), id)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "凭证不存在"})
 		return
@@ -77,7 +124,7 @@ func (h *CredentialHandler) GetByDomain(c *gin.Context) {
 		return
 	}
 
-	creds, err := h.credRepo.GetByDomain(domain)
+	creds, err := h.credRepo.GetByDomain(c.GetInt64("user_id"), domain)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取凭证失败"})
 		return
@@ -90,6 +137,26 @@ func (h *CredentialHandler) GetByDomain(c *gin.Context) {
 	c.JSON(http.StatusOK, creds)
 }
 
+// Health 保险库健康报告：弱密码、重复使用的密码和长期未修改的密码
+func (h *CredentialHandler) Health(c *gin.Context) {
+	var req model.CredentialHealthRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	if req.MaxAgeDays == 0 {
+		req.MaxAgeDays = 365
+	}
+
+	report, err := h.credRepo.Health(c.GetInt64("user_id"), time.Duration(req.MaxAgeDays)*24*time.Hour)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成健康报告失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
 // Update 更新凭证
 func (h *CredentialHandler) Update(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
@@ -104,38 +171,283 @@ func (h *CredentialHandler) Update(c *gin.Context) {
 		return
 	}
 
+	userID := c.GetInt64("user_id")
+
 	// 获取现有凭证
-	existing, err := h.credRepo.GetByID(id)
+	existing, err := h.credRepo.GetByID(userID, id)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "凭证不存在"})
 		return
 	}
 
+	// 原密码无法解密时必须提供新密码，避免把空密码写回
+	if existing.DecryptFailed && req.Password == "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "原密码无法解密，请输入新密码"})
+		return
+	}
+	if existing.TOTPDecryptFailed && req.TOTPSecret == "" && !req.RemoveTOTP {
+		c.JSON(http.StatusConflict, gin.H{"error": "原 TOTP 密钥无法解密，请输入新密钥或删除"})
+		return
+	}
+	if existing.NotesDecryptFailed && req.Notes == "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "原备注无法解密，请输入新备注"})
+		return
+	}
+	if req.Fields == nil && fieldsDecryptFailed(existing.Fields) {
+		c.JSON(http.StatusConflict, gin.H{"error": "原自定义字段无法解密，请重新填写"})
+		return
+	}
+	if existing.Type == model.CredentialTypeNote && (req.Username != "" || req.Password != "" || req.TOTPSecret != "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "安全笔记不能包含用户名、密码或 TOTP 密钥"})
+		return
+	}
+
 	// 合并更新
-	title := existing.Title
-	username := existing.Username
-	password := existing.Password
-	notes := existing.Notes
+	cred := existing
 	if req.Title != "" {
-		title = req.Title
+		cred.Title = req.Title
 	}
 	if req.Username != "" {
-		username = req.Username
+		cred.Username = req.Username
 	}
 	if req.Password != "" {
-		password = req.Password
+		cred.Password = req.Password
 	}
 	if req.Notes != "" {
-		notes = req.Notes
+		cred.Notes = req.Notes
+	}
+	if req.Fields != nil {
+		cred.Fields = *req.Fields
+	}
+	if req.MatchRule != "" {
+		cred.MatchRule = req.MatchRule
+		cred.MatchPattern = req.MatchPattern
+	} else if req.MatchPattern != "" {
+		cred.MatchPattern = req.MatchPattern
+	}
+	if !validateMatchRule(c, cred) {
+		return
+	}
+	if req.RemoveTOTP {
+		cred.TOTPSecret = ""
+	} else if req.TOTPSecret != "" {
+		var ok bool
+		if cred.TOTPSecret, ok = normalizeTOTPSecret(c, req.TOTPSecret); !ok {
+			return
+		}
 	}
 
-	if err := h.credRepo.Update(id, title, username, password, notes); err != nil {
+	if err := h.credRepo.Update(userID, cred, config.App.CredentialHistory()); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新凭证失败"})
 		return
 	}
 
-	cred, _ := h.credRepo.GetByID(id)
-	c.JSON(http.StatusOK, cred)
+	updated, _ := h.credRepo.GetByID(userID, id)
+	c.JSON(http.StatusOK, updated)
+}
+
+// History 获取凭证的历史密码列表（不含密码）
+func (h *CredentialHandler) History(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的ID"})
+		return
+	}
+
+	entries, err := h.credRepo.History(c.GetInt64("user_id"), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取历史密码失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+// GetHistory 查看一条历史密码
+func (h *CredentialHandler) GetHistory(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的ID"})
+		return
+	}
+	historyID, err := strconv.ParseInt(c.Param("history_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的ID"})
+		return
+	}
+
+	entry, err := h.credRepo.GetHistory(c.GetInt64("user_id"), id, historyID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "历史密码不存在"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, entry)
+}
+
+// Restore 恢复历史密码：指定历史记录，或恢复到某个时间点正在使用的密码；当前密码会保存到历史记录
+func (h *CredentialHandler) Restore(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的ID"})
+		return
+	}
+
+	var req model.CredentialRestoreRequest
+	if err := c.ShouldBindJSON(&req); err != nil || (req.HistoryID == 0) == (req.At == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	userID := c.GetInt64("user_id")
+	existing, err := h.credRepo.GetByID(userID, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "凭证不存在"})
+		return
+	}
+
+	historyID := req.HistoryID
+	if req.At != nil {
+		if !req.At.Before(existing.PasswordChangedAt) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "该时间点使用的就是当前密码"})
+			return
+		}
+		entries, err := h.credRepo.History(userID, id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "恢复密码失败"})
+			return
+		}
+		for _, entry := range entries {
+			if !req.At.Before(entry.ValidFrom) && req.At.Before(entry.ReplacedAt) {
+				historyID = entry.ID
+				break
+			}
+		}
+		if historyID == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "没有该时间点的历史密码"})
+			return
+		}
+	}
+
+	entry, err := h.credRepo.GetHistory(userID, id, historyID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "历史密码不存在"})
+		return
+	}
+	if entry.DecryptFailed {
+		c.JSON(http.StatusConflict, gin.H{"error": "历史密码无法解密"})
+		return
+	}
+	if fieldsDecryptFailed(existing.Fields) || existing.NotesDecryptFailed || existing.TOTPDecryptFailed {
+		c.JSON(http.StatusConflict, gin.H{"error": "凭证的部分内容无法解密，请先编辑凭证"})
+		return
+	}
+
+	existing.Password = entry.Password
+	if err := h.credRepo.Update(userID, existing, config.App.CredentialHistory()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "恢复密码失败"})
+		return
+	}
+
+	updated, _ := h.credRepo.GetByID(userID, id)
+	c.JSON(http.StatusOK, updated)
+}
+
+// TOTP 返回凭证当前的 TOTP 验证码和剩余有效时间
+func (h *CredentialHandler) TOTP(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的ID"})
+		return
+	}
+
+	cred, err := h.credRepo.GetByID(c.GetInt64("user_id"), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "凭证不存在"})
+		return
+	}
+	if cred.TOTPDecryptFailed {
+		c.JSON(http.StatusConflict, gin.H{"error": "TOTP 密钥无法解密"})
+		return
+	}
+	if cred.TOTPSecret == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "凭证未设置 TOTP 密钥"})
+		return
+	}
+
+	cfg, err := util.ParseTOTPSecret(cred.TOTPSecret)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	code, remaining, err := cfg.Code(time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成验证码失败"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, model.CredentialTOTPCode{Code: code, Remaining: remaining, Period: cfg.Period, Digits: cfg.Digits})
+}
+
+// Match 按网页地址匹配凭证，供浏览器扩展和收藏工具自动填充使用
+func (h *CredentialHandler) Match(c *gin.Context) {
+	var req model.CredentialMatchRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	matches, err := h.credRepo.Match(c.GetInt64("user_id"), req.URL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "匹配凭证失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, matches)
+}
+
+// validateMatchRule 校验匹配规则的参数，不需要参数的规则清空 MatchPattern；无效时写入 400 响应
+func validateMatchRule(c *gin.Context, cred *model.Credential) bool {
+	switch cred.MatchRule {
+	case model.MatchStartsWith:
+		if cred.MatchPattern == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "starts_with 规则需要填写网址前缀"})
+			return false
+		}
+	case model.MatchRegex:
+		if _, err := regexp.Compile(cred.MatchPattern); err != nil || cred.MatchPattern == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的正则表达式"})
+			return false
+		}
+	default:
+		cred.MatchPattern = ""
+	}
+	return true
+}
+
+// fieldsDecryptFailed 是否有无法解密的 hidden 字段
+func fieldsDecryptFailed(fields []model.CredentialField) bool {
+	for _, field := range fields {
+		if field.DecryptFailed {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeTOTPSecret 校验 TOTP 密钥，返回去掉首尾空白后保存的值；无效时写入 400 响应
+func normalizeTOTPSecret(c *gin.Context, secret string) (string, bool) {
+	secret = strings.TrimSpace(secret)
+	if secret == "" {
+		return "", true
+	}
+	if _, err := util.ParseTOTPSecret(secret); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", false
+	}
+	return secret, true
 }
 
 // Delete 删除凭证
@@ -146,7 +458,7 @@ func (h *CredentialHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	if err := h.credRepo.Delete(id); err != nil {
+	if err := h.credRepo.Delete(c.GetInt64("user_id"), id); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除凭证失败"})
 		return
 	}
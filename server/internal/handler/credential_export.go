@@ -0,0 +1,249 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"Nibstash_v2_server/internal/model"
+	"Nibstash_v2_server/internal/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Export 导出全部凭证，无法解密的凭证不导出
+// encrypted 为用备份密码加密的备份文件；bitwarden、csv 为未加密文件，需要再次输入主密码
+func (h *CredentialHandler) Export(c *gin.Context) {
+	var req model.CredentialExportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	userID := c.GetInt64("user_id")
+	username := c.GetString("username")
+	if req.Format == model.CredentialExportEncrypted {
+		if len(req.Passphrase) < 8 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "备份密码至少 8 位"})
+			return
+		}
+	} else {
+		if throttled(c, h.securityRepo, username) {
+			return
+		}
+		if _, err := h.vaultRepo.Unlock(userID, req.MasterPassword); err != nil {
+			if err == util.ErrMasterPasswordFail {
+				recordFailure(c, h.securityRepo, model.SecurityEventVaultFailed, userID, username)
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "导出失败"})
+			return
+		}
+	}
+
+	creds, err := h.credRepo.List(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "导出失败"})
+		return
+	}
+	exportable := creds[:0]
+	for _, cred := range creds {
+		if !cred.DecryptFailed {
+			exportable = append(exportable, cred)
+		}
+	}
+
+	var (
+		content     []byte
+		contentType string
+		filename    string
+	)
+	switch req.Format {
+	case model.CredentialExportEncrypted:
+		content, err = encryptedCredentialExport(exportable, req.Passphrase)
+		contentType, filename = "application/json", "nibstash-credentials-backup.json"
+	case model.CredentialExportBitwarden:
+		content, err = bitwardenCredentialExport(exportable)
+		contentType, filename = "application/json", "nibstash-credentials.json"
+	case model.CredentialExportCSV:
+		content, err = csvCredentialExport(exportable)
+		contentType, filename = "text/csv; charset=utf-8", "nibstash-credentials.csv"
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "导出失败"})
+		return
+	}
+	recordEvent(c, h.securityRepo, model.SecurityEventCredExport, userID, username)
+
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.Data(http.StatusOK, contentType, content)
+}
+
+// encryptedCredentialExport 生成加密备份文件，导入时需要同一个备份密码
+func encryptedCredentialExport(creds []model.Credential, passphrase string) ([]byte, error) {
+	archive := model.CredentialArchive{Version: 1, ExportedAt: time.Now(), Credentials: []model.ArchiveCredential{}}
+	for _, cred := range creds {
+		archive.Credentials = append(archive.Credentials, model.ArchiveCredential{
+			Type:         cred.Type,
+			Domain:       cred.Domain,
+			Title:        cred.Title,
+			Username:     cred.Username,
+			Password:     cred.Password,
+			Notes:        cred.Notes,
+			TOTP:         cred.TOTPSecret,
+			Fields:       cred.Fields,
+			MatchRule:    cred.MatchRule,
+			MatchPattern: cred.MatchPattern,
+			CreatedAt:    cred.CreatedAt,
+			UpdatedAt:    cred.UpdatedAt,
+		})
+	}
+
+	plaintext, err := json.Marshal(archive)
+	if err != nil {
+		return nil, err
+	}
+	return util.SealArchive(passphrase, plaintext)
+}
+
+// bitwardenCredentialExport 生成 Bitwarden 未加密 JSON 导出格式
+func bitwardenCredentialExport(creds []model.Credential) ([]byte, error) {
+	type login struct {
+		URIs     []bitwardenURI `json:"uris"`
+		Username string         `json:"username"`
+		Password string         `json:"password"`
+		TOTP     *string        `json:"totp"`
+	}
+	type field struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+		Type  int    `json:"type"`
+	}
+	type secureNote struct {
+		Type int `json:"type"`
+	}
+	type item struct {
+		Type       int         `json:"type"`
+		Name       string      `json:"name"`
+		Notes      string      `json:"notes"`
+		Favorite   bool        `json:"favorite"`
+		Fields     []field     `json:"fields,omitempty"`
+		Login      *login      `json:"login,omitempty"`
+		SecureNote *secureNote `json:"secureNote,omitempty"`
+	}
+
+	items := []item{}
+	for _, cred := range creds {
+		var fields []field
+		for _, f := range cred.Fields {
+			fieldType := 0
+			if f.Type == model.FieldTypeHidden {
+				fieldType = 1
+			}
+			fields = append(fields, field{Name: f.Name, Value: f.Value, Type: fieldType})
+		}
+		if cred.Type == model.CredentialTypeNote {
+			items = append(items, item{Type: 2, Name: cred.Title, Notes: cred.Notes, Fields: fields, SecureNote: &secureNote{}})
+			continue
+		}
+
+		var totp *string
+		if cred.TOTPSecret != "" {
+			totp = &cred.TOTPSecret
+		}
+		items = append(items, item{
+			Type:   1,
+			Name:   cred.Title,
+			Notes:  cred.Notes,
+			Fields: fields,
+			Login: &login{
+				URIs:     bitwardenURIs(cred),
+				Username: cred.Username,
+				Password: cred.Password,
+				TOTP:     totp,
+			},
+		})
+	}
+
+	return json.MarshalIndent(gin.H{"encrypted": false, "folders": []any{}, "items": items}, "", "  ")
+}
+
+// bitwardenURIs 按匹配规则生成 Bitwarden 的网址列表；starts_with、regex 的规则参数作为第一个网址，
+// 凭证域名作为从不匹配的第二个网址保留
+func bitwardenURIs(cred model.Credential) []bitwardenURI {
+	domainURI := "https://" + cred.Domain
+	match := func(m int) *int { return &m }
+	switch cred.MatchRule {
+	case model.MatchHost:
+		return []bitwardenURI{{URI: domainURI, Match: match(1)}}
+	case model.MatchStartsWith:
+		return []bitwardenURI{{URI: cred.MatchPattern, Match: match(2)}, {URI: domainURI, Match: match(5)}}
+	case model.MatchRegex:
+		return []bitwardenURI{{URI: cred.MatchPattern, Match: match(4)}, {URI: domainURI, Match: match(5)}}
+	case model.MatchNever:
+		return []bitwardenURI{{URI: domainURI, Match: match(5)}}
+	}
+	return []bitwardenURI{{URI: domainURI}}
+}
+
+type bitwardenURI struct {
+	Match *int   `json:"match"`
+	URI   string `json:"uri"`
+}
+
+// csvCredentialExport 生成 Bitwarden 兼容的 CSV
+func csvCredentialExport(creds []model.Credential) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"folder", "favorite", "type", "name", "notes", "fields", "reprompt", "login_uri", "login_username", "login_password", "login_totp"})
+	for _, cred := range creds {
+		var fields []string
+		for _, f := range cred.Fields {
+			fields = append(fields, f.Name+": "+f.Value)
+		}
+		if cred.Type == model.CredentialTypeNote {
+			w.Write([]string{"", "", "note", cred.Title, cred.Notes, strings.Join(fields, "\n"), "", "", "", "", ""})
+			continue
+		}
+		w.Write([]string{"", "", "login", cred.Title, cred.Notes, strings.Join(fields, "\n"), "", "https://" + cred.Domain, cred.Username, cred.Password, cred.TOTPSecret})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// parseCredentialArchive 解密加密备份文件，返回其中的凭证
+func parseCredentialArchive(content []byte, passphrase string) ([]model.ImportCredential, error) {
+	if passphrase == "" {
+		return nil, errArchivePassphraseRequired
+	}
+	plaintext, err := util.OpenArchive(passphrase, content)
+	if err != nil {
+		return nil, err
+	}
+
+	var archive model.CredentialArchive
+	if err := json.Unmarshal(plaintext, &archive); err != nil {
+		return nil, util.ErrArchiveInvalid
+	}
+
+	var items []model.ImportCredential
+	for _, cred := range archive.Credentials {
+		items = append(items, model.ImportCredential{
+			Type:         cred.Type,
+			Domain:       cred.Domain,
+			Title:        cred.Title,
+			Username:     cred.Username,
+			Password:     cred.Password,
+			Notes:        cred.Notes,
+			TOTP:         cred.TOTP,
+			Fields:       cred.Fields,
+			MatchRule:    cred.MatchRule,
+			MatchPattern: cred.MatchPattern,
+		})
+	}
+	return items, nil
+}
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"Nibstash_v2_server/internal/model"
+	"Nibstash_v2_server/internal/util"
+)
+
+func TestEncryptedCredentialExportRoundTrip(t *testing.T) {
+	now := time.Now()
+	creds := []model.Credential{
+		{
+			Type:         model.CredentialTypeLogin,
+			Domain:       "github.com",
+			Title:        "GitHub",
+			Username:     "alice",
+			Password:     "correct horse battery staple",
+			Notes:        "主账号",
+			TOTPSecret:   "otpauth://totp/GitHub:alice?secret=JBSWY3DPEHPK3PXP&issuer=GitHub",
+			Fields:       []model.CredentialField{{Name: "恢复邮箱", Type: model.FieldTypeEmail, Value: "alice@example.com"}, {Name: "PIN", Type: model.FieldTypeHidden, Value: "1234"}},
+			MatchRule:    model.MatchStartsWith,
+			MatchPattern: "https://github.com/login",
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		},
+		{
+			Type:         model.CredentialTypeLogin,
+			Domain:       "example.com",
+			Title:        "Example",
+			Username:     "bob",
+			Password:     "hunter2",
+			Fields:       []model.CredentialField{},
+			MatchRule:    model.MatchRegex,
+			MatchPattern: `^https://example\.com/(a|b)$`,
+		},
+		{
+			Type:   model.CredentialTypeNote,
+			Title:  "Wi-Fi",
+			Notes:  "密码在路由器背面",
+			Fields: []model.CredentialField{{Name: "SSID", Type: model.FieldTypeText, Value: "home"}},
+		},
+	}
+
+	content, err := encryptedCredentialExport(creds, "backup passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	format, items, err := parseCredentialExport(content, "backup passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "archive" {
+		t.Errorf("format = %q, want archive", format)
+	}
+	if len(items) != len(creds) {
+		t.Fatalf("got %d items, want %d", len(items), len(creds))
+	}
+	for i, cred := range creds {
+		want := model.ImportCredential{
+			Type:         cred.Type,
+			Domain:       cred.Domain,
+			Title:        cred.Title,
+			Username:     cred.Username,
+			Password:     cred.Password,
+			Notes:        cred.Notes,
+			TOTP:         cred.TOTPSecret,
+			Fields:       cred.Fields,
+			MatchRule:    cred.MatchRule,
+			MatchPattern: cred.MatchPattern,
+		}
+		if len(want.Fields) == 0 {
+			want.Fields = nil
+		}
+		got := items[i]
+		if len(got.Fields) == 0 {
+			got.Fields = nil
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("item %d = %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+func TestEncryptedCredentialExportPassphrase(t *testing.T) {
+	content, err := encryptedCredentialExport([]model.Credential{{Type: model.CredentialTypeLogin, Domain: "github.com", Password: "secret"}}, "right")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := parseCredentialExport(content, ""); err != errArchivePassphraseRequired {
+		t.Errorf("empty passphrase error = %v, want %v", err, errArchivePassphraseRequired)
+	}
+	if _, _, err := parseCredentialExport(content, "wrong"); err != util.ErrArchivePassphrase {
+		t.Errorf("wrong passphrase error = %v, want %v", err, util.ErrArchivePassphrase)
+	}
+}
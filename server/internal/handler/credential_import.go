@@ -0,0 +1,461 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"Nibstash_v2_server/internal/model"
+	"Nibstash_v2_server/internal/repository"
+	"Nibstash_v2_server/internal/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	errImportFormat              = errors.New("无法识别的文件格式，支持 Bitwarden JSON、KeePass XML/CSV 和浏览器导出的密码 CSV")
+	errArchivePassphraseRequired = errors.New("请输入备份文件密码")
+)
+
+// ImportCredentials 从 Bitwarden、KeePass、浏览器导出的文件或加密备份文件导入凭证
+// preview=true 时只返回每一条的处理结果，确认后再不带 preview 上传同一文件导入
+// 加密备份文件需要在表单中提供 passphrase
+func (h *ImportHandler) ImportCredentials(c *gin.Context) {
+	var req model.CredentialImportRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	file, _, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请上传文件"})
+		return
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "读取文件失败"})
+		return
+	}
+
+	format, items, err := parseCredentialExport(content, c.PostForm("passphrase"))
+	if err != nil {
+		if err == errArchivePassphraseRequired {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "passphrase_required": true})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(items) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "未找到有效的凭证"})
+		return
+	}
+
+	result, err := h.credRepo.Import(c.GetInt64("user_id"), items, req.Preview)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "导入失败"})
+		return
+	}
+	result.Format = format
+
+	c.JSON(http.StatusOK, result)
+}
+
+// parseCredentialExport 识别导出文件格式并解析，返回格式名称和凭证列表
+func parseCredentialExport(content []byte, passphrase string) (string, []model.ImportCredential, error) {
+	content = bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))
+	trimmed := bytes.TrimSpace(content)
+	if len(trimmed) == 0 {
+		return "", nil, errImportFormat
+	}
+
+	var (
+		format string
+		items  []model.ImportCredential
+		err    error
+	)
+	switch {
+	case util.IsArchive(trimmed):
+		format = "archive"
+		items, err = parseCredentialArchive(trimmed, passphrase)
+	case trimmed[0] == '{':
+		format = "bitwarden"
+		items, err = parseBitwardenJSON(trimmed)
+	case trimmed[0] == '<':
+		format = "keepass_xml"
+		items, err = parseKeePassXML(trimmed)
+	default:
+		format, items, err = parsePasswordCSV(content)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	// 登录网址统一转换为域名（备份文件中已经是域名），安全笔记没有域名
+	for i := range items {
+		item := &items[i]
+		item.Fields = importFields(item.Fields)
+		if item.Type == model.CredentialTypeNote {
+			if item.Invalid == "" && item.Title == "" {
+				item.Invalid = "安全笔记缺少标题"
+			}
+			continue
+		}
+		item.Type = model.CredentialTypeLogin
+		if item.Domain == "" {
+			item.Domain = importDomain(item.URL)
+		}
+		if item.Invalid == "" && item.Domain == "" {
+			item.Invalid = "缺少网址或网址无效"
+		}
+		if item.Invalid == "" && item.MatchRule == model.MatchRegex {
+			if _, err := regexp.Compile(item.MatchPattern); err != nil {
+				item.Invalid = "无效的正则表达式"
+			}
+		}
+		// 无法识别的 TOTP 密钥（如 Steam 令牌）不导入，登录项本身照常导入
+		item.TOTP = strings.TrimSpace(item.TOTP)
+		if item.TOTP != "" {
+			if _, err := util.ParseTOTPSecret(item.TOTP); err != nil {
+				item.TOTP = ""
+				item.Warning = err.Error() + "，未导入 TOTP"
+			}
+		}
+		if item.Title == "" {
+			item.Title = item.Domain
+		}
+	}
+	return format, items, nil
+}
+
+// importFields 去掉没有名称或类型无效的自定义字段
+func importFields(fields []model.CredentialField) []model.CredentialField {
+	valid := fields[:0]
+	for _, field := range fields {
+		switch field.Type {
+		case model.FieldTypeText, model.FieldTypeHidden, model.FieldTypeURL, model.FieldTypeEmail:
+			if field.Name != "" {
+				valid = append(valid, field)
+			}
+		}
+	}
+	return valid
+}
+
+// importDomain 从登录网址中提取域名，兼容没有协议的网址（KeePass 中常见）
+func importDomain(rawURL string) string {
+	url := strings.ToLower(strings.TrimSpace(rawURL))
+	if url == "" {
+		return ""
+	}
+	if !strings.Contains(url, "://") {
+		url = "https://" + url
+	}
+	return repository.ExtractDomain(url)
+}
+
+// Bitwarden 未加密的 JSON 导出
+type bitwardenExport struct {
+	Encrypted bool `json:"encrypted"`
+	Items     []struct {
+		Type   int    `json:"type"` // 1 为登录项，2 为安全笔记
+		Name   string `json:"name"`
+		Notes  string `json:"notes"`
+		Fields []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+			Type  int    `json:"type"` // 0 文本，1 隐藏，2 布尔，3 关联字段
+		} `json:"fields"`
+		Login *struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+			TOTP     string `json:"totp"`
+			URIs     []struct {
+				URI   string `json:"uri"`
+				Match *int   `json:"match"` // 为空时使用默认规则
+			} `json:"uris"`
+		} `json:"login"`
+	} `json:"items"`
+}
+
+func parseBitwardenJSON(content []byte) ([]model.ImportCredential, error) {
+	var export bitwardenExport
+	if err := json.Unmarshal(content, &export); err != nil {
+		return nil, errImportFormat
+	}
+	if export.Encrypted {
+		return nil, errors.New("不支持加密的 Bitwarden 导出，请选择 .json 格式导出")
+	}
+
+	var items []model.ImportCredential
+	for _, it := range export.Items {
+		item := model.ImportCredential{Title: it.Name, Notes: it.Notes}
+		for _, field := range it.Fields {
+			switch field.Type {
+			case 0, 2:
+				item.Fields = append(item.Fields, model.CredentialField{Name: field.Name, Type: model.FieldTypeText, Value: field.Value})
+			case 1:
+				item.Fields = append(item.Fields, model.CredentialField{Name: field.Name, Type: model.FieldTypeHidden, Value: field.Value})
+			}
+		}
+		if it.Type == 2 {
+			item.Type = model.CredentialTypeNote
+			items = append(items, item)
+			continue
+		}
+		if it.Type != 1 || it.Login == nil {
+			item.Invalid = "不是登录项或安全笔记"
+			items = append(items, item)
+			continue
+		}
+		item.Username = it.Login.Username
+		item.Password = it.Login.Password
+		item.TOTP = it.Login.TOTP
+		// 取第一个能提取出域名的网址
+		if len(it.Login.URIs) > 0 {
+			item.MatchRule, item.MatchPattern = bitwardenMatchRule(it.Login.URIs[0].Match, it.Login.URIs[0].URI)
+		}
+		for _, uri := range it.Login.URIs {
+			if importDomain(uri.URI) != "" {
+				item.URL = uri.URI
+				break
+			}
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// bitwardenMatchRule 转换 Bitwarden 的网址匹配方式（0 域名、1 主机、2 开头、3 完全相同、4 正则、5 从不），
+// 完全相同转换为正则表达式
+func bitwardenMatchRule(match *int, uri string) (string, string) {
+	if match == nil {
+		return "", ""
+	}
+	switch *match {
+	case 1:
+		return model.MatchHost, ""
+	case 2:
+		return model.MatchStartsWith, uri
+	case 3:
+		return model.MatchRegex, "^" + regexp.QuoteMeta(uri) + "$"
+	case 4:
+		return model.MatchRegex, uri
+	case 5:
+		return model.MatchNever, ""
+	}
+	return "", ""
+}
+
+// KeePass 2.x 未加密的 XML 导出
+type keepassFile struct {
+	Meta struct {
+		RecycleBinUUID string `xml:"RecycleBinUUID"`
+	} `xml:"Meta"`
+	Root struct {
+		Groups []keepassGroup `xml:"Group"`
+	} `xml:"Root"`
+}
+
+type keepassGroup struct {
+	UUID    string         `xml:"UUID"`
+	Name    string         `xml:"Name"`
+	Entries []keepassEntry `xml:"Entry"`
+	Groups  []keepassGroup `xml:"Group"`
+}
+
+// keepassEntry 条目的字段，历史版本（History）不导入
+type keepassEntry struct {
+	Strings []struct {
+		Key   string `xml:"Key"`
+		Value string `xml:"Value"`
+	} `xml:"String"`
+}
+
+func parseKeePassXML(content []byte) ([]model.ImportCredential, error) {
+	var file keepassFile
+	if err := xml.Unmarshal(content, &file); err != nil || len(file.Root.Groups) == 0 {
+		return nil, errImportFormat
+	}
+
+	var items []model.ImportCredential
+	var walk func(group keepassGroup)
+	walk = func(group keepassGroup) {
+		// 跳过回收站中的条目
+		if file.Meta.RecycleBinUUID != "" && group.UUID == file.Meta.RecycleBinUUID {
+			return
+		}
+		for _, entry := range group.Entries {
+			var item model.ImportCredential
+			fields := make(map[string]string)
+			for _, s := range entry.Strings {
+				fields[s.Key] = s.Value
+				switch s.Key {
+				case "Title":
+					item.Title = s.Value
+				case "UserName":
+					item.Username = s.Value
+				case "Password":
+					item.Password = s.Value
+				case "URL":
+					item.URL = s.Value
+				case "Notes":
+					item.Notes = s.Value
+				}
+			}
+			item.TOTP = keepassTOTP(fields)
+			items = append(items, item)
+		}
+		for _, child := range group.Groups {
+			walk(child)
+		}
+	}
+	for _, group := range file.Root.Groups {
+		walk(group)
+	}
+	return items, nil
+}
+
+// keepassTOTP 读取条目的 TOTP 设置：KeePassXC 的 otp 字段（otpauth:// URI），
+// 或 KeePass 2.47 起内置的 TimeOtp-* 字段（转换为 otpauth:// URI）
+func keepassTOTP(fields map[string]string) string {
+	if otp := fields["otp"]; otp != "" {
+		return otp
+	}
+	secret := fields["TimeOtp-Secret-Base32"]
+	if secret == "" {
+		return ""
+	}
+
+	params := url.Values{}
+	params.Set("secret", secret)
+	switch fields["TimeOtp-Algorithm"] {
+	case "HMAC-SHA-256":
+		params.Set("algorithm", "SHA256")
+	case "HMAC-SHA-512":
+		params.Set("algorithm", "SHA512")
+	}
+	if digits := fields["TimeOtp-Length"]; digits != "" {
+		params.Set("digits", digits)
+	}
+	if period := fields["TimeOtp-Period"]; period != "" {
+		params.Set("period", period)
+	}
+	return "otpauth://totp/?" + params.Encode()
+}
+
+// 各种 CSV 导出的列名（小写）对应的字段
+var csvColumns = map[string]string{
+	"url":            "url",
+	"web site":       "url",
+	"website":        "url",
+	"login_uri":      "url",
+	"username":       "username",
+	"user name":      "username",
+	"login name":     "username",
+	"login_username": "username",
+	"password":       "password",
+	"login_password": "password",
+	"name":           "title",
+	"title":          "title",
+	"account":        "title",
+	"note":           "notes",
+	"notes":          "notes",
+	"comments":       "notes",
+	"totp":           "totp",
+	"login_totp":     "totp",
+	"type":           "type",
+	"fields":         "fields",
+}
+
+// parsePasswordCSV 按表头解析 Chrome、Firefox、KeePass（KeePassXC）、Bitwarden 等导出的密码 CSV
+func parsePasswordCSV(content []byte) (string, []model.ImportCredential, error) {
+	reader := csv.NewReader(bytes.NewReader(content))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil || len(records) < 1 {
+		return "", nil, errImportFormat
+	}
+
+	header := make(map[string]bool)
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		name = strings.ToLower(strings.TrimSpace(name))
+		header[name] = true
+		if field, ok := csvColumns[name]; ok {
+			if _, exists := columns[field]; !exists {
+				columns[field] = i
+			}
+		}
+	}
+	if _, ok := columns["url"]; !ok {
+		return "", nil, errImportFormat
+	}
+	// Bitwarden CSV 中的安全笔记
+	_, hasType := columns["type"]
+	if _, ok := columns["password"]; !ok {
+		return "", nil, errImportFormat
+	}
+
+	format := "csv"
+	switch {
+	case header["login_uri"]:
+		format = "bitwarden_csv"
+	case header["httprealm"]:
+		format = "firefox_csv"
+	case header["group"] || header["login name"]:
+		format = "keepass_csv"
+	case header["name"] && header["url"] && header["username"]:
+		format = "chrome_csv"
+	}
+
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return record[i]
+	}
+
+	var items []model.ImportCredential
+	for _, record := range records[1:] {
+		item := model.ImportCredential{
+			URL:      field(record, "url"),
+			Title:    field(record, "title"),
+			Username: field(record, "username"),
+			Password: field(record, "password"),
+			Notes:    field(record, "notes"),
+			TOTP:     field(record, "totp"),
+			Fields:   parseCSVFields(field(record, "fields")),
+		}
+		if hasType && field(record, "type") == "note" {
+			item.Type = model.CredentialTypeNote
+		}
+		items = append(items, item)
+	}
+	return format, items, nil
+}
+
+// parseCSVFields 解析 Bitwarden CSV 的 fields 列，每行一个 "名称: 值"，均按文本字段导入
+func parseCSVFields(text string) []model.CredentialField {
+	var fields []model.CredentialField
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, "\r")
+		name, value, ok := strings.Cut(line, ": ")
+		if !ok || name == "" {
+			continue
+		}
+		fields = append(fields, model.CredentialField{Name: name, Type: model.FieldTypeText, Value: value})
+	}
+	return fields
+}
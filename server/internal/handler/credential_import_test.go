@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"testing"
+
+	"Nibstash_v2_server/internal/model"
+)
+
+func TestParseCredentialExportInvalidTOTP(t *testing.T) {
+	content := []byte(`{"encrypted": false, "items": [
+		{"type": 1, "name": "Steam", "login": {"username": "gabe", "password": "secret", "totp": "steam://ABCDEFGH", "uris": [{"uri": "https://store.steampowered.com"}]}},
+		{"type": 1, "name": "GitHub", "login": {"username": "alice", "password": "secret", "totp": "JBSWY3DPEHPK3PXP", "uris": [{"uri": "https://github.com"}]}}
+	]}`)
+
+	format, items, err := parseCredentialExport(content, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "bitwarden" || len(items) != 2 {
+		t.Fatalf("got %s with %d items, want bitwarden with 2 items", format, len(items))
+	}
+
+	steam := items[0]
+	if steam.Invalid != "" || steam.TOTP != "" || steam.Warning == "" {
+		t.Errorf("steam item = %+v, want valid login without TOTP and with a warning", steam)
+	}
+	if steam.Type != model.CredentialTypeLogin || steam.Domain != "store.steampowered.com" || steam.Password != "secret" {
+		t.Errorf("steam item = %+v, want login for store.steampowered.com", steam)
+	}
+
+	github := items[1]
+	if github.Invalid != "" || github.Warning != "" || github.TOTP != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("github item = %+v, want TOTP kept without warning", github)
+	}
+}
@@ -1,8 +1,11 @@
 package handler
 
 import (
+	"database/sql"
 	"net/http"
+	"strconv"
 
+	"Nibstash_v2_server/internal/model"
 	"Nibstash_v2_server/internal/repository"
 
 	"github.com/gin-gonic/gin"
@@ -11,27 +14,45 @@ import (
 type DomainHandler struct {
 	domainRepo     *repository.DomainRepository
 	credentialRepo *repository.CredentialRepository
+	equivRepo      *repository.EquivalentDomainRepository
 }
 
 func NewDomainHandler() *DomainHandler {
 	return &DomainHandler{
 		domainRepo:     repository.NewDomainRepository(),
 		credentialRepo: repository.NewCredentialRepository(),
+		equivRepo:      repository.NewEquivalentDomainRepository(),
 	}
 }
 
-// List 获取域名列表（实时计算，解决刷新问题）
+// List 获取域名列表（实时计算，解决刷新问题），collapse=true 时合并等价域名组
 func (h *DomainHandler) List(c *gin.Context) {
-	domains, err := h.domainRepo.GetAllDomains()
+	var req model.DomainListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+
+	domains, total, err := h.domainRepo.ListDomains(c.GetInt64("user_id"), req.Collapse, req.Page, req.PageSize)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取域名列表失败"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"domains": domains})
+	// 提供 page_size 时分页
+	resp := model.DomainListResponse{Domains: domains}
+	if req.PageSize > 0 {
+		resp.Total, resp.Page, resp.PageSize = total, req.Page, req.PageSize
+	}
+
+	c.JSON(http.StatusOK, resp)
 }
 
-// GetBookmarks 获取指定域名的书签
+// GetBookmarks 分页获取指定域名的书签
 func (h *DomainHandler) GetBookmarks(c *gin.Context) {
 	domain := c.Param("domain")
 	if domain == "" {
@@ -39,13 +60,34 @@ func (h *DomainHandler) GetBookmarks(c *gin.Context) {
 		return
 	}
 
-	bookmarks, err := h.domainRepo.GetBookmarksByDomain(domain)
+	var req model.DomainBookmarkListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 {
+		req.PageSize = 20
+	}
+
+	bookmarks, total, err := h.domainRepo.GetBookmarksByDomain(c.GetInt64("user_id"), domain, req.Page, req.PageSize)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取书签失败"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"bookmarks": bookmarks})
+	if bookmarks == nil {
+		bookmarks = []model.Bookmark{}
+	}
+
+	c.JSON(http.StatusOK, model.BookmarkListResponse{
+		Bookmarks: bookmarks,
+		Total:     total,
+		Page:      req.Page,
+		PageSize:  req.PageSize,
+	})
 }
 
 // Delete 删除域名记录和凭证数据（不删除书签）
@@ -57,16 +99,130 @@ func (h *DomainHandler) Delete(c *gin.Context) {
 	}
 
 	// 删除该域名的凭证
-	if err := h.credentialRepo.DeleteByDomain(domain); err != nil {
+	if err := h.credentialRepo.DeleteByDomain(c.GetInt64("user_id"), domain); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除凭证失败"})
 		return
 	}
 
 	// 删除域名记录
-	if err := h.domainRepo.DeleteDomain(domain); err != nil {
+	if err := h.domainRepo.DeleteDomain(c.GetInt64("user_id"), domain); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除域名失败"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
 }
+
+// ListEquivalents 获取内置和自定义的等价域名组
+func (h *DomainHandler) ListEquivalents(c *gin.Context) {
+	list, err := h.equivRepo.List(c.GetInt64("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取等价域名失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+// CreateEquivalent 创建自定义等价域名组
+func (h *DomainHandler) CreateEquivalent(c *gin.Context) {
+	var req model.EquivalentDomainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	domains, ok := normalizeEquivalentDomains(c, req.Domains)
+	if !ok {
+		return
+	}
+
+	group, err := h.equivRepo.Create(c.GetInt64("user_id"), domains)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建等价域名失败"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, group)
+}
+
+// UpdateEquivalent 修改自定义等价域名组的域名
+func (h *DomainHandler) UpdateEquivalent(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的ID"})
+		return
+	}
+
+	var req model.EquivalentDomainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	domains, ok := normalizeEquivalentDomains(c, req.Domains)
+	if !ok {
+		return
+	}
+
+	userID := c.GetInt64("user_id")
+	if err := h.equivRepo.Update(userID, id, domains); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "等价域名组不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新等价域名失败"})
+		return
+	}
+
+	group, _ := h.equivRepo.GetByID(userID, id)
+	c.JSON(http.StatusOK, group)
+}
+
+// DeleteEquivalent 删除自定义等价域名组
+func (h *DomainHandler) DeleteEquivalent(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的ID"})
+		return
+	}
+
+	if err := h.equivRepo.Delete(c.GetInt64("user_id"), id); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "等价域名组不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除等价域名失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// ExcludeGlobalEquivalent 停用或重新启用内置等价域名组
+func (h *DomainHandler) ExcludeGlobalEquivalent(c *gin.Context) {
+	var req model.EquivalentDomainExcludeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	if err := h.equivRepo.SetExcluded(c.GetInt64("user_id"), c.Param("key"), req.Excluded); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "内置等价域名组不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新等价域名失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "更新成功"})
+}
+
+// normalizeEquivalentDomains 转换为顶级域名并去重，不足两个时返回 400
+func normalizeEquivalentDomains(c *gin.Context, input []string) ([]string, bool) {
+	domains := repository.NormalizeEquivalentDomains(input)
+	if len(domains) < 2 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "等价域名组至少需要两个不同的有效域名"})
+		return nil, false
+	}
+	return domains, true
+}
@@ -1,27 +1,63 @@
 package handler
 
 import (
+	"database/sql"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"Nibstash_v2_server/internal/repository"
+	"Nibstash_v2_server/internal/util"
 
 	"github.com/gin-gonic/gin"
 )
 
 type FaviconHandler struct {
 	bookmarkRepo *repository.BookmarkRepository
+	faviconRepo  *repository.FaviconRepository
 }
 
 func NewFaviconHandler() *FaviconHandler {
 	return &FaviconHandler{
 		bookmarkRepo: repository.NewBookmarkRepository(),
+		faviconRepo:  repository.NewFaviconRepository(),
 	}
 }
 
+// Host 返回服务器缓存的主机图标，供 <img> 直接引用，不需要访问令牌；地址需带有 FaviconURL 生成的用户 ID 和签名，
+// 且该用户有这个主机的书签，签名无效时与没有图标一样返回 404。只读缓存，不会触发获取
+func (h *FaviconHandler) Host(c *gin.Context) {
+	host := util.NormalizeHost(c.Param("host"))
+	userID, err := strconv.ParseInt(c.Query("u"), 10, 64)
+	if err != nil || !repository.ValidFaviconSignature(userID, host, c.Query("sig")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "图标不存在"})
+		return
+	}
+
+	icon, err := h.faviconRepo.GetByHost(userID, host)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "图标不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取图标失败"})
+		return
+	}
+
+	etag := `"` + icon.Hash + `"`
+	c.Header("Cache-Control", "private, max-age=86400")
+	c.Header("ETag", etag)
+	c.Header("X-Content-Type-Options", "nosniff")
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, icon.ContentType, icon.Data)
+}
+
 // GetPending 获取待处理的 favicon 列表
 func (h *FaviconHandler) GetPending(c *gin.Context) {
-	bookmarks, err := h.bookmarkRepo.GetWithoutFavicon()
+	bookmarks, err := h.bookmarkRepo.GetWithoutFavicon(c.GetInt64("user_id"))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取列表失败"})
 		return
@@ -46,7 +82,7 @@ func (h *FaviconHandler) Update(c *gin.Context) {
 		return
 	}
 
-	if err := h.bookmarkRepo.UpdateFavicon(id, req.Favicon); err != nil {
+	if err := h.bookmarkRepo.UpdateFavicon(c.GetInt64("user_id"), id, req.Favicon); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新失败"})
 		return
 	}
@@ -21,7 +21,7 @@ func NewFolderHandler() *FolderHandler {
 
 // List 获取文件夹树
 func (h *FolderHandler) List(c *gin.Context) {
-	tree, err := h.folderRepo.GetFolderTree()
+	tree, err := h.folderRepo.GetFolderTree(c.GetInt64("user_id"))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取文件夹列表失败"})
 		return
@@ -32,7 +32,7 @@ func (h *FolderHandler) List(c *gin.Context) {
 	}
 
 	// 添加未分类节点
-	hasUncategorized := h.folderRepo.HasUncategorized()
+	hasUncategorized := h.folderRepo.HasUncategorized(c.GetInt64("user_id"))
 
 	c.JSON(http.StatusOK, gin.H{
 		"folders":          tree,
@@ -48,7 +48,7 @@ func (h *FolderHandler) Create(c *gin.Context) {
 		return
 	}
 
-	if err := h.folderRepo.Create(req.Path); err != nil {
+	if err := h.folderRepo.Create(c.GetInt64("user_id"), req.Path); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建文件夹失败"})
 		return
 	}
@@ -64,7 +64,7 @@ func (h *FolderHandler) Move(c *gin.Context) {
 		return
 	}
 
-	if err := h.folderRepo.Move(req.SourcePath, req.TargetPath); err != nil {
+	if err := h.folderRepo.Move(c.GetInt64("user_id"), req.SourcePath, req.TargetPath); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "移动文件夹失败"})
 		return
 	}
@@ -80,7 +80,7 @@ func (h *FolderHandler) Merge(c *gin.Context) {
 		return
 	}
 
-	if err := h.folderRepo.Merge(req.SourcePath, req.TargetPath); err != nil {
+	if err := h.folderRepo.Merge(c.GetInt64("user_id"), req.SourcePath, req.TargetPath); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "合并文件夹失败"})
 		return
 	}
@@ -92,7 +92,7 @@ func (h *FolderHandler) Merge(c *gin.Context) {
 func (h *FolderHandler) Delete(c *gin.Context) {
 	path := c.Query("path")
 
-	if err := h.folderRepo.Delete(path); err != nil {
+	if err := h.folderRepo.Delete(c.GetInt64("user_id"), path); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除文件夹失败"})
 		return
 	}
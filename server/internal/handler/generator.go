@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"math"
+	"net/http"
+
+	"Nibstash_v2_server/internal/model"
+	"Nibstash_v2_server/internal/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GeneratorHandler struct{}
+
+func NewGeneratorHandler() *GeneratorHandler {
+	return &GeneratorHandler{}
+}
+
+// Generate 生成密码，同时返回熵和强度评估
+func (h *GeneratorHandler) Generate(c *gin.Context) {
+	var req model.PasswordGenerateRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	opts := util.GeneratorOptions{
+		Mode:             req.Mode,
+		Length:           req.Length,
+		Lowercase:        boolOr(req.Lowercase, true),
+		Uppercase:        boolOr(req.Uppercase, true),
+		Digits:           boolOr(req.Digits, true),
+		Symbols:          boolOr(req.Symbols, true),
+		ExcludeAmbiguous: req.ExcludeAmbiguous,
+		Words:            req.Words,
+		Separator:        "-",
+		Capitalize:       req.Capitalize,
+		IncludeNumber:    req.IncludeNumber,
+	}
+	if opts.Mode == "" {
+		opts.Mode = util.GenerateRandom
+	}
+	if opts.Length == 0 {
+		opts.Length = 20
+	}
+	if opts.Words == 0 {
+		opts.Words = 5
+	}
+	if req.Separator != nil {
+		opts.Separator = *req.Separator
+	}
+
+	password, entropy, err := util.GeneratePassword(opts)
+	if err != nil {
+		if err == util.ErrNoCharset {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成密码失败"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{
+		"password":     password,
+		"entropy_bits": math.Round(entropy*10) / 10,
+		"strength":     util.EstimateStrength(password),
+	})
+}
+
+// Strength 评估密码强度（不保存），用户名和域名作为额外的字典
+func (h *GeneratorHandler) Strength(c *gin.Context) {
+	var req model.PasswordStrengthRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	c.JSON(http.StatusOK, util.EstimateStrength(req.Password, req.Username, req.Domain))
+}
+
+// boolOr 未指定时返回默认值
+func boolOr(v *bool, def bool) bool {
+	if v == nil {
+		return def
+	}
+	return *v
+}
@@ -14,11 +14,13 @@ import (
 
 type ImportHandler struct {
 	bookmarkRepo *repository.BookmarkRepository
+	credRepo     *repository.CredentialRepository
 }
 
 func NewImportHandler() *ImportHandler {
 	return &ImportHandler{
 		bookmarkRepo: repository.NewBookmarkRepository(),
+		credRepo:     repository.NewCredentialRepository(),
 	}
 }
 
@@ -43,7 +45,7 @@ func (h *ImportHandler) Import(c *gin.Context) {
 		return
 	}
 
-	imported, skipped, err := h.bookmarkRepo.BatchImport(bookmarks, true)
+	imported, skipped, err := h.bookmarkRepo.BatchImport(c.GetInt64("user_id"), bookmarks, true)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "导入失败"})
 		return
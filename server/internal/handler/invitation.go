@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"Nibstash_v2_server/internal/model"
+	"Nibstash_v2_server/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+type InvitationHandler struct {
+	invitationRepo *repository.InvitationRepository
+}
+
+func NewInvitationHandler() *InvitationHandler {
+	return &InvitationHandler{
+		invitationRepo: repository.NewInvitationRepository(),
+	}
+}
+
+// List 获取邀请码列表
+func (h *InvitationHandler) List(c *gin.Context) {
+	invitations, err := h.invitationRepo.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取邀请码列表失败"})
+		return
+	}
+
+	if invitations == nil {
+		invitations = []model.Invitation{}
+	}
+
+	c.JSON(http.StatusOK, invitations)
+}
+
+// Create 创建邀请码，明文只返回这一次
+func (h *InvitationHandler) Create(c *gin.Context) {
+	var req model.InvitationCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	invitation, code, err := h.invitationRepo.Create(c.GetInt64("user_id"), req.ExpiresInDays)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建邀请码失败"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, model.InvitationCreateResponse{Invitation: *invitation, Code: code})
+}
+
+// Delete 删除邀请码
+func (h *InvitationHandler) Delete(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的ID"})
+		return
+	}
+
+	if err := h.invitationRepo.Delete(id); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "邀请码不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除邀请码失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
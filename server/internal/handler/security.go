@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"net/http"
+
+	"Nibstash_v2_server/internal/model"
+	"Nibstash_v2_server/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SecurityHandler struct {
+	userRepo     *repository.UserRepository
+	securityRepo *repository.SecurityRepository
+}
+
+func NewSecurityHandler() *SecurityHandler {
+	return &SecurityHandler{
+		userRepo:     repository.NewUserRepository(),
+		securityRepo: repository.NewSecurityRepository(),
+	}
+}
+
+// ListEvents 获取安全事件（登录、失败尝试、锁定等），管理员可查看所有用户
+func (h *SecurityHandler) ListEvents(c *gin.Context) {
+	var req model.SecurityEventListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	userID := c.GetInt64("user_id")
+	if req.All {
+		user, err := h.userRepo.GetByID(userID)
+		if err != nil || user.Role != model.RoleAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "需要管理员权限"})
+			return
+		}
+		userID = 0
+	}
+
+	events, total, err := h.securityRepo.List(userID, req.Page, req.PageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取安全事件失败"})
+		return
+	}
+
+	if events == nil {
+		events = []model.SecurityEvent{}
+	}
+
+	c.JSON(http.StatusOK, model.SecurityEventListResponse{
+		Events:   events,
+		Total:    total,
+		Page:     req.Page,
+		PageSize: req.PageSize,
+	})
+}
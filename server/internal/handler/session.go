@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"Nibstash_v2_server/internal/model"
+	"Nibstash_v2_server/internal/repository"
+	"Nibstash_v2_server/internal/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SessionHandler struct {
+	sessionRepo *repository.SessionRepository
+}
+
+func NewSessionHandler() *SessionHandler {
+	return &SessionHandler{
+		sessionRepo: repository.NewSessionRepository(),
+	}
+}
+
+// List 获取当前用户的登录会话（设备、IP、最后活跃时间）
+func (h *SessionHandler) List(c *gin.Context) {
+	sessions, err := h.sessionRepo.List(c.GetInt64("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取会话列表失败"})
+		return
+	}
+
+	if sessions == nil {
+		sessions = []model.Session{}
+	}
+
+	currentID := c.GetInt64("session_id")
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentID
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// Delete 退出指定会话
+func (h *SessionHandler) Delete(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的ID"})
+		return
+	}
+
+	if err := h.sessionRepo.Delete(c.GetInt64("user_id"), id); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "会话不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "退出会话失败"})
+		return
+	}
+	util.LockVault(id)
+
+	if id == c.GetInt64("session_id") {
+		setSessionCookie(c, "")
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "已退出该会话"})
+}
+
+// DeleteAll 退出所有会话（包括当前会话）并锁定保险库
+func (h *SessionHandler) DeleteAll(c *gin.Context) {
+	count, err := h.sessionRepo.DeleteAll(c.GetInt64("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "退出会话失败"})
+		return
+	}
+
+	// 退出所有设备时同时锁定所有会话的保险库
+	util.LockUserVaults(c.GetInt64("user_id"))
+
+	setSessionCookie(c, "")
+	c.JSON(http.StatusOK, gin.H{"message": "已退出所有设备", "count": count})
+}
@@ -20,9 +20,9 @@ func NewTagHandler() *TagHandler {
 	}
 }
 
-// List 获取标签列表
+// List 获取标签列表（search 参数支持名称、全拼和首字母）
 func (h *TagHandler) List(c *gin.Context) {
-	tags, err := h.tagRepo.List()
+	tags, err := h.tagRepo.List(c.GetInt64("user_id"), c.Query("search"))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取标签列表失败"})
 		return
@@ -43,14 +43,16 @@ func (h *TagHandler) Create(c *gin.Context) {
 		return
 	}
 
+	userID := c.GetInt64("user_id")
+
 	// 检查是否已存在
-	existing, _ := h.tagRepo.GetByName(req.Name)
+	existing, _ := h.tagRepo.GetByName(userID, req.Name)
 	if existing != nil {
 		c.JSON(http.StatusConflict, gin.H{"error": "标签已存在"})
 		return
 	}
 
-	tag, err := h.tagRepo.Create(req.Name, req.Color)
+	tag, err := h.tagRepo.Create(userID, req.Name, req.Color)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建标签失败"})
 		return
@@ -73,8 +75,10 @@ func (h *TagHandler) Update(c *gin.Context) {
 		return
 	}
 
+	userID := c.GetInt64("user_id")
+
 	// 获取现有标签
-	existing, err := h.tagRepo.GetByID(id)
+	existing, err := h.tagRepo.GetByID(userID, id)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "标签不存在"})
 		return
@@ -90,12 +94,12 @@ func (h *TagHandler) Update(c *gin.Context) {
 		color = req.Color
 	}
 
-	if err := h.tagRepo.Update(id, name, color); err != nil {
+	if err := h.tagRepo.Update(userID, id, name, color); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新标签失败"})
 		return
 	}
 
-	tag, _ := h.tagRepo.GetByID(id)
+	tag, _ := h.tagRepo.GetByID(userID, id)
 	c.JSON(http.StatusOK, tag)
 }
 
@@ -107,7 +111,7 @@ func (h *TagHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	if err := h.tagRepo.Delete(id); err != nil {
+	if err := h.tagRepo.Delete(c.GetInt64("user_id"), id); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除标签失败"})
 		return
 	}
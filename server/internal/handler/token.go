@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"Nibstash_v2_server/internal/model"
+	"Nibstash_v2_server/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TokenHandler struct {
+	tokenRepo *repository.TokenRepository
+}
+
+func NewTokenHandler() *TokenHandler {
+	return &TokenHandler{
+		tokenRepo: repository.NewTokenRepository(),
+	}
+}
+
+// List 获取当前用户的 API Token 列表
+func (h *TokenHandler) List(c *gin.Context) {
+	tokens, err := h.tokenRepo.List(c.GetInt64("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取 Token 列表失败"})
+		return
+	}
+
+	if tokens == nil {
+		tokens = []model.APIToken{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tokens": tokens, "available_scopes": model.AllScopes})
+}
+
+// Create 创建 API Token，明文只返回这一次
+func (h *TokenHandler) Create(c *gin.Context) {
+	var req model.APITokenCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	apiToken, token, err := h.tokenRepo.Create(c.GetInt64("user_id"), req.Name, req.Scopes, req.ExpiresInDays)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建 Token 失败"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, model.APITokenCreateResponse{APIToken: *apiToken, Token: token})
+}
+
+// Delete 吊销 API Token
+func (h *TokenHandler) Delete(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的ID"})
+		return
+	}
+
+	if err := h.tokenRepo.Delete(c.GetInt64("user_id"), id); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Token 不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "吊销 Token 失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "已吊销"})
+}
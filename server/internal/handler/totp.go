@@ -0,0 +1,202 @@
+package handler
+
+import (
+	"net/http"
+
+	"Nibstash_v2_server/config"
+	"Nibstash_v2_server/internal/middleware"
+	"Nibstash_v2_server/internal/model"
+	"Nibstash_v2_server/internal/repository"
+	"Nibstash_v2_server/internal/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TOTPHandler struct {
+	userRepo     *repository.UserRepository
+	totpRepo     *repository.TOTPRepository
+	securityRepo *repository.SecurityRepository
+	sessionRepo  *repository.SessionRepository
+}
+
+func NewTOTPHandler() *TOTPHandler {
+	return &TOTPHandler{
+		userRepo:     repository.NewUserRepository(),
+		totpRepo:     repository.NewTOTPRepository(),
+		securityRepo: repository.NewSecurityRepository(),
+		sessionRepo:  repository.NewSessionRepository(),
+	}
+}
+
+// Status 获取两步验证状态
+func (h *TOTPHandler) Status(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+	user, err := h.userRepo.GetByID(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.TOTPStatusResponse{
+		Enabled:                user.TOTPEnabled,
+		RecoveryCodesRemaining: h.totpRepo.RemainingRecoveryCodes(userID),
+	})
+}
+
+// Setup 生成新的 TOTP 密钥（未开启前可重复调用，以最后一次为准）
+func (h *TOTPHandler) Setup(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+	user, err := h.userRepo.GetByID(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+
+	secret, err := util.GenerateTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成密钥失败"})
+		return
+	}
+
+	if err := h.totpRepo.SetPendingSecret(userID, secret); err != nil {
+		if err == repository.ErrTOTPAlreadyEnabled {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存密钥失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.TOTPSetupResponse{
+		Secret: secret,
+		URI:    util.TOTPURI(config.App.AppName, user.Username, secret),
+	})
+}
+
+// Enable 校验验证码后开启两步验证，返回恢复码（只返回这一次）
+func (h *TOTPHandler) Enable(c *gin.Context) {
+	var req model.TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	userID := c.GetInt64("user_id")
+	codes, err := h.totpRepo.Enable(userID, req.Code)
+	switch err {
+	case nil:
+	case repository.ErrTOTPCodeInvalid:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case repository.ErrTOTPAlreadyEnabled:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case repository.ErrTOTPNotEnabled:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请先生成密钥"})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "开启两步验证失败"})
+		return
+	}
+
+	recordEvent(c, h.securityRepo, model.SecurityEventTOTPEnabled, userID, c.GetString("username"))
+	c.JSON(http.StatusOK, gin.H{"message": "两步验证已开启", "recovery_codes": codes})
+}
+
+// Disable 关闭两步验证，需要密码和验证码（或恢复码）
+func (h *TOTPHandler) Disable(c *gin.Context) {
+	var req model.TOTPDisableRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	userID := c.GetInt64("user_id")
+	user, err := h.userRepo.GetByID(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+	if !h.userRepo.VerifyPassword(user, req.Password) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "密码错误"})
+		return
+	}
+
+	if !h.verifyCode(c, userID, req.Code) {
+		return
+	}
+
+	if err := h.totpRepo.Disable(userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "关闭两步验证失败"})
+		return
+	}
+
+	recordEvent(c, h.securityRepo, model.SecurityEventTOTPDisabled, userID, user.Username)
+	c.JSON(http.StatusOK, gin.H{"message": "两步验证已关闭"})
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码，需要验证码（或未使用的恢复码）
+func (h *TOTPHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req model.TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	userID := c.GetInt64("user_id")
+	if !h.verifyCode(c, userID, req.Code) {
+		return
+	}
+
+	codes, err := h.totpRepo.RegenerateRecoveryCodes(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成恢复码失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// StepUp 二次验证：校验验证码后记录到当前会话，有效期内可查看凭证密码
+func (h *TOTPHandler) StepUp(c *gin.Context) {
+	var req model.TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	userID := c.GetInt64("user_id")
+	if !h.verifyCode(c, userID, req.Code) {
+		return
+	}
+
+	if err := h.sessionRepo.SetStepUp(userID, c.GetInt64("session_id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "二次验证失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "二次验证成功", "expires_in": int(middleware.StepUpTTL.Seconds())})
+}
+
+// verifyCode 校验验证码或恢复码，失败时写入响应并返回 false
+// 与登录共用限流计数，防止已登录的会话被用来暴力猜测验证码
+func (h *TOTPHandler) verifyCode(c *gin.Context, userID int64, code string) bool {
+	username := c.GetString("username")
+	if throttled(c, h.securityRepo, username) {
+		return false
+	}
+
+	err := h.totpRepo.Verify(userID, code)
+	switch err {
+	case nil:
+		return true
+	case repository.ErrTOTPCodeInvalid:
+		recordFailure(c, h.securityRepo, model.SecurityEventTOTPFailed, userID, username)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case repository.ErrTOTPNotEnabled:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "验证失败"})
+	}
+	return false
+}
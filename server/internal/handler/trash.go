@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"net/http"
+
+	"Nibstash_v2_server/config"
+	"Nibstash_v2_server/internal/model"
+	"Nibstash_v2_server/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TrashHandler struct {
+	trashRepo *repository.TrashRepository
+}
+
+func NewTrashHandler() *TrashHandler {
+	return &TrashHandler{
+		trashRepo: repository.NewTrashRepository(),
+	}
+}
+
+// List 获取回收站条目
+func (h *TrashHandler) List(c *gin.Context) {
+	var req model.TrashListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	items, total, err := h.trashRepo.List(c.GetInt64("user_id"), req.Page, req.PageSize, req.Type, config.App.TrashRetention())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取回收站失败"})
+		return
+	}
+
+	if items == nil {
+		items = []model.TrashItem{}
+	}
+
+	c.JSON(http.StatusOK, model.TrashListResponse{
+		Items:    items,
+		Total:    total,
+		Page:     req.Page,
+		PageSize: req.PageSize,
+	})
+}
+
+// Restore 恢复条目（逐个恢复，遇到错误时停止并返回已恢复的数量）
+func (h *TrashHandler) Restore(c *gin.Context) {
+	var req model.TrashBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	userID := c.GetInt64("user_id")
+	for i, item := range req.Items {
+		if err := h.trashRepo.Restore(userID, item.Type, item.ID); err != nil {
+			status := http.StatusInternalServerError
+			message := "恢复失败"
+			switch err {
+			case repository.ErrTrashItemNotFound:
+				status, message = http.StatusNotFound, err.Error()
+			case repository.ErrTrashConflict:
+				status, message = http.StatusConflict, err.Error()
+			}
+			c.JSON(status, gin.H{"error": message, "restored": i, "item": item})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "恢复成功", "restored": len(req.Items)})
+}
+
+// Purge 永久删除条目
+func (h *TrashHandler) Purge(c *gin.Context) {
+	var req model.TrashBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	userID := c.GetInt64("user_id")
+	purged := 0
+	for _, item := range req.Items {
+		err := h.trashRepo.Purge(userID, item.Type, item.ID)
+		if err == repository.ErrTrashItemNotFound {
+			continue
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "删除失败", "purged": purged})
+			return
+		}
+		purged++
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功", "purged": purged})
+}
+
+// Empty 清空回收站
+func (h *TrashHandler) Empty(c *gin.Context) {
+	if err := h.trashRepo.Empty(c.GetInt64("user_id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "清空回收站失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "回收站已清空"})
+}
@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"net/http"
+
+	"Nibstash_v2_server/config"
+	"Nibstash_v2_server/internal/model"
+	"Nibstash_v2_server/internal/repository"
+	"Nibstash_v2_server/internal/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+type VaultHandler struct {
+	vaultRepo    *repository.VaultRepository
+	credRepo     *repository.CredentialRepository
+	securityRepo *repository.SecurityRepository
+}
+
+func NewVaultHandler() *VaultHandler {
+	return &VaultHandler{
+		vaultRepo:    repository.NewVaultRepository(),
+		credRepo:     repository.NewCredentialRepository(),
+		securityRepo: repository.NewSecurityRepository(),
+	}
+}
+
+// Status 获取保险库状态
+func (h *VaultHandler) Status(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+	resp := model.VaultStatusResponse{Initialized: h.vaultRepo.Initialized(userID)}
+	if locksAt, ok := util.VaultUnlockedUntil(userID, c.GetInt64("session_id")); ok {
+		resp.Unlocked = true
+		if !locksAt.IsZero() {
+			resp.LocksAt = &locksAt
+		}
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// Setup 设置主密码，已有凭证重新加密为保险库密钥，完成后保险库处于解锁状态
+// 无法解密的凭证保持原样，在 failed 中列出
+func (h *VaultHandler) Setup(c *gin.Context) {
+	var req model.VaultSetupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	userID := c.GetInt64("user_id")
+	keys, failed, err := h.vaultRepo.Setup(userID, req.MasterPassword)
+	if err != nil {
+		if err == repository.ErrVaultAlreadyInitialized {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "设置主密码失败"})
+		return
+	}
+
+	util.UnlockVault(userID, c.GetInt64("session_id"), keys, config.App.VaultTimeout())
+	// 加密之前保存的明文备注，失败时下次解锁再试
+	h.credRepo.EncryptLegacyNotes(userID)
+	c.JSON(http.StatusOK, gin.H{"message": "主密码设置成功，保险库已解锁", "failed": failed})
+}
+
+// Unlock 用主密码解锁保险库，与登录共用失败限流
+func (h *VaultHandler) Unlock(c *gin.Context) {
+	var req model.VaultUnlockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	userID := c.GetInt64("user_id")
+	username := c.GetString("username")
+	if throttled(c, h.securityRepo, username) {
+		return
+	}
+
+	keys, err := h.vaultRepo.Unlock(userID, req.MasterPassword)
+	switch err {
+	case nil:
+	case util.ErrMasterPasswordFail:
+		recordFailure(c, h.securityRepo, model.SecurityEventVaultFailed, userID, username)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case repository.ErrVaultNotInitialized:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "vault_setup_required": true})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "解锁失败"})
+		return
+	}
+
+	util.UnlockVault(userID, c.GetInt64("session_id"), keys, config.App.VaultTimeout())
+	// 加密之前保存的明文备注，失败时下次解锁再试
+	h.credRepo.EncryptLegacyNotes(userID)
+	c.JSON(http.StatusOK, gin.H{"message": "保险库已解锁"})
+}
+
+// Lock 锁定当前会话的保险库，清除内存中的密钥
+func (h *VaultHandler) Lock(c *gin.Context) {
+	util.LockVault(c.GetInt64("session_id"))
+	c.JSON(http.StatusOK, gin.H{"message": "保险库已锁定"})
+}
+
+// ChangePassword 修改主密码
+func (h *VaultHandler) ChangePassword(c *gin.Context) {
+	var req model.VaultChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	userID := c.GetInt64("user_id")
+	username := c.GetString("username")
+	if throttled(c, h.securityRepo, username) {
+		return
+	}
+
+	err := h.vaultRepo.ChangeMasterPassword(userID, req.OldMasterPassword, req.NewMasterPassword)
+	switch err {
+	case nil:
+	case util.ErrMasterPasswordFail:
+		recordFailure(c, h.securityRepo, model.SecurityEventVaultFailed, userID, username)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "旧主密码错误"})
+		return
+	case repository.ErrVaultNotInitialized:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "vault_setup_required": true})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "修改主密码失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "主密码修改成功"})
+}
+
+// Rotate 轮换保险库密钥并重新加密所有凭证，返回重新加密的报告
+func (h *VaultHandler) Rotate(c *gin.Context) {
+	var req model.VaultRotateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	userID := c.GetInt64("user_id")
+	username := c.GetString("username")
+	if throttled(c, h.securityRepo, username) {
+		return
+	}
+
+	keys, report, err := h.vaultRepo.Rotate(userID, req.MasterPassword)
+	switch err {
+	case nil:
+	case util.ErrMasterPasswordFail:
+		recordFailure(c, h.securityRepo, model.SecurityEventVaultFailed, userID, username)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case repository.ErrVaultNotInitialized:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "vault_setup_required": true})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "轮换密钥失败"})
+		return
+	}
+
+	// 其他会话中的旧密钥不含新密钥，全部锁定；当前会话使用新密钥保持解锁
+	util.LockUserVaults(userID)
+	util.UnlockVault(userID, c.GetInt64("session_id"), keys, config.App.VaultTimeout())
+	c.JSON(http.StatusOK, report)
+}
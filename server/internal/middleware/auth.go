@@ -2,38 +2,84 @@ package middleware
 
 import (
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
 	"Nibstash_v2_server/config"
+	"Nibstash_v2_server/internal/model"
+	"Nibstash_v2_server/internal/repository"
+	"Nibstash_v2_server/internal/util"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// AccessTokenTTL 访问 Token 的有效期，过期后用刷新 Token 换取新的访问 Token
+const AccessTokenTTL = 15 * time.Minute
+
+// StepUpTTL 二次验证的有效期，超过后访问凭证密码需要重新输入验证码
+const StepUpTTL = 10 * time.Minute
+
+// totpChallengeTTL 密码校验通过后输入两步验证码的时限
+const totpChallengeTTL = 5 * time.Minute
+
+// purposeTOTPChallenge 两步验证登录中间凭证的用途，只能用于 /auth/login/totp
+const purposeTOTPChallenge = "totp_challenge"
+
 type Claims struct {
-	UserID   int64  `json:"user_id"`
-	Username string `json:"username"`
+	UserID    int64  `json:"user_id"`
+	Username  string `json:"username"`
+	SessionID int64  `json:"sid,omitempty"`     // 所属会话，会话删除后 Token 立即失效
+	Purpose   string `json:"purpose,omitempty"` // 非空表示不是登录 Token
 	jwt.RegisteredClaims
 }
 
-// GenerateToken 生成 JWT Token
-func GenerateToken(userID int64, username string) (string, error) {
-	claims := Claims{
-		UserID:   userID,
-		Username: username,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(7 * 24 * time.Hour)), // 7天有效期
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
+// GenerateToken 生成会话的访问 Token
+func GenerateToken(userID int64, username string, sessionID int64) (string, error) {
+	return signToken(Claims{UserID: userID, Username: username, SessionID: sessionID}, AccessTokenTTL)
+}
+
+// GenerateChallengeToken 生成两步验证登录的中间凭证，不能用于访问接口
+func GenerateChallengeToken(userID int64, username string) (string, error) {
+	return signToken(Claims{UserID: userID, Username: username, Purpose: purposeTOTPChallenge}, totpChallengeTTL)
+}
+
+func signToken(claims Claims, ttl time.Duration) (string, error) {
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(config.App.JWTSecret))
 }
 
-// ParseToken 解析 JWT Token
+// ParseToken 解析访问 Token（拒绝两步验证中间凭证和不属于任何会话的旧版 Token）
 func ParseToken(tokenString string) (*Claims, error) {
+	claims, err := parseClaims(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != "" || claims.SessionID == 0 {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+	return claims, nil
+}
+
+// ParseChallengeToken 解析两步验证登录的中间凭证
+func ParseChallengeToken(tokenString string) (*Claims, error) {
+	claims, err := parseClaims(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != purposeTOTPChallenge {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+	return claims, nil
+}
+
+func parseClaims(tokenString string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 		return []byte(config.App.JWTSecret), nil
 	})
@@ -48,8 +94,11 @@ func ParseToken(tokenString string) (*Claims, error) {
 	return nil, jwt.ErrSignatureInvalid
 }
 
-// Auth JWT 认证中间件
+// Auth 认证中间件，同时接受登录 JWT 和个人 API Token（nbs_ 开头）
 func Auth() gin.HandlerFunc {
+	tokenRepo := repository.NewTokenRepository()
+	sessionRepo := repository.NewSessionRepository()
+
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -66,6 +115,22 @@ func Auth() gin.HandlerFunc {
 			return
 		}
 
+		// 个人 API Token：只能访问其权限范围内的接口
+		if strings.HasPrefix(parts[1], repository.APITokenPrefix) {
+			apiToken, err := tokenRepo.Authenticate(parts[1])
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的 API Token"})
+				c.Abort()
+				return
+			}
+
+			c.Set("user_id", apiToken.UserID)
+			c.Set("api_token_id", apiToken.ID)
+			c.Set("scopes", apiToken.Scopes)
+			c.Next()
+			return
+		}
+
 		claims, err := ParseToken(parts[1])
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的认证信息"})
@@ -73,9 +138,119 @@ func Auth() gin.HandlerFunc {
 			return
 		}
 
+		// 会话已退出（或修改密码后被吊销）时 Token 立即失效
+		session, err := sessionRepo.Touch(claims.UserID, claims.SessionID)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+
+		var stepUpAt int64
+		if session.StepUpAt != nil {
+			stepUpAt = session.StepUpAt.Unix()
+		}
+
 		// 将用户信息存入上下文
 		c.Set("user_id", claims.UserID)
 		c.Set("username", claims.Username)
+		c.Set("session_id", session.ID)
+		c.Set("step_up_at", stepUpAt)
+		c.Next()
+	}
+}
+
+// isAPIToken 当前请求是否使用 API Token 认证
+func isAPIToken(c *gin.Context) bool {
+	_, ok := c.Get("api_token_id")
+	return ok
+}
+
+// RequireScope 要求 API Token 具有指定权限范围（登录会话不受限制）
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if isAPIToken(c) && !slices.Contains(c.GetStringSlice("scopes"), scope) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API Token 缺少权限: " + scope})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
+
+// SessionOnly 仅允许登录会话访问（如修改密码、管理 API Token）
+func SessionOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if isAPIToken(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "该接口不允许使用 API Token 访问"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// AdminOnly 仅允许管理员访问（角色每次从数据库读取，降级后立即生效）
+func AdminOnly() gin.HandlerFunc {
+	userRepo := repository.NewUserRepository()
+
+	return func(c *gin.Context) {
+		user, err := userRepo.GetByID(c.GetInt64("user_id"))
+		if err != nil || user.Role != model.RoleAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "需要管理员权限"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireStepUp 开启两步验证的用户需在 StepUpTTL 内通过二次验证才能访问（如查看凭证密码）
+// API Token 只能由通过两步验证的登录会话创建，并受权限范围限制，不要求二次验证
+func RequireStepUp() gin.HandlerFunc {
+	userRepo := repository.NewUserRepository()
+
+	return func(c *gin.Context) {
+		if isAPIToken(c) {
+			c.Next()
+			return
+		}
+
+		user, err := userRepo.GetByID(c.GetInt64("user_id"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "用户不存在"})
+			c.Abort()
+			return
+		}
+
+		stepUpAt := time.Unix(c.GetInt64("step_up_at"), 0)
+		if user.TOTPEnabled && time.Since(stepUpAt) > StepUpTTL {
+			c.JSON(http.StatusForbidden, gin.H{"error": "需要二次验证", "step_up_required": true})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireVaultUnlocked 要求当前会话的保险库已解锁（读写凭证密码），同时顺延自动锁定时间；
+// API Token 请求要求该用户有已解锁的会话，不顺延自动锁定时间
+func RequireVaultUnlocked() gin.HandlerFunc {
+	vaultRepo := repository.NewVaultRepository()
+
+	return func(c *gin.Context) {
+		userID := c.GetInt64("user_id")
+		if util.TouchVault(userID, c.GetInt64("session_id")) {
+			c.Next()
+			return
+		}
+
+		if !vaultRepo.Initialized(userID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": repository.ErrVaultNotInitialized.Error(), "vault_setup_required": true})
+			c.Abort()
+			return
+		}
+		c.JSON(http.StatusLocked, gin.H{"error": util.ErrVaultLocked.Error(), "vault_locked": true})
+		c.Abort()
+	}
+}
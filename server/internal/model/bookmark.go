@@ -12,6 +12,10 @@ type Bookmark struct {
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 	Tags        []Tag     `json:"tags,omitempty"`
+
+	// 搜索结果高亮（仅全文搜索时返回，匹配部分用 <mark> 包裹）
+	TitleHighlight string `json:"title_highlight,omitempty"`
+	Snippet        string `json:"snippet,omitempty"`
 }
 
 type BookmarkCreateRequest struct {
@@ -38,7 +42,7 @@ type BookmarkListRequest struct {
 	Search       string `form:"search"`
 	FolderPath   string `form:"folder_path"`
 	FilterFolder bool   `form:"filter_folder"`
-	SortBy       string `form:"sort_by"`
+	SortBy       string `form:"sort_by"` // title_asc, title_desc, time_asc, time_desc, url_asc, url_desc, relevance
 }
 
 type BookmarkListResponse struct {
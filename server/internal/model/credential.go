@@ -2,28 +2,260 @@ package model
 
 import "time"
 
+// 凭证类型
+const (
+	CredentialTypeLogin = "login" // 网站登录
+	CredentialTypeNote  = "note"  // 安全笔记，不关联域名，只有标题、备注和自定义字段
+)
+
+// 自定义字段类型
+const (
+	FieldTypeText   = "text"
+	FieldTypeHidden = "hidden" // 加密保存，界面默认隐藏
+	FieldTypeURL    = "url"
+	FieldTypeEmail  = "email"
+)
+
+// 网址匹配规则，决定哪些网页能匹配到凭证（GET /api/credentials/match）
+const (
+	MatchBaseDomain = "domain"      // 顶级域名相同（默认）
+	MatchHost       = "host"        // 主机名与凭证域名完全相同
+	MatchStartsWith = "starts_with" // 网页地址以 MatchPattern 开头
+	MatchRegex      = "regex"       // 网页地址匹配正则表达式 MatchPattern
+	MatchNever      = "never"       // 从不匹配
+)
+
+// 匹配质量，数值越大越精确
+const (
+	MatchQualityBaseDomain = 1 // 只有顶级域名相同，或顶级域名属于同一等价域名组
+	MatchQualitySubdomain  = 2 // 网页主机名与凭证域名互为子域名
+	MatchQualityHost       = 3 // 主机名相同
+	MatchQualityPattern    = 4 // 匹配 starts_with 或 regex 规则
+)
+
 type Credential struct {
-	ID        int64     `json:"id"`
-	Domain    string    `json:"domain"`
-	Title     string    `json:"title"`
-	Username  string    `json:"username"`
-	Password  string    `json:"password"` // 解密后的密码
-	Notes     string    `json:"notes"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        int64             `json:"id"`
+	Type      string            `json:"type"`
+	Domain    string            `json:"domain"` // 安全笔记为空
+	Title     string            `json:"title"`
+	Username  string            `json:"username"`
+	Password  string            `json:"password"` // 解密后的密码
+	Notes     string            `json:"notes"`    // 解密后的备注
+	Fields    []CredentialField `json:"fields"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+	// 当前密码的设置时间，修改标题、备注等不影响
+	PasswordChangedAt time.Time `json:"password_changed_at"`
+
+	// 网址匹配规则，MatchPattern 为 starts_with 的网址前缀或 regex 的正则表达式
+	MatchRule    string `json:"match_rule"`
+	MatchPattern string `json:"match_pattern"`
+
+	// 解密后的 TOTP 密钥（otpauth:// URI 或 Base32），未设置时为空
+	TOTPSecret string `json:"totp_secret"`
+
+	// 密码强度评分（0-4），空密码或尚未评估时为空
+	PasswordStrength *int `json:"password_strength"`
+	// 密码在本地泄露数据集中出现的次数，大于 0 表示已泄露；未导入数据集时为空
+	BreachCount *int `json:"breach_count"`
+
+	// 密码无法解密（密钥已丢失或数据损坏）时为 true，Password 为空
+	DecryptFailed bool `json:"decrypt_failed,omitempty"`
+	// TOTP 密钥无法解密时为 true，TOTPSecret 为空
+	TOTPDecryptFailed bool `json:"totp_decrypt_failed,omitempty"`
+	// 备注无法解密时为 true，Notes 为空
+	NotesDecryptFailed bool `json:"notes_decrypt_failed,omitempty"`
+}
+
+// CredentialField 自定义字段
+type CredentialField struct {
+	Name  string `json:"name" binding:"required,max=100"`
+	Type  string `json:"type" binding:"required,oneof=text hidden url email"`
+	Value string `json:"value"`
+	// hidden 字段的值无法解密时为 true，Value 为空
+	DecryptFailed bool `json:"decrypt_failed,omitempty"`
 }
 
 type CredentialCreateRequest struct {
-	Domain   string `json:"domain" binding:"required"`
-	Title    string `json:"title"`
-	Username string `json:"username"`
-	Password string `json:"password"`
-	Notes    string `json:"notes"`
+	Type       string            `json:"type" binding:"omitempty,oneof=login note"` // 默认为 login
+	Domain     string            `json:"domain"`                                    // login 必填
+	Title      string            `json:"title"`                                     // note 必填
+	Username   string            `json:"username"`
+	Password   string            `json:"password"`
+	Notes      string            `json:"notes"`
+	TOTPSecret string            `json:"totp_secret"` // otpauth:// URI 或 Base32 密钥
+	Fields     []CredentialField `json:"fields" binding:"max=50,dive"`
+	// 默认为 domain；starts_with、regex 需要 match_pattern
+	MatchRule    string `json:"match_rule" binding:"omitempty,oneof=domain host starts_with regex never"`
+	MatchPattern string `json:"match_pattern" binding:"max=2048"`
 }
 
 type CredentialUpdateRequest struct {
+	Title      string             `json:"title"`
+	Username   string             `json:"username"`
+	Password   string             `json:"password"`
+	Notes      string             `json:"notes"`
+	TOTPSecret string             `json:"totp_secret"`
+	RemoveTOTP bool               `json:"remove_totp"`                            // 删除已保存的 TOTP 密钥
+	Fields     *[]CredentialField `json:"fields" binding:"omitempty,max=50,dive"` // 为空时保持不变，提供时整体替换
+	// 为空时保持不变
+	MatchRule    string `json:"match_rule" binding:"omitempty,oneof=domain host starts_with regex never"`
+	MatchPattern string `json:"match_pattern" binding:"max=2048"`
+}
+
+type CredentialMatchRequest struct {
+	URL string `form:"url" binding:"required,max=4096"` // 当前网页的完整地址
+}
+
+// CredentialMatch 匹配到的凭证，按匹配质量从高到低排列
+type CredentialMatch struct {
+	Credential
+	Match   string `json:"match"`   // 匹配方式：pattern、host、subdomain、domain、equivalent
+	Quality int    `json:"quality"` // 匹配质量，见 MatchQuality*
+}
+
+// CredentialHistoryEntry 历史密码，列表中不包含密码
+type CredentialHistoryEntry struct {
+	ID         int64     `json:"id"`
+	Password   string    `json:"password,omitempty"` // 只在查看单条历史密码时返回
+	ValidFrom  time.Time `json:"valid_from"`         // 设置时间
+	ReplacedAt time.Time `json:"replaced_at"`        // 被替换的时间
+	// 查看时无法解密为 true
+	DecryptFailed bool `json:"decrypt_failed,omitempty"`
+}
+
+// CredentialRestoreRequest 恢复历史密码：指定历史记录 ID，或恢复到某个时间点正在使用的密码
+type CredentialRestoreRequest struct {
+	HistoryID int64      `json:"history_id"`
+	At        *time.Time `json:"at"`
+}
+
+// CredentialTOTPCode 凭证当前的 TOTP 验证码
+type CredentialTOTPCode struct {
+	Code      string `json:"code"`
+	Remaining int    `json:"remaining"` // 剩余有效秒数
+	Period    int    `json:"period"`
+	Digits    int    `json:"digits"`
+}
+
+// 凭证导入预览中每一条的处理结果
+const (
+	CredentialImportNew       = "new"       // 将被导入（预览）或已导入
+	CredentialImportDuplicate = "duplicate" // 与已有凭证或文件中前面的条目重复（域名 + 用户名）
+	CredentialImportInvalid   = "invalid"   // 缺少网址等原因无法导入
+)
+
+// ImportCredential 从导出文件中解析出的一条凭证
+type ImportCredential struct {
+	Type     string
+	URL      string
+	Domain   string
+	Title    string
+	Username string
+	Password string
+	Notes    string
+	TOTP     string // otpauth:// URI 或 Base32 密钥
+	Fields   []CredentialField
+	// 网址匹配规则，为空时使用默认规则
+	MatchRule    string
+	MatchPattern string
+	Invalid      string // 非空时表示无法导入的原因
+	Warning      string // 非空时表示导入时忽略的内容（如无法识别的 TOTP 密钥），其余内容正常导入
+}
+
+type CredentialImportRequest struct {
+	Preview bool `form:"preview"` // 只返回预览结果，不写入数据库
+}
+
+// CredentialImportItem 导入结果中的一条，不包含密码
+type CredentialImportItem struct {
+	Type     string `json:"type"`
+	Domain   string `json:"domain"`
 	Title    string `json:"title"`
 	Username string `json:"username"`
-	Password string `json:"password"`
-	Notes    string `json:"notes"`
+	Status   string `json:"status"`
+	Reason   string `json:"reason,omitempty"`
+	Warning  string `json:"warning,omitempty"`
+}
+
+type CredentialImportResult struct {
+	Format     string                 `json:"format"` // archive, bitwarden, bitwarden_csv, keepass_xml, keepass_csv, chrome_csv, firefox_csv, csv
+	Preview    bool                   `json:"preview"`
+	Total      int                    `json:"total"`
+	Imported   int                    `json:"imported"` // 预览时为将要导入的数量
+	Duplicates int                    `json:"duplicates"`
+	Invalid    int                    `json:"invalid"`
+	Items      []CredentialImportItem `json:"items"`
+}
+
+// 凭证导出格式
+const (
+	CredentialExportEncrypted = "encrypted" // 用备份密码加密的备份文件，可重新导入
+	CredentialExportBitwarden = "bitwarden" // Bitwarden 兼容的未加密 JSON
+	CredentialExportCSV       = "csv"       // Bitwarden 兼容的未加密 CSV
+)
+
+type CredentialExportRequest struct {
+	Format         string `json:"format" binding:"required,oneof=encrypted bitwarden csv"`
+	Passphrase     string `json:"passphrase"`      // 加密备份使用的备份密码
+	MasterPassword string `json:"master_password"` // 导出未加密文件前需要再次输入主密码
+}
+
+// CredentialArchive 加密备份文件中的内容
+type CredentialArchive struct {
+	Version     int                 `json:"version"`
+	ExportedAt  time.Time           `json:"exported_at"`
+	Credentials []ArchiveCredential `json:"credentials"`
+}
+
+type ArchiveCredential struct {
+	Type         string            `json:"type,omitempty"` // 早期备份文件没有类型，按 login 处理
+	Domain       string            `json:"domain"`
+	Title        string            `json:"title"`
+	Username     string            `json:"username"`
+	Password     string            `json:"password"`
+	Notes        string            `json:"notes"`
+	TOTP         string            `json:"totp,omitempty"`
+	Fields       []CredentialField `json:"fields,omitempty"`
+	MatchRule    string            `json:"match_rule,omitempty"`
+	MatchPattern string            `json:"match_pattern,omitempty"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+}
+
+type CredentialHealthRequest struct {
+	MaxAgeDays int `form:"max_age_days" binding:"omitempty,min=1,max=3650"` // 超过多少天未修改视为旧密码，默认 365
+}
+
+// CredentialHealthItem 有问题的凭证，不包含密码
+type CredentialHealthItem struct {
+	ID          int64     `json:"id"`
+	Domain      string    `json:"domain"`
+	Title       string    `json:"title"`
+	Username    string    `json:"username"`
+	Strength    int       `json:"strength"`
+	Weak        bool      `json:"weak"`
+	BreachCount int       `json:"breach_count"` // 在泄露数据集中出现的次数
+	ReusedCount int       `json:"reused_count"` // 使用相同密码的其他凭证数量
+	Old         bool      `json:"old"`
+	AgeDays     int       `json:"age_days"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type CredentialHealthGroup struct {
+	TopDomain   string                 `json:"top_domain"`
+	Credentials []CredentialHealthItem `json:"credentials"`
+}
+
+// CredentialHealthReport 保险库健康报告，Weak、Breached、Reused、Old 为对应问题的凭证数量
+type CredentialHealthReport struct {
+	Total          int                     `json:"total"`
+	Weak           int                     `json:"weak"`
+	Breached       int                     `json:"breached"`
+	BreachesLoaded bool                    `json:"breaches_loaded"` // 是否已导入泄露数据集
+	Reused         int                     `json:"reused"`
+	Old            int                     `json:"old"`
+	DecryptFailed  int                     `json:"decrypt_failed"`
+	MaxAgeDays     int                     `json:"max_age_days"`
+	Groups         []CredentialHealthGroup `json:"groups"`
 }
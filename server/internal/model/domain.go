@@ -6,9 +6,51 @@ type DomainGroup struct {
 	SubDomains     []string `json:"sub_domains"`
 	BookmarkCount  int      `json:"bookmark_count"`
 	HasCredentials bool     `json:"has_credentials"`
+
+	// 合并等价域名组时其他的顶级域名
+	EquivalentDomains []string `json:"equivalent_domains,omitempty"`
 }
 
-// DomainListResponse 域名列表响应
+// DomainListResponse 域名列表响应（分页参数只在分页时返回）
 type DomainListResponse struct {
-	Domains []DomainGroup `json:"domains"`
+	Domains  []DomainGroup `json:"domains"`
+	Total    int           `json:"total,omitempty"`
+	Page     int           `json:"page,omitempty"`
+	PageSize int           `json:"page_size,omitempty"`
+}
+
+// DomainListRequest 域名列表参数，不提供 page_size 时返回全部
+type DomainListRequest struct {
+	// 为 true 时把同一等价域名组的顶级域名合并为一项
+	Collapse bool `form:"collapse"`
+	Page     int  `form:"page" binding:"omitempty,min=1"`
+	PageSize int  `form:"page_size" binding:"omitempty,min=1,max=500"`
+}
+
+// DomainBookmarkListRequest 域名下的书签列表参数
+type DomainBookmarkListRequest struct {
+	Page     int `form:"page" binding:"omitempty,min=1"`
+	PageSize int `form:"page_size" binding:"omitempty,min=1,max=100"`
+}
+
+// EquivalentDomainGroup 等价域名组，组内的顶级域名共用登录凭证
+type EquivalentDomainGroup struct {
+	ID       int64    `json:"id,omitempty"`  // 自定义组
+	Key      string   `json:"key,omitempty"` // 内置组
+	Domains  []string `json:"domains"`
+	Excluded bool     `json:"excluded"` // 内置组已停用
+}
+
+// EquivalentDomainList 内置和自定义的等价域名组
+type EquivalentDomainList struct {
+	Global []EquivalentDomainGroup `json:"global"`
+	Custom []EquivalentDomainGroup `json:"custom"`
+}
+
+type EquivalentDomainRequest struct {
+	Domains []string `json:"domains" binding:"required,min=2,max=50,dive,required,max=253"`
+}
+
+type EquivalentDomainExcludeRequest struct {
+	Excluded bool `json:"excluded"`
 }
@@ -0,0 +1,18 @@
+package model
+
+// FaviconURLPrefix 服务器缓存的网站图标地址前缀，后接主机名
+const FaviconURLPrefix = "/api/favicons/host/"
+
+// Favicon 去重保存的图标内容
+type Favicon struct {
+	Hash        string // 内容的 SHA-256（十六进制）
+	ContentType string
+	Data        []byte
+}
+
+// FaviconHost 等待获取图标的主机
+type FaviconHost struct {
+	Host     string
+	Attempts int  // 连续失败次数
+	HasIcon  bool // 已有图标（定期刷新）
+}
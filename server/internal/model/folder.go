@@ -5,9 +5,16 @@ type FolderNode struct {
 	Name     string       `json:"name"`
 	Path     string       `json:"path"`
 	Count    int          `json:"count"`
+	Pinyin   string       `json:"pinyin,omitempty"` // 路径拼音索引，用于按拼音筛选
 	Children []FolderNode `json:"children,omitempty"`
 }
 
+// FolderOption 文件夹选项（用于 bookmarklet 文件夹选择器）
+type FolderOption struct {
+	Path   string `json:"path"`
+	Pinyin string `json:"pinyin,omitempty"`
+}
+
 type FolderCreateRequest struct {
 	Path string `json:"path" binding:"required"`
 }
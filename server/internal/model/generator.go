@@ -0,0 +1,22 @@
+package model
+
+// PasswordGenerateRequest 密码生成选项，未指定的字符类型默认全部启用
+type PasswordGenerateRequest struct {
+	Mode             string  `form:"mode" binding:"omitempty,oneof=random pronounceable passphrase"`
+	Length           int     `form:"length" binding:"omitempty,min=8,max=128"` // 默认 20
+	Lowercase        *bool   `form:"lowercase"`
+	Uppercase        *bool   `form:"uppercase"`
+	Digits           *bool   `form:"digits"`
+	Symbols          *bool   `form:"symbols"`
+	ExcludeAmbiguous bool    `form:"exclude_ambiguous"`                      // 排除 Il1O0o 等容易混淆的字符
+	Words            int     `form:"words" binding:"omitempty,min=3,max=20"` // 密码短语单词数，默认 5
+	Separator        *string `form:"separator" binding:"omitempty,max=3"`    // 密码短语分隔符，默认 -
+	Capitalize       bool    `form:"capitalize"`
+	IncludeNumber    bool    `form:"include_number"`
+}
+
+type PasswordStrengthRequest struct {
+	Password string `json:"password" binding:"required"`
+	Username string `json:"username"`
+	Domain   string `json:"domain"`
+}
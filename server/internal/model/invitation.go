@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// Invitation 邀请码（不含明文和哈希）
+type Invitation struct {
+	ID        int64      `json:"id"`
+	Prefix    string     `json:"prefix"`
+	CreatedBy int64      `json:"created_by"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	UsedBy    *int64     `json:"used_by"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type InvitationCreateRequest struct {
+	ExpiresInDays int `json:"expires_in_days" binding:"min=0"` // 0 表示永不过期
+}
+
+// InvitationCreateResponse 创建结果，明文邀请码只在此时返回一次
+type InvitationCreateResponse struct {
+	Invitation
+	Code string `json:"code"`
+}
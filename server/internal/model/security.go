@@ -0,0 +1,39 @@
+package model
+
+import "time"
+
+// 安全事件类型
+const (
+	SecurityEventLoginSucceeded = "login_succeeded"
+	SecurityEventLoginFailed    = "login_failed"        // 用户名或密码错误
+	SecurityEventTOTPFailed     = "totp_failed"         // 两步验证码错误（登录、二次验证）
+	SecurityEventVaultFailed    = "vault_unlock_failed" // 主密码错误
+	SecurityEventAccountLocked  = "account_locked"      // 连续失败次数达到上限，账号临时锁定
+	SecurityEventPasswordChange = "password_changed"
+	SecurityEventTOTPEnabled    = "totp_enabled"
+	SecurityEventTOTPDisabled   = "totp_disabled"
+	SecurityEventCredExport     = "credentials_exported" // 导出凭证（含加密备份）
+)
+
+type SecurityEvent struct {
+	ID        int64     `json:"id"`
+	UserID    *int64    `json:"user_id"` // 登录失败且用户不存在时为空
+	Username  string    `json:"username"`
+	Event     string    `json:"event"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type SecurityEventListRequest struct {
+	Page     int  `form:"page" binding:"min=1"`
+	PageSize int  `form:"page_size" binding:"min=1,max=100"`
+	All      bool `form:"all"` // 管理员查看所有用户的事件（含不存在的用户名）
+}
+
+type SecurityEventListResponse struct {
+	Events   []SecurityEvent `json:"events"`
+	Total    int             `json:"total"`
+	Page     int             `json:"page"`
+	PageSize int             `json:"page_size"`
+}
@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// Session 登录会话（不含刷新 Token）
+type Session struct {
+	ID         int64     `json:"id"`
+	UserID     int64     `json:"-"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"` // 是否为发起请求的会话
+
+	StepUpAt *time.Time `json:"-"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
@@ -5,6 +5,9 @@ type Tag struct {
 	Name  string `json:"name"`
 	Color string `json:"color"`
 	Count int    `json:"count,omitempty"`
+
+	// 拼音索引（全拼 + 首字母），便于前端按拼音筛选
+	Pinyin string `json:"pinyin,omitempty"`
 }
 
 type TagCreateRequest struct {
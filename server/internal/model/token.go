@@ -0,0 +1,38 @@
+package model
+
+import "time"
+
+// API Token 权限范围
+const (
+	ScopeBookmarksRead    = "bookmarks:read"    // 读取书签、文件夹、标签、域名
+	ScopeBookmarksWrite   = "bookmarks:write"   // 修改书签、文件夹、标签，导入书签
+	ScopeCredentialsRead  = "credentials:read"  // 读取凭证（含解密后的密码）
+	ScopeCredentialsWrite = "credentials:write" // 修改凭证
+)
+
+// AllScopes 所有可用的权限范围
+var AllScopes = []string{ScopeBookmarksRead, ScopeBookmarksWrite, ScopeCredentialsRead, ScopeCredentialsWrite}
+
+// APIToken 个人 API Token（不含明文和哈希）
+type APIToken struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"-"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"` // Token 开头几位，便于识别
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type APITokenCreateRequest struct {
+	Name          string   `json:"name" binding:"required,max=100"`
+	Scopes        []string `json:"scopes" binding:"required,min=1,dive,oneof=bookmarks:read bookmarks:write credentials:read credentials:write"`
+	ExpiresInDays int      `json:"expires_in_days" binding:"min=0"` // 0 表示永不过期
+}
+
+// APITokenCreateResponse 创建结果，明文 Token 只在此时返回一次
+type APITokenCreateResponse struct {
+	APIToken
+	Token string `json:"token"`
+}
@@ -0,0 +1,46 @@
+package model
+
+import "time"
+
+// 回收站条目类型
+const (
+	TrashTypeBookmark   = "bookmark"
+	TrashTypeFolder     = "folder"
+	TrashTypeCredential = "credential"
+)
+
+// TrashItem 回收站条目
+// 删除文件夹时，其中的子文件夹和书签随文件夹作为一个条目显示，恢复时一起恢复
+type TrashItem struct {
+	Type       string     `json:"type"`
+	ID         int64      `json:"id"`
+	Title      string     `json:"title"`                 // 书签标题 / 文件夹名称 / 凭证标题
+	Detail     string     `json:"detail"`                // 书签网址 / 文件夹完整路径 / 凭证域名
+	FolderPath string     `json:"folder_path,omitempty"` // 书签和文件夹删除前所在的文件夹
+	ItemCount  int        `json:"item_count,omitempty"`  // 文件夹中随之删除的书签数量
+	DeletedAt  time.Time  `json:"deleted_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"` // 到期后自动永久删除（未启用自动清理时为空）
+}
+
+type TrashListRequest struct {
+	Page     int    `form:"page" binding:"min=1"`
+	PageSize int    `form:"page_size" binding:"min=1,max=100"`
+	Type     string `form:"type" binding:"omitempty,oneof=bookmark folder credential"`
+}
+
+type TrashListResponse struct {
+	Items    []TrashItem `json:"items"`
+	Total    int         `json:"total"`
+	Page     int         `json:"page"`
+	PageSize int         `json:"page_size"`
+}
+
+// TrashItemRef 回收站条目引用（用于恢复和永久删除）
+type TrashItemRef struct {
+	Type string `json:"type" binding:"required,oneof=bookmark folder credential"`
+	ID   int64  `json:"id" binding:"required"`
+}
+
+type TrashBatchRequest struct {
+	Items []TrashItemRef `json:"items" binding:"required,min=1,dive"`
+}
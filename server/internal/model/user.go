@@ -2,21 +2,72 @@ package model
 
 import "time"
 
+// 用户角色
+const (
+	RoleAdmin = "admin" // 管理员：可以创建邀请码
+	RoleUser  = "user"
+)
+
+// DefaultUsername 首次启动时创建的管理员用户名
+const DefaultUsername = "admin"
+
 type User struct {
-	ID        int64     `json:"id"`
-	Username  string    `json:"username"`
-	Password  string    `json:"-"` // 不输出到 JSON
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID          int64     `json:"id"`
+	Username    string    `json:"username"`
+	Password    string    `json:"-"` // 不输出到 JSON
+	Role        string    `json:"role"`
+	TOTPEnabled bool      `json:"totp_enabled"` // 是否已开启两步验证
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
 }
 
 type LoginRequest struct {
+	Username string `json:"username"` // 为空时使用默认管理员，兼容旧版客户端
 	Password string `json:"password" binding:"required"`
 }
 
+type RegisterRequest struct {
+	Username   string `json:"username" binding:"required,min=2,max=32"`
+	Password   string `json:"password" binding:"required,min=4"`
+	InviteCode string `json:"invite_code"`
+}
+
 type LoginResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
+	Token        string `json:"token"`         // 访问 Token，有效期较短
+	RefreshToken string `json:"refresh_token"` // 用于 /auth/refresh 换取新的访问 Token
+	ExpiresIn    int    `json:"expires_in"`    // 访问 Token 有效期（秒）
+	User         User   `json:"user"`
+}
+
+// LoginChallengeResponse 已开启两步验证时密码校验通过后的响应，需用 challenge 和验证码完成登录
+type LoginChallengeResponse struct {
+	TOTPRequired bool   `json:"totp_required"`
+	Challenge    string `json:"challenge"`
+}
+
+type LoginTOTPRequest struct {
+	Challenge string `json:"challenge" binding:"required"`
+	Code      string `json:"code" binding:"required"` // TOTP 验证码或恢复码
+}
+
+// TOTPCodeRequest 提交验证码（开启两步验证、二次验证）
+type TOTPCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type TOTPDisableRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type TOTPSetupResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"` // 生成二维码供验证器 App 扫描
+}
+
+type TOTPStatusResponse struct {
+	Enabled                bool `json:"enabled"`
+	RecoveryCodesRemaining int  `json:"recovery_codes_remaining"`
 }
 
 type ChangePasswordRequest struct {
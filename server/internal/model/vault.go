@@ -0,0 +1,41 @@
+package model
+
+import "time"
+
+type VaultStatusResponse struct {
+	Initialized bool       `json:"initialized"` // 是否已设置主密码
+	Unlocked    bool       `json:"unlocked"`
+	LocksAt     *time.Time `json:"locks_at"` // 无操作时自动锁定的时间，为空表示不自动锁定
+}
+
+type VaultSetupRequest struct {
+	MasterPassword string `json:"master_password" binding:"required,min=8"`
+}
+
+type VaultUnlockRequest struct {
+	MasterPassword string `json:"master_password" binding:"required"`
+}
+
+type VaultChangePasswordRequest struct {
+	OldMasterPassword string `json:"old_master_password" binding:"required"`
+	NewMasterPassword string `json:"new_master_password" binding:"required,min=8"`
+}
+
+type VaultRotateRequest struct {
+	MasterPassword string `json:"master_password" binding:"required"`
+}
+
+// DecryptFailure 无法解密的数据（密钥不存在或密文损坏），保持原样不做修改
+type DecryptFailure struct {
+	Table string `json:"table"`
+	ID    int64  `json:"id"`
+	Error string `json:"error"`
+}
+
+// KeyRotationReport 重新加密的结果
+type KeyRotationReport struct {
+	KeyID       string           `json:"key_id"`      // 新的当前密钥 ID
+	Reencrypted int              `json:"reencrypted"` // 重新加密的条数
+	Failed      []DecryptFailure `json:"failed"`
+	RetiredKeys []string         `json:"retired_keys"` // 已不再被使用而删除的旧密钥
+}
@@ -3,9 +3,17 @@ package repository
 import (
 	"Nibstash_v2_server/database"
 	"Nibstash_v2_server/internal/model"
+	"Nibstash_v2_server/internal/search"
+	"Nibstash_v2_server/internal/util"
 	"strings"
 )
 
+// 书签查询的公共列和 FROM 子句（文件夹路径由 folder_paths 视图拼接）
+const (
+	bookmarkSelectColumns = "b.id, b.url, b.title, b.description, COALESCE(fp.path, ''), b.favicon, b.created_at, b.updated_at"
+	bookmarkFromClause    = "bookmarks b LEFT JOIN folder_paths fp ON fp.id = b.folder_id"
+)
+
 type BookmarkRepository struct {
 	tagRepo    *TagRepository
 	domainRepo *DomainRepository
@@ -18,11 +26,17 @@ func NewBookmarkRepository() *BookmarkRepository {
 	}
 }
 
-func (r *BookmarkRepository) Create(url, title, description, favicon, folderPath string, tagIDs []int64) (*model.Bookmark, error) {
+func (r *BookmarkRepository) Create(userID int64, url, title, description, favicon, folderPath string, tagIDs []int64) (*model.Bookmark, error) {
+	folderID, err := ensureFolderPath(database.DB, userID, folderPath)
+	if err != nil {
+		return nil, err
+	}
+
+	host, topDomain := bookmarkDomain(url)
 	result, err := database.DB.Exec(`
-		INSERT INTO bookmarks (url, title, description, folder_path, favicon)
-		VALUES (?, ?, ?, ?, ?)
-	`, url, title, description, folderPath, favicon)
+		INSERT INTO bookmarks (user_id, url, title, description, folder_id, favicon, title_pinyin, host, top_domain)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, userID, url, title, description, nullableFolderID(folderID), favicon, util.PinyinIndex(title), host, topDomain)
 	if err != nil {
 		return nil, err
 	}
@@ -33,22 +47,30 @@ func (r *BookmarkRepository) Create(url, title, description, favicon, folderPath
 	}
 
 	// 关联标签
-	for _, tagID := range tagIDs {
-		database.DB.Exec(`INSERT OR IGNORE INTO bookmark_tags (bookmark_id, tag_id) VALUES (?, ?)`, id, tagID)
-	}
+	addBookmarkTags(userID, id, tagIDs)
 
 	// 同步添加域名到 domains 表
-	r.domainRepo.AddDomain(url)
+	r.domainRepo.AddDomain(userID, url)
 
-	return r.GetByID(id)
+	return r.GetByID(userID, id)
 }
 
-func (r *BookmarkRepository) GetByID(id int64) (*model.Bookmark, error) {
+// addBookmarkTags 关联标签，忽略不属于该用户的标签
+func addBookmarkTags(userID, bookmarkID int64, tagIDs []int64) {
+	for _, tagID := range tagIDs {
+		database.DB.Exec(`
+			INSERT OR IGNORE INTO bookmark_tags (bookmark_id, tag_id)
+			SELECT ?, id FROM tags WHERE id = ? AND user_id = ?
+		`, bookmarkID, tagID, userID)
+	}
+}
+
+func (r *BookmarkRepository) GetByID(userID, id int64) (*model.Bookmark, error) {
 	bookmark := &model.Bookmark{}
 	err := database.DB.QueryRow(`
-		SELECT id, url, title, description, folder_path, favicon, created_at, updated_at
-		FROM bookmarks WHERE id = ?
-	`, id).Scan(&bookmark.ID, &bookmark.URL, &bookmark.Title, &bookmark.Description,
+		SELECT `+bookmarkSelectColumns+`
+		FROM `+bookmarkFromClause+` WHERE b.id = ? AND b.user_id = ? AND b.deleted_at IS NULL
+	`, id, userID).Scan(&bookmark.ID, &bookmark.URL, &bookmark.Title, &bookmark.Description,
 		&bookmark.FolderPath, &bookmark.Favicon, &bookmark.CreatedAt, &bookmark.UpdatedAt)
 	if err != nil {
 		return nil, err
@@ -58,12 +80,12 @@ func (r *BookmarkRepository) GetByID(id int64) (*model.Bookmark, error) {
 	return bookmark, nil
 }
 
-func (r *BookmarkRepository) GetByURL(url string) (*model.Bookmark, error) {
+func (r *BookmarkRepository) GetByURL(userID int64, url string) (*model.Bookmark, error) {
 	bookmark := &model.Bookmark{}
 	err := database.DB.QueryRow(`
-		SELECT id, url, title, description, folder_path, favicon, created_at, updated_at
-		FROM bookmarks WHERE url = ?
-	`, url).Scan(&bookmark.ID, &bookmark.URL, &bookmark.Title, &bookmark.Description,
+		SELECT `+bookmarkSelectColumns+`
+		FROM `+bookmarkFromClause+` WHERE b.url = ? AND b.user_id = ? AND b.deleted_at IS NULL
+	`, url, userID).Scan(&bookmark.ID, &bookmark.URL, &bookmark.Title, &bookmark.Description,
 		&bookmark.FolderPath, &bookmark.Favicon, &bookmark.CreatedAt, &bookmark.UpdatedAt)
 	if err != nil {
 		return nil, err
@@ -73,67 +95,93 @@ func (r *BookmarkRepository) GetByURL(url string) (*model.Bookmark, error) {
 	return bookmark, nil
 }
 
-func (r *BookmarkRepository) Update(id int64, url, title, description string, tagIDs []int64) error {
-	_, err := database.DB.Exec(`
-		UPDATE bookmarks SET url = ?, title = ?, description = ?, updated_at = CURRENT_TIMESTAMP
-		WHERE id = ?
-	`, url, title, description, id)
+func (r *BookmarkRepository) Update(userID, id int64, url, title, description string, tagIDs []int64) error {
+	host, topDomain := bookmarkDomain(url)
+	result, err := database.DB.Exec(`
+		UPDATE bookmarks SET url = ?, title = ?, description = ?, title_pinyin = ?, host = ?, top_domain = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND user_id = ? AND deleted_at IS NULL
+	`, url, title, description, util.PinyinIndex(title), host, topDomain, id, userID)
 	if err != nil {
 		return err
 	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return nil
+	}
+
+	// 网址可能改为新的域名
+	r.domainRepo.AddDomain(userID, url)
 
 	// 更新标签关联
 	database.DB.Exec(`DELETE FROM bookmark_tags WHERE bookmark_id = ?`, id)
-	for _, tagID := range tagIDs {
-		database.DB.Exec(`INSERT OR IGNORE INTO bookmark_tags (bookmark_id, tag_id) VALUES (?, ?)`, id, tagID)
-	}
+	addBookmarkTags(userID, id, tagIDs)
 
 	return nil
 }
 
-func (r *BookmarkRepository) Delete(id int64) error {
-	_, err := database.DB.Exec(`DELETE FROM bookmarks WHERE id = ?`, id)
+// Delete 将书签移入回收站
+func (r *BookmarkRepository) Delete(userID, id int64) error {
+	_, err := database.DB.Exec(`UPDATE bookmarks SET deleted_at = ? WHERE id = ? AND user_id = ? AND deleted_at IS NULL`, trashStamp(), id, userID)
 	return err
 }
 
-func (r *BookmarkRepository) DeleteByIDs(ids []int64) error {
+// DeleteByIDs 将多个书签移入回收站
+func (r *BookmarkRepository) DeleteByIDs(userID int64, ids []int64) error {
 	if len(ids) == 0 {
 		return nil
 	}
 	placeholders := strings.Repeat("?,", len(ids))
 	placeholders = placeholders[:len(placeholders)-1]
-	args := make([]interface{}, len(ids))
+	args := make([]interface{}, len(ids)+2)
+	args[0] = trashStamp()
+	args[1] = userID
 	for i, id := range ids {
-		args[i] = id
+		args[i+2] = id
 	}
-	_, err := database.DB.Exec(`DELETE FROM bookmarks WHERE id IN (`+placeholders+`)`, args...)
+	_, err := database.DB.Exec(`UPDATE bookmarks SET deleted_at = ? WHERE user_id = ? AND deleted_at IS NULL AND id IN (`+placeholders+`)`, args...)
 	return err
 }
 
-func (r *BookmarkRepository) List(page, pageSize int, tagID int64, search, folderPath string, filterFolder bool, sortBy string) ([]model.Bookmark, int, error) {
+// List 分页查询用户的书签，query 为解析后的搜索语句（可为 nil）
+func (r *BookmarkRepository) List(userID int64, page, pageSize int, tagID int64, query search.Node, folderPath string, filterFolder bool, sortBy string) ([]model.Bookmark, int, error) {
 	offset := (page - 1) * pageSize
 
-	var args []interface{}
-	whereClause := "1=1 AND b.url NOT LIKE 'nibstash://folder-placeholder/%'"
+	args := []interface{}{userID}
+	whereClause := "b.user_id = ? AND b.deleted_at IS NULL"
+
+	// 全文搜索：LEFT JOIN 全文索引获取相关度和高亮片段，过滤条件在 WHERE 中
+	joinClause := ""
+	var joinArgs []interface{}
+	ftsQuery := rankQuery(query)
+	useFTS := ftsQuery != ""
+	if useFTS {
+		joinClause = `
+		LEFT JOIN (
+			SELECT rowid, ` + ftsRankExpr + ` AS rank,
+				highlight(bookmarks_fts, 0, '` + highlightOpen + `', '` + highlightClose + `') AS title_hl,
+				snippet(bookmarks_fts, -1, '` + highlightOpen + `', '` + highlightClose + `', '…', 64) AS snippet
+			FROM bookmarks_fts WHERE bookmarks_fts MATCH ?
+		) f ON f.rowid = b.id`
+		joinArgs = append(joinArgs, ftsQuery)
+	}
 
 	if tagID > 0 {
 		whereClause += " AND b.id IN (SELECT bookmark_id FROM bookmark_tags WHERE tag_id = ?)"
 		args = append(args, tagID)
 	}
 
-	if search != "" {
-		whereClause += " AND (b.title LIKE ? OR b.url LIKE ? OR b.description LIKE ?)"
-		searchPattern := "%" + search + "%"
-		args = append(args, searchPattern, searchPattern, searchPattern)
+	if query != nil {
+		queryClause, queryArgs := compileQuery(query)
+		whereClause += " AND " + queryClause
+		args = append(args, queryArgs...)
 	}
 
 	if filterFolder {
-		if folderPath == "" {
-			whereClause += " AND b.folder_path = ?"
-			args = append(args, "")
+		if folderPath = normalizeFolderPath(folderPath); folderPath == "" {
+			whereClause += " AND b.folder_id IS NULL"
 		} else {
-			whereClause += " AND (b.folder_path = ? OR b.folder_path LIKE ?)"
-			args = append(args, folderPath, folderPath+"/%")
+			clause, folderArgs := folderSubtreeClause(folderPath)
+			whereClause += " AND " + clause
+			args = append(args, folderArgs...)
 		}
 	}
 
@@ -157,19 +205,28 @@ func (r *BookmarkRepository) List(page, pageSize int, tagID int64, search, folde
 		orderClause = "b.url ASC"
 	case "url_desc":
 		orderClause = "b.url DESC"
+	case "relevance":
+		if useFTS {
+			orderClause = "f.rank IS NULL, f.rank ASC, b.created_at DESC"
+		}
 	}
 
 	// 获取列表
-	query := `
-		SELECT b.id, b.url, b.title, b.description, b.folder_path, b.favicon, b.created_at, b.updated_at
-		FROM bookmarks b
+	highlightColumns := "'', ''"
+	if useFTS {
+		highlightColumns = "COALESCE(f.title_hl, ''), COALESCE(f.snippet, '')"
+	}
+	listQuery := `
+		SELECT ` + bookmarkSelectColumns + `, ` + highlightColumns + `
+		FROM ` + bookmarkFromClause + joinClause + `
 		WHERE ` + whereClause + `
 		ORDER BY ` + orderClause + `
 		LIMIT ? OFFSET ?
 	`
-	args = append(args, pageSize, offset)
+	listArgs := append(joinArgs, args...)
+	listArgs = append(listArgs, pageSize, offset)
 
-	rows, err := database.DB.Query(query, args...)
+	rows, err := database.DB.Query(listQuery, listArgs...)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -178,10 +235,15 @@ func (r *BookmarkRepository) List(page, pageSize int, tagID int64, search, folde
 	var bookmarks []model.Bookmark
 	for rows.Next() {
 		var b model.Bookmark
-		err := rows.Scan(&b.ID, &b.URL, &b.Title, &b.Description, &b.FolderPath, &b.Favicon, &b.CreatedAt, &b.UpdatedAt)
+		var titleHighlight, snippet string
+		err := rows.Scan(&b.ID, &b.URL, &b.Title, &b.Description, &b.FolderPath, &b.Favicon, &b.CreatedAt, &b.UpdatedAt, &titleHighlight, &snippet)
 		if err != nil {
 			continue
 		}
+		if strings.Contains(titleHighlight+snippet, highlightOpen) {
+			b.TitleHighlight = renderHighlight(titleHighlight)
+			b.Snippet = renderHighlight(snippet)
+		}
 		b.Tags, _ = r.tagRepo.GetByBookmarkID(b.ID)
 		bookmarks = append(bookmarks, b)
 	}
@@ -189,34 +251,45 @@ func (r *BookmarkRepository) List(page, pageSize int, tagID int64, search, folde
 	return bookmarks, total, nil
 }
 
-func (r *BookmarkRepository) Exists(url, folderPath string) bool {
+func (r *BookmarkRepository) Exists(userID int64, url, folderPath string) bool {
+	folderID, err := findFolderID(database.DB, userID, folderPath)
+	if err != nil {
+		return false
+	}
+
 	var count int
-	database.DB.QueryRow(`SELECT COUNT(*) FROM bookmarks WHERE url = ? AND folder_path = ?`, url, folderPath).Scan(&count)
+	database.DB.QueryRow(`SELECT COUNT(*) FROM bookmarks WHERE user_id = ? AND url = ? AND IFNULL(folder_id, 0) = ? AND deleted_at IS NULL`, userID, url, folderID).Scan(&count)
 	return count > 0
 }
 
-func (r *BookmarkRepository) MoveToFolder(ids []int64, targetFolder string) error {
+func (r *BookmarkRepository) MoveToFolder(userID int64, ids []int64, targetFolder string) error {
 	if len(ids) == 0 {
 		return nil
 	}
+	folderID, err := ensureFolderPath(database.DB, userID, targetFolder)
+	if err != nil {
+		return err
+	}
+
 	placeholders := strings.Repeat("?,", len(ids))
 	placeholders = placeholders[:len(placeholders)-1]
-	args := make([]interface{}, len(ids)+1)
-	args[0] = targetFolder
+	args := make([]interface{}, len(ids)+2)
+	args[0] = nullableFolderID(folderID)
+	args[1] = userID
 	for i, id := range ids {
-		args[i+1] = id
+		args[i+2] = id
 	}
-	_, err := database.DB.Exec(`UPDATE bookmarks SET folder_path = ?, updated_at = CURRENT_TIMESTAMP WHERE id IN (`+placeholders+`)`, args...)
+	_, err = database.DB.Exec(`UPDATE bookmarks SET folder_id = ?, updated_at = CURRENT_TIMESTAMP WHERE user_id = ? AND deleted_at IS NULL AND id IN (`+placeholders+`)`, args...)
 	return err
 }
 
-func (r *BookmarkRepository) UpdateFavicon(id int64, favicon string) error {
-	_, err := database.DB.Exec(`UPDATE bookmarks SET favicon = ? WHERE id = ?`, favicon, id)
+func (r *BookmarkRepository) UpdateFavicon(userID, id int64, favicon string) error {
+	_, err := database.DB.Exec(`UPDATE bookmarks SET favicon = ? WHERE id = ? AND user_id = ?`, favicon, id, userID)
 	return err
 }
 
-func (r *BookmarkRepository) GetWithoutFavicon() ([]model.Bookmark, error) {
-	rows, err := database.DB.Query(`SELECT id, url FROM bookmarks WHERE (favicon = '' OR favicon IS NULL) AND url NOT LIKE 'nibstash://folder-placeholder/%'`)
+func (r *BookmarkRepository) GetWithoutFavicon(userID int64) ([]model.Bookmark, error) {
+	rows, err := database.DB.Query(`SELECT id, url FROM bookmarks WHERE user_id = ? AND (favicon = '' OR favicon IS NULL) AND deleted_at IS NULL`, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -232,8 +305,13 @@ func (r *BookmarkRepository) GetWithoutFavicon() ([]model.Bookmark, error) {
 	return bookmarks, nil
 }
 
-func (r *BookmarkRepository) GetAllForExport() ([]model.Bookmark, error) {
-	rows, err := database.DB.Query(`SELECT url, title, folder_path FROM bookmarks WHERE url NOT LIKE 'nibstash://folder-placeholder/%' ORDER BY folder_path, title`)
+func (r *BookmarkRepository) GetAllForExport(userID int64) ([]model.Bookmark, error) {
+	rows, err := database.DB.Query(`
+		SELECT b.url, b.title, COALESCE(fp.path, '') AS folder_path
+		FROM `+bookmarkFromClause+`
+		WHERE b.user_id = ? AND b.deleted_at IS NULL
+		ORDER BY folder_path, b.title
+	`, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -249,7 +327,7 @@ func (r *BookmarkRepository) GetAllForExport() ([]model.Bookmark, error) {
 	return bookmarks, nil
 }
 
-func (r *BookmarkRepository) BatchImport(bookmarks []model.ImportBookmark, skipDuplicates bool) (imported, skipped int, err error) {
+func (r *BookmarkRepository) BatchImport(userID int64, bookmarks []model.ImportBookmark, skipDuplicates bool) (imported, skipped int, err error) {
 	tx, err := database.DB.Begin()
 	if err != nil {
 		return 0, 0, err
@@ -260,13 +338,13 @@ func (r *BookmarkRepository) BatchImport(bookmarks []model.ImportBookmark, skipD
 		}
 	}()
 
-	stmt, err := tx.Prepare(`INSERT OR IGNORE INTO bookmarks (url, title, description, folder_path, favicon) VALUES (?, ?, '', ?, ?)`)
+	stmt, err := tx.Prepare(`INSERT OR IGNORE INTO bookmarks (user_id, url, title, description, folder_id, favicon, title_pinyin, host, top_domain) VALUES (?, ?, ?, '', ?, ?, ?, ?, ?)`)
 	if err != nil {
 		return 0, 0, err
 	}
 	defer stmt.Close()
 
-	checkStmt, err := tx.Prepare(`SELECT COUNT(*) FROM bookmarks WHERE url = ? AND folder_path = ?`)
+	checkStmt, err := tx.Prepare(`SELECT COUNT(*) FROM bookmarks WHERE user_id = ? AND url = ? AND IFNULL(folder_id, 0) = ? AND deleted_at IS NULL`)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -275,13 +353,25 @@ func (r *BookmarkRepository) BatchImport(bookmarks []model.ImportBookmark, skipD
 	// 收集需要添加的域名
 	var importedURLs []string
 
+	// 文件夹路径 -> ID 缓存，避免同一文件夹重复查询
+	folderIDs := make(map[string]int64)
+
 	for _, bm := range bookmarks {
 		if bm.URL == "" || bm.Title == "" {
 			continue
 		}
 
+		folderID, ok := folderIDs[bm.FolderPath]
+		if !ok {
+			folderID, err = ensureFolderPath(tx, userID, bm.FolderPath)
+			if err != nil {
+				return imported, skipped, err
+			}
+			folderIDs[bm.FolderPath] = folderID
+		}
+
 		var count int
-		checkStmt.QueryRow(bm.URL, bm.FolderPath).Scan(&count)
+		checkStmt.QueryRow(userID, bm.URL, folderID).Scan(&count)
 		if count > 0 {
 			if skipDuplicates {
 				skipped++
@@ -289,7 +379,8 @@ func (r *BookmarkRepository) BatchImport(bookmarks []model.ImportBookmark, skipD
 			}
 		}
 
-		result, err := stmt.Exec(bm.URL, bm.Title, bm.FolderPath, bm.Favicon)
+		host, topDomain := bookmarkDomain(bm.URL)
+		result, err := stmt.Exec(userID, bm.URL, bm.Title, nullableFolderID(folderID), bm.Favicon, util.PinyinIndex(bm.Title), host, topDomain)
 		if err == nil {
 			if rows, _ := result.RowsAffected(); rows > 0 {
 				imported++
@@ -305,24 +396,121 @@ func (r *BookmarkRepository) BatchImport(bookmarks []model.ImportBookmark, skipD
 
 	// 同步添加域名到 domains 表
 	for _, url := range importedURLs {
-		r.domainRepo.AddDomain(url)
+		r.domainRepo.AddDomain(userID, url)
 	}
 
 	return imported, skipped, nil
 }
 
-// DeleteAll 清空所有书签
-func (r *BookmarkRepository) DeleteAll() error {
-	_, err := database.DB.Exec(`DELETE FROM bookmarks`)
+// SyncPinyin 为尚未生成拼音索引的书签补全 title_pinyin（用于升级后的首次启动）
+func (r *BookmarkRepository) SyncPinyin() error {
+	rows, err := database.DB.Query(`SELECT id, title FROM bookmarks WHERE title_pinyin IS NULL`)
+	if err != nil {
+		return err
+	}
+
+	pending := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var title string
+		if err := rows.Scan(&id, &title); err == nil {
+			pending[id] = title
+		}
+	}
+	rows.Close()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`UPDATE bookmarks SET title_pinyin = ? WHERE id = ?`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for id, title := range pending {
+		if _, err := stmt.Exec(util.PinyinIndex(title), id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// SyncDomains 补全书签的 host 和 top_domain，返回修改的行数
+// all 为 false 时只处理尚未计算的书签（用于升级后的首次启动），为 true 时按当前的 Public Suffix List 重新计算全部书签
+func (r *BookmarkRepository) SyncDomains(all bool) (int, error) {
+	query := `SELECT id, url, IFNULL(host, ''), IFNULL(top_domain, ''), host IS NULL FROM bookmarks`
+	if !all {
+		query += ` WHERE host IS NULL`
+	}
+	rows, err := database.DB.Query(query)
+	if err != nil {
+		return 0, err
+	}
+
+	type bookmarkDomainRow struct {
+		id              int64
+		host, topDomain string
+	}
+	var stale []bookmarkDomainRow
+	for rows.Next() {
+		var id int64
+		var url, oldHost, oldTopDomain string
+		var missing bool
+		if err := rows.Scan(&id, &url, &oldHost, &oldTopDomain, &missing); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if host, topDomain := bookmarkDomain(url); missing || host != oldHost || topDomain != oldTopDomain {
+			stale = append(stale, bookmarkDomainRow{id: id, host: host, topDomain: topDomain})
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(stale) == 0 {
+		return 0, err
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`UPDATE bookmarks SET host = ?, top_domain = ? WHERE id = ?`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	for _, row := range stale {
+		if _, err := stmt.Exec(row.host, row.topDomain, row.id); err != nil {
+			return 0, err
+		}
+	}
+	return len(stale), tx.Commit()
+}
+
+// DeleteAll 将用户的所有书签移入回收站
+func (r *BookmarkRepository) DeleteAll(userID int64) error {
+	_, err := database.DB.Exec(`UPDATE bookmarks SET deleted_at = ? WHERE user_id = ? AND deleted_at IS NULL`, trashStamp(), userID)
 	return err
 }
 
-// DeleteByFolder 删除指定文件夹的书签
-func (r *BookmarkRepository) DeleteByFolder(folderPath string) error {
-	if folderPath == "" {
-		_, err := database.DB.Exec(`DELETE FROM bookmarks WHERE folder_path = ''`)
+// DeleteByFolder 将指定文件夹（含子文件夹）的书签移入回收站，保留文件夹本身
+func (r *BookmarkRepository) DeleteByFolder(userID int64, folderPath string) error {
+	if folderPath = normalizeFolderPath(folderPath); folderPath == "" {
+		_, err := database.DB.Exec(`UPDATE bookmarks SET deleted_at = ? WHERE user_id = ? AND folder_id IS NULL AND deleted_at IS NULL`, trashStamp(), userID)
 		return err
 	}
-	_, err := database.DB.Exec(`DELETE FROM bookmarks WHERE folder_path = ? OR folder_path LIKE ?`, folderPath, folderPath+"/%")
+	clause, args := folderSubtreeClause(folderPath)
+	args = append([]interface{}{trashStamp(), userID}, args...)
+	_, err := database.DB.Exec(`UPDATE bookmarks AS b SET deleted_at = ? WHERE b.user_id = ? AND b.deleted_at IS NULL AND `+clause, args...)
 	return err
 }
@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode/utf8"
+
+	"Nibstash_v2_server/internal/search"
+)
+
+// trigram 分词器要求查询词至少 3 个字符，更短的搜索词回退到 LIKE
+const ftsMinQueryLen = 3
+
+// 高亮标记：先用控制字符占位，转义 HTML 后再替换成 <mark>，避免标题中的 HTML 被注入
+const (
+	highlightOpen  = "\x02"
+	highlightClose = "\x03"
+)
+
+// bm25 各列权重（title, url, description, title_pinyin）
+const ftsRankExpr = "bm25(bookmarks_fts, 10.0, 5.0, 1.0, 2.0)"
+
+// canUseFTS 判断搜索词能否走全文索引
+func canUseFTS(search string) bool {
+	return utf8.RuneCountInString(search) >= ftsMinQueryLen
+}
+
+// ftsPhrase 将用户输入转换为 FTS5 短语查询，避免输入中的运算符被解析
+func ftsPhrase(search string) string {
+	return `"` + strings.ReplaceAll(search, `"`, `""`) + `"`
+}
+
+// renderHighlight 转义 HTML 并把占位标记替换为 <mark>
+func renderHighlight(s string) string {
+	s = html.EscapeString(s)
+	s = strings.ReplaceAll(s, highlightOpen, "<mark>")
+	s = strings.ReplaceAll(s, highlightClose, "</mark>")
+	return s
+}
+
+// escapeLike 转义 LIKE 通配符，配合 ESCAPE '\' 使用
+func escapeLike(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `%`, `\%`)
+	s = strings.ReplaceAll(s, `_`, `\_`)
+	return s
+}
+
+// compileQuery 将搜索语法树编译为 WHERE 子句（书签表别名为 b）
+func compileQuery(node search.Node) (string, []interface{}) {
+	switch n := node.(type) {
+	case *search.And:
+		return compileGroup(n.Children, " AND ")
+	case *search.Or:
+		return compileGroup(n.Children, " OR ")
+	case *search.Not:
+		clause, args := compileQuery(n.Child)
+		return "NOT " + clause, args
+	case *search.Term:
+		return compileTerm(n)
+	}
+	return "1=1", nil
+}
+
+func compileGroup(children []search.Node, sep string) (string, []interface{}) {
+	clauses := make([]string, 0, len(children))
+	var args []interface{}
+	for _, child := range children {
+		clause, childArgs := compileQuery(child)
+		clauses = append(clauses, clause)
+		args = append(args, childArgs...)
+	}
+	return "(" + strings.Join(clauses, sep) + ")", args
+}
+
+func compileTerm(t *search.Term) (string, []interface{}) {
+	pattern := "%" + escapeLike(t.Value) + "%"
+
+	switch t.Field {
+	case search.FieldTag:
+		return `b.id IN (
+			SELECT bt.bookmark_id FROM bookmark_tags bt JOIN tags t ON t.id = bt.tag_id
+			WHERE t.name = ? COLLATE NOCASE
+		)`, []interface{}{t.Value}
+	case search.FieldFolder:
+		folderPath := normalizeFolderPath(t.Value)
+		clause, args := folderSubtreeClause(folderPath)
+		if pinyinClause, pinyinArgs := folderPathPinyinClause(folderPath); pinyinClause != "" {
+			return "(" + clause + " OR " + pinyinClause + ")", append(args, pinyinArgs...)
+		}
+		return clause, args
+	case search.FieldSite:
+		return compileSite(t.Value)
+	case search.FieldTitle:
+		return `(b.title LIKE ? ESCAPE '\' OR b.title_pinyin LIKE ? ESCAPE '\')`, []interface{}{pattern, pattern}
+	case search.FieldURL:
+		return `b.url LIKE ? ESCAPE '\'`, []interface{}{pattern}
+	case search.FieldDesc:
+		return `b.description LIKE ? ESCAPE '\'`, []interface{}{pattern}
+	case search.FieldCreated:
+		return "date(b.created_at) " + t.Op + " ?", []interface{}{t.Value}
+	case search.FieldUpdated:
+		return "date(b.updated_at) " + t.Op + " ?", []interface{}{t.Value}
+	}
+
+	// 自由文本：够长的词走全文索引，否则回退到 LIKE；纯字母的词同时按拼音匹配所在文件夹
+	var clause string
+	var args []interface{}
+	if canUseFTS(t.Value) {
+		clause, args = "b.id IN (SELECT rowid FROM bookmarks_fts WHERE bookmarks_fts MATCH ?)", []interface{}{ftsPhrase(t.Value)}
+	} else {
+		clause = `b.title LIKE ? ESCAPE '\' OR b.url LIKE ? ESCAPE '\' OR b.description LIKE ? ESCAPE '\' OR b.title_pinyin LIKE ? ESCAPE '\'`
+		args = []interface{}{pattern, pattern, pattern, pattern}
+	}
+	if isPinyinTerm(t.Value) {
+		clause += " OR " + folderPinyinClause(`(' ' || pinyin LIKE ? OR pinyin LIKE ?)`)
+		args = append(args, "% "+t.Value+"%", "%:"+t.Value+"%")
+	}
+	return "(" + clause + ")", args
+}
+
+// isPinyinTerm 只有由 ASCII 字母组成的词才按拼音匹配，避免数字和符号等误匹配
+func isPinyinTerm(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// folderPinyinClause 按拼音索引（格式见 util.PathPinyinIndex）匹配文件夹中的书签；
+// 子文件夹的拼音索引包含上级各级目录的词，因此子文件夹也会匹配
+func folderPinyinClause(condition string) string {
+	return "b.folder_id IN (SELECT id FROM folders WHERE deleted_at IS NULL AND " + condition + ")"
+}
+
+// folderPathPinyinClause folder: 的拼音匹配：各级都是纯字母时，每一级按对应层级目录的全拼或首字母的前缀匹配，
+// 只有一级时也可以按整个路径的全拼、首字母或各级首字匹配（如 "gh" 匹配 "工作/后台"）；否则返回空字符串
+func folderPathPinyinClause(folderPath string) (string, []interface{}) {
+	segments := strings.Split(folderPath, "/")
+	conditions := make([]string, 0, len(segments))
+	var args []interface{}
+	for i, segment := range segments {
+		if !isPinyinTerm(segment) {
+			return "", nil
+		}
+		conditions = append(conditions, "' ' || pinyin LIKE ?")
+		args = append(args, fmt.Sprintf("%% %d:%s%%", i+1, segment))
+	}
+	if len(segments) == 1 {
+		conditions[0] = "(' ' || pinyin LIKE ? OR " + conditions[0] + ")"
+		args = append([]interface{}{"% " + segments[0] + "%"}, args...)
+	}
+	return folderPinyinClause(strings.Join(conditions, " AND ")), args
+}
+
+// folderSubtreeClause 匹配指定文件夹及其所有子文件夹中的书签
+// 调用方需同时限定 b.user_id，书签的 folder_id 只会指向同一用户的文件夹，因此这里无需再按用户过滤
+func folderSubtreeClause(folderPath string) (string, []interface{}) {
+	return `b.folder_id IN (SELECT id FROM folder_paths WHERE deleted_at IS NULL AND (path = ? OR path LIKE ? ESCAPE '\'))`,
+		[]interface{}{folderPath, escapeLike(folderPath) + "/%"}
+}
+
+// compileSite 匹配站点及其子域名（忽略端口、路径和查询串）
+func compileSite(site string) (string, []interface{}) {
+	site = escapeLike(strings.ToLower(strings.Trim(site, "/")))
+
+	var clauses []string
+	var args []interface{}
+	for _, host := range []string{"://" + site, "://%." + site} {
+		for _, suffix := range []string{"", "/%", ":%", "?%", "#%"} {
+			clauses = append(clauses, `LOWER(b.url) LIKE ? ESCAPE '\'`)
+			args = append(args, "%"+host+suffix)
+		}
+	}
+	return "(" + strings.Join(clauses, " OR ") + ")", args
+}
+
+// rankQuery 收集未被否定的自由文本词，组成用于相关度排序和高亮的 FTS 查询
+func rankQuery(node search.Node) string {
+	var phrases []string
+	search.Walk(node, func(t *search.Term, negated bool) {
+		if !negated && t.Field == search.FieldText && canUseFTS(t.Value) {
+			phrases = append(phrases, ftsPhrase(t.Value))
+		}
+	})
+	return strings.Join(phrases, " OR ")
+}
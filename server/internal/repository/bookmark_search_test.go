@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"Nibstash_v2_server/database"
+	"Nibstash_v2_server/internal/search"
+)
+
+// setupDB 在临时目录中创建已执行全部迁移的数据库
+func setupDB(t *testing.T) {
+	t.Helper()
+	if err := database.Init(filepath.Join(t.TempDir(), "nibstash.db")); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(database.Close)
+	if err := database.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSearchFolderPinyin(t *testing.T) {
+	setupDB(t)
+	user, err := NewUserRepository().Register("alice", "password123", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 标题和网址不含下面用到的搜索词，只能通过文件夹匹配
+	bookmarks := NewBookmarkRepository()
+	for title, folder := range map[string]string{
+		"1": "工作/后台",
+		"2": "阅读",
+		"3": "安全",
+		"4": "",
+	} {
+		if _, err := bookmarks.Create(user.ID, "http://"+title+".cc/", title, "", "", folder, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"go", []string{"1"}},
+		{"gongzuo", []string{"1"}},
+		{"gz", []string{"1"}},
+		{"gh", []string{"1"}},
+		{"ho", []string{"1"}},
+		{"HOU", []string{"1"}},
+		{"yd", []string{"2"}},
+		{"a", []string{"3"}},
+		// 短词不再按子串匹配无关的文件夹
+		{"u", nil},
+		{"zu", nil},
+		{"ou", nil},
+		{"uo", nil},
+		{"ta", nil},
+		{"folder:gz", []string{"1"}},
+		{"folder:gh", []string{"1"}},
+		{"folder:gz/ho", []string{"1"}},
+		{"folder:工作", []string{"1"}},
+		{"folder:ho", nil},
+		{"folder:后台", nil},
+		{"folder:yd/ho", nil},
+		{"folder:u", nil},
+	}
+	for _, tt := range tests {
+		node, err := search.Parse(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		list, _, err := bookmarks.List(user.ID, 1, 100, 0, node, "", false, "title_asc")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, b := range list {
+			got = append(got, b.Title)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("search %q = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"Nibstash_v2_server/database"
+)
+
+// BreachRepository 本地 Have I Been Pwned 泄露密码数据集，离线检查密码是否泄露
+type BreachRepository struct{}
+
+func NewBreachRepository() *BreachRepository {
+	return &BreachRepository{}
+}
+
+// 导入时每个事务写入的行数
+const breachImportBatch = 100000
+
+// Loaded 是否已导入数据集
+func (r *BreachRepository) Loaded() bool {
+	var exists bool
+	database.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM pwned_passwords)`).Scan(&exists)
+	return exists
+}
+
+// Count 返回密码在数据集中出现的次数；未导入数据集时 checked 为 false
+func (r *BreachRepository) Count(password string) (count int, checked bool, err error) {
+	if !r.Loaded() {
+		return 0, false, nil
+	}
+
+	hash := sha1.Sum([]byte(password))
+	err = database.DB.QueryRow(`SELECT count FROM pwned_passwords WHERE hash = ?`, hash[:]).Scan(&count)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, true, nil
+		}
+		return 0, false, err
+	}
+	return count, true, nil
+}
+
+// Import 导入 HIBP 数据文件，每行为 "SHA1:次数"（完整哈希）；prefix 非空时为按前缀拆分的范围文件，
+// 每行为 "后 35 位:次数"。重复的哈希以后导入的为准，progress 每导入 100 万行调用一次
+func (r *BreachRepository) Import(reader io.Reader, prefix string, progress func(total int)) (int, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if tx != nil {
+			tx.Rollback()
+		}
+	}()
+	stmt, err := tx.Prepare(`INSERT OR REPLACE INTO pwned_passwords (hash, count) VALUES (?, ?)`)
+	if err != nil {
+		return 0, err
+	}
+
+	imported := 0
+	scanner := bufio.NewScanner(reader)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		hash, count, err := parseBreachLine(prefix + text)
+		if err != nil {
+			stmt.Close()
+			return imported, fmt.Errorf("第 %d 行: %w", line, err)
+		}
+		if _, err := stmt.Exec(hash, count); err != nil {
+			stmt.Close()
+			return imported, err
+		}
+		imported++
+
+		if imported%breachImportBatch == 0 {
+			stmt.Close()
+			if err := tx.Commit(); err != nil {
+				return imported, err
+			}
+			if tx, err = database.DB.Begin(); err != nil {
+				return imported, err
+			}
+			if stmt, err = tx.Prepare(`INSERT OR REPLACE INTO pwned_passwords (hash, count) VALUES (?, ?)`); err != nil {
+				return imported, err
+			}
+		}
+		if progress != nil && imported%1000000 == 0 {
+			progress(imported)
+		}
+	}
+	stmt.Close()
+	if err := scanner.Err(); err != nil {
+		return imported, err
+	}
+
+	err = tx.Commit()
+	tx = nil
+	return imported, err
+}
+
+// parseBreachLine 解析 "SHA1:次数"，没有次数时按 1 次计算
+func parseBreachLine(line string) ([]byte, int, error) {
+	hexHash, countText, hasCount := strings.Cut(line, ":")
+	if len(hexHash) != sha1.Size*2 {
+		return nil, 0, fmt.Errorf("不是 SHA-1 哈希: %q", hexHash)
+	}
+	hash, err := hex.DecodeString(hexHash)
+	if err != nil {
+		return nil, 0, fmt.Errorf("不是 SHA-1 哈希: %q", hexHash)
+	}
+
+	count := 1
+	if hasCount {
+		if count, err = strconv.Atoi(strings.TrimSpace(countText)); err != nil || count < 0 {
+			return nil, 0, fmt.Errorf("无效的次数: %q", countText)
+		}
+	}
+	return hash, count, nil
+}
+
+// Stats 数据集中的哈希数量
+func (r *BreachRepository) Stats() (int64, error) {
+	var total int64
+	err := database.DB.QueryRow(`SELECT COUNT(*) FROM pwned_passwords`).Scan(&total)
+	return total, err
+}
+
+// Clear 删除数据集，已保存的检查结果不变
+func (r *BreachRepository) Clear() error {
+	_, err := database.DB.Exec(`DELETE FROM pwned_passwords`)
+	return err
+}
@@ -1,28 +1,58 @@
 package repository
 
 import (
+	"database/sql"
+	"sort"
+	"strings"
+	"time"
+
 	"Nibstash_v2_server/database"
 	"Nibstash_v2_server/internal/model"
 	"Nibstash_v2_server/internal/util"
 )
 
-type CredentialRepository struct{}
+type CredentialRepository struct {
+	domainRepo *DomainRepository
+	breachRepo *BreachRepository
+	equivRepo  *EquivalentDomainRepository
+}
 
 func NewCredentialRepository() *CredentialRepository {
-	return &CredentialRepository{}
+	return &CredentialRepository{
+		domainRepo: NewDomainRepository(),
+		breachRepo: NewBreachRepository(),
+		equivRepo:  NewEquivalentDomainRepository(),
+	}
 }
 
-func (r *CredentialRepository) Create(domain, title, username, password, notes string) (*model.Credential, error) {
-	// 加密密码
-	encryptedPassword, err := util.Encrypt(password)
+// 凭证密码、TOTP 密钥、备注和 hidden 类型的自定义字段使用用户的保险库密钥加密，保险库锁定时读写凭证返回 util.ErrVaultLocked
+// 无法解密的内容不会当作密文返回，而是标记为 DecryptFailed（TOTP 密钥、备注、字段各有对应的标记）
+
+// Create 创建凭证，cred 中的 ID 和时间被忽略
+func (r *CredentialRepository) Create(userID int64, cred *model.Credential) (*model.Credential, error) {
+	keys, err := util.VaultKeyring(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 加密密码、TOTP 密钥和备注
+	encrypted, err := encryptCredential(keys, cred)
 	if err != nil {
 		return nil, err
 	}
 
-	result, err := database.DB.Exec(`
-		INSERT INTO credentials (domain, title, username, password, notes, updated_at)
-		VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
-	`, domain, title, username, encryptedPassword, notes)
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO credentials (user_id, type, domain, title, username, password, notes, notes_encrypted, totp_secret, password_strength, breach_count,
+			match_rule, match_pattern, updated_at, password_changed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, 1, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+	`, userID, cred.Type, cred.Domain, cred.Title, cred.Username, encrypted.password, encrypted.notes, encrypted.totpSecret,
+		passwordStrength(cred.Password, cred.Username, cred.Domain), r.breachCount(cred.Password), matchRule(cred.MatchRule), cred.MatchPattern)
 	if err != nil {
 		return nil, err
 	}
@@ -31,40 +61,55 @@ func (r *CredentialRepository) Create(domain, title, username, password, notes s
 	if err != nil {
 		return nil, err
 	}
+	if err := saveCredentialFields(tx, keys, id, cred.Fields); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
 
-	return r.GetByID(id)
+	return r.GetByID(userID, id)
 }
 
-func (r *CredentialRepository) GetByID(id int64) (*model.Credential, error) {
-	cred := &model.Credential{}
-	var encryptedPassword string
-	err := database.DB.QueryRow(`
-		SELECT id, domain, title, username, password, notes, created_at, updated_at
-		FROM credentials WHERE id = ?
-	`, id).Scan(&cred.ID, &cred.Domain, &cred.Title, &cred.Username, &encryptedPassword, &cred.Notes, &cred.CreatedAt, &cred.UpdatedAt)
+func (r *CredentialRepository) GetByID(userID, id int64) (*model.Credential, error) {
+	keys, err := util.VaultKeyring(userID)
 	if err != nil {
 		return nil, err
 	}
 
-	// 解密密码
-	if encryptedPassword != "" {
-		decrypted, err := util.Decrypt(encryptedPassword)
-		if err == nil {
-			cred.Password = decrypted
-		} else {
-			// 如果解密失败，可能是旧数据（未加密），直接使用
-			cred.Password = encryptedPassword
-		}
+	row := database.DB.QueryRow(`
+		SELECT `+credentialColumns+`
+		FROM credentials WHERE id = ? AND user_id = ? AND deleted_at IS NULL
+	`, id, userID)
+	cred, err := scanCredential(row, keys)
+	if err != nil {
+		return nil, err
 	}
 
-	return cred, nil
+	creds := []model.Credential{*cred}
+	if err := attachCredentialFields(keys, creds, "c.id = ? AND c.user_id = ?", id, userID); err != nil {
+		return nil, err
+	}
+	return &creds[0], nil
 }
 
-func (r *CredentialRepository) GetByDomain(domain string) ([]model.Credential, error) {
+// GetByDomain 按域名获取凭证，域名属于等价域名组时同时返回组内其他域名的凭证
+func (r *CredentialRepository) GetByDomain(userID int64, domain string) ([]model.Credential, error) {
+	keys, err := util.VaultKeyring(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	equivalents, err := r.equivRepo.resolve(userID)
+	if err != nil {
+		return nil, err
+	}
+	where, args := credentialDomainWhere(userID, domain, equivalents)
+
 	rows, err := database.DB.Query(`
-		SELECT id, domain, title, username, password, notes, created_at, updated_at
-		FROM credentials WHERE domain = ? ORDER BY id ASC
-	`, domain)
+		SELECT `+credentialColumns+`
+		FROM credentials c WHERE `+where+` AND c.deleted_at IS NULL ORDER BY c.id ASC
+	`, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -72,53 +117,161 @@ func (r *CredentialRepository) GetByDomain(domain string) ([]model.Credential, e
 
 	var creds []model.Credential
 	for rows.Next() {
-		var cred model.Credential
-		var encryptedPassword string
-		if err := rows.Scan(&cred.ID, &cred.Domain, &cred.Title, &cred.Username, &encryptedPassword, &cred.Notes, &cred.CreatedAt, &cred.UpdatedAt); err == nil {
-			// 解密密码
-			if encryptedPassword != "" {
-				decrypted, err := util.Decrypt(encryptedPassword)
-				if err == nil {
-					cred.Password = decrypted
-				} else {
-					cred.Password = encryptedPassword
-				}
-			}
-			creds = append(creds, cred)
+		if cred, err := scanCredential(rows, keys); err == nil {
+			creds = append(creds, *cred)
 		}
 	}
+	rows.Close()
+
+	if err := attachCredentialFields(keys, creds, where, args...); err != nil {
+		return nil, err
+	}
 	return creds, nil
 }
 
-func (r *CredentialRepository) Update(id int64, title, username, password, notes string) error {
-	// 加密密码
-	encryptedPassword, err := util.Encrypt(password)
+// credentialDomainWhere 按域名查找凭证的条件；域名属于等价域名组时还匹配组内所有顶级域名及其子域名
+func credentialDomainWhere(userID int64, domain string, equivalents equivalentDomains) (string, []interface{}) {
+	where := "c.user_id = ? AND (c.domain = ?"
+	args := []interface{}{userID, domain}
+	if group, ok := equivalents[GetTopDomain(domain)]; ok {
+		for _, topDomain := range group {
+			where += " OR c.domain = ? OR c.domain LIKE ?"
+			args = append(args, topDomain, "%."+topDomain)
+		}
+	}
+	return where + ")", args
+}
+
+// Update 更新凭证的标题、用户名、密码、备注、TOTP 密钥、自定义字段和匹配规则（类型和域名不变）
+// 密码发生变化时旧密码保存到历史记录，每个凭证只保留最近 historyDepth 个（为 0 时不保留）
+func (r *CredentialRepository) Update(userID int64, cred *model.Credential, historyDepth int) error {
+	keys, err := util.VaultKeyring(userID)
+	if err != nil {
+		return err
+	}
+
+	// 加密密码、TOTP 密钥和备注
+	encrypted, err := encryptCredential(keys, cred)
 	if err != nil {
 		return err
 	}
 
-	_, err = database.DB.Exec(`
-		UPDATE credentials SET title = ?, username = ?, password = ?, notes = ?, updated_at = CURRENT_TIMESTAMP
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var oldPassword string
+	var changedAt time.Time
+	err = tx.QueryRow(`SELECT password, password_changed_at FROM credentials WHERE id = ? AND user_id = ? AND deleted_at IS NULL`,
+		cred.ID, userID).Scan(&oldPassword, &changedAt)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// 无法解密的旧密码视为已修改，密文原样保存到历史记录
+	plaintext, failed := decryptOptional(keys, oldPassword)
+	changed := failed || plaintext != cred.Password
+
+	if _, err := tx.Exec(`
+		UPDATE credentials SET title = ?, username = ?, password = ?, notes = ?, notes_encrypted = 1, totp_secret = ?,
+			updated_at = CURRENT_TIMESTAMP, password_strength = ?, breach_count = ?, match_rule = ?, match_pattern = ?,
+			password_changed_at = CASE WHEN ? THEN CURRENT_TIMESTAMP ELSE password_changed_at END
 		WHERE id = ?
-	`, title, username, encryptedPassword, notes, id)
-	return err
+	`, cred.Title, cred.Username, encrypted.password, encrypted.notes, encrypted.totpSecret,
+		passwordStrength(cred.Password, cred.Username, cred.Domain), r.breachCount(cred.Password),
+		matchRule(cred.MatchRule), cred.MatchPattern, changed, cred.ID); err != nil {
+		return err
+	}
+	if changed && oldPassword != "" && historyDepth > 0 {
+		if _, err := tx.Exec(`INSERT INTO credential_history (credential_id, password, valid_from) VALUES (?, ?, ?)`,
+			cred.ID, oldPassword, changedAt); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`
+		DELETE FROM credential_history WHERE credential_id = ? AND id NOT IN (
+			SELECT id FROM credential_history WHERE credential_id = ? ORDER BY id DESC LIMIT ?
+		)
+	`, cred.ID, cred.ID, historyDepth); err != nil {
+		return err
+	}
+	if err := saveCredentialFields(tx, keys, cred.ID, cred.Fields); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// History 获取凭证的历史密码（不含密码），最近替换的在前
+func (r *CredentialRepository) History(userID, id int64) ([]model.CredentialHistoryEntry, error) {
+	rows, err := database.DB.Query(`
+		SELECT h.id, h.valid_from, h.replaced_at
+		FROM credential_history h JOIN credentials c ON c.id = h.credential_id
+		WHERE h.credential_id = ? AND c.user_id = ? AND c.deleted_at IS NULL
+		ORDER BY h.id DESC
+	`, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []model.CredentialHistoryEntry{}
+	for rows.Next() {
+		var entry model.CredentialHistoryEntry
+		if err := rows.Scan(&entry.ID, &entry.ValidFrom, &entry.ReplacedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
 }
 
-func (r *CredentialRepository) Delete(id int64) error {
-	_, err := database.DB.Exec(`DELETE FROM credentials WHERE id = ?`, id)
+// GetHistory 获取一条历史密码并解密，无法解密时标记 DecryptFailed
+func (r *CredentialRepository) GetHistory(userID, id, historyID int64) (*model.CredentialHistoryEntry, error) {
+	keys, err := util.VaultKeyring(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var entry model.CredentialHistoryEntry
+	var encryptedPassword string
+	err = database.DB.QueryRow(`
+		SELECT h.id, h.password, h.valid_from, h.replaced_at
+		FROM credential_history h JOIN credentials c ON c.id = h.credential_id
+		WHERE h.id = ? AND h.credential_id = ? AND c.user_id = ? AND c.deleted_at IS NULL
+	`, historyID, id, userID).Scan(&entry.ID, &encryptedPassword, &entry.ValidFrom, &entry.ReplacedAt)
+	if err != nil {
+		return nil, err
+	}
+	entry.Password, entry.DecryptFailed = decryptOptional(keys, encryptedPassword)
+	return &entry, nil
+}
+
+// Delete 将凭证移入回收站
+func (r *CredentialRepository) Delete(userID, id int64) error {
+	_, err := database.DB.Exec(`UPDATE credentials SET deleted_at = ? WHERE id = ? AND user_id = ? AND deleted_at IS NULL`, trashStamp(), id, userID)
 	return err
 }
 
-func (r *CredentialRepository) DeleteByDomain(domain string) error {
-	_, err := database.DB.Exec(`DELETE FROM credentials WHERE domain = ?`, domain)
+// DeleteByDomain 将域名下的所有凭证移入回收站
+func (r *CredentialRepository) DeleteByDomain(userID int64, domain string) error {
+	_, err := database.DB.Exec(`UPDATE credentials SET deleted_at = ? WHERE user_id = ? AND domain = ? AND deleted_at IS NULL`, trashStamp(), userID, domain)
 	return err
 }
 
-func (r *CredentialRepository) List() ([]model.Credential, error) {
+func (r *CredentialRepository) List(userID int64) ([]model.Credential, error) {
+	keys, err := util.VaultKeyring(userID)
+	if err != nil {
+		return nil, err
+	}
+
 	rows, err := database.DB.Query(`
-		SELECT id, domain, title, username, password, notes, created_at, updated_at
-		FROM credentials ORDER BY domain ASC, id ASC
-	`)
+		SELECT `+credentialColumns+`
+		FROM credentials WHERE user_id = ? AND deleted_at IS NULL ORDER BY domain ASC, id ASC
+	`, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -126,19 +279,428 @@ func (r *CredentialRepository) List() ([]model.Credential, error) {
 
 	var creds []model.Credential
 	for rows.Next() {
-		var cred model.Credential
-		var encryptedPassword string
-		if err := rows.Scan(&cred.ID, &cred.Domain, &cred.Title, &cred.Username, &encryptedPassword, &cred.Notes, &cred.CreatedAt, &cred.UpdatedAt); err == nil {
-			if encryptedPassword != "" {
-				decrypted, err := util.Decrypt(encryptedPassword)
-				if err == nil {
-					cred.Password = decrypted
-				} else {
-					cred.Password = encryptedPassword
+		if cred, err := scanCredential(rows, keys); err == nil {
+			creds = append(creds, *cred)
+		}
+	}
+	rows.Close()
+
+	if err := attachCredentialFields(keys, creds, "c.user_id = ?", userID); err != nil {
+		return nil, err
+	}
+	return creds, nil
+}
+
+// Import 批量导入凭证，按域名 + 用户名去重（已有凭证和文件中先出现的条目优先）
+// preview 为 true 时只计算结果，不写入数据库
+func (r *CredentialRepository) Import(userID int64, items []model.ImportCredential, preview bool) (result *model.CredentialImportResult, err error) {
+	keys, err := util.VaultKeyring(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil || preview {
+			tx.Rollback()
+		}
+	}()
+
+	// 已有凭证的 域名 + 用户名（安全笔记为标题）
+	seen := make(map[string]bool)
+	rows, err := tx.Query(`SELECT type, domain, username, title FROM credentials WHERE user_id = ? AND deleted_at IS NULL`, userID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var credType, domain, username, title string
+		if err := rows.Scan(&credType, &domain, &username, &title); err == nil {
+			seen[credentialKey(credType, domain, username, title)] = true
+		}
+	}
+	rows.Close()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO credentials (user_id, type, domain, title, username, password, notes, notes_encrypted, totp_secret, password_strength, breach_count,
+			match_rule, match_pattern, updated_at, password_changed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, 1, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	result = &model.CredentialImportResult{Preview: preview, Total: len(items), Items: []model.CredentialImportItem{}}
+	for _, item := range items {
+		if item.Type == "" {
+			item.Type = model.CredentialTypeLogin
+		}
+		entry := model.CredentialImportItem{Type: item.Type, Domain: item.Domain, Title: item.Title, Username: item.Username}
+		key := credentialKey(item.Type, item.Domain, item.Username, item.Title)
+		switch {
+		case item.Invalid != "":
+			entry.Status, entry.Reason = model.CredentialImportInvalid, item.Invalid
+			result.Invalid++
+		case seen[key]:
+			entry.Status = model.CredentialImportDuplicate
+			result.Duplicates++
+		default:
+			seen[key] = true
+			entry.Status, entry.Warning = model.CredentialImportNew, item.Warning
+			result.Imported++
+			if !preview {
+				encrypted, err := encryptCredential(keys, &model.Credential{Password: item.Password, Notes: item.Notes, TOTPSecret: item.TOTP})
+				if err != nil {
+					return nil, err
+				}
+				res, err := stmt.Exec(userID, item.Type, item.Domain, item.Title, item.Username, encrypted.password, encrypted.notes, encrypted.totpSecret,
+					passwordStrength(item.Password, item.Username, item.Domain), r.breachCount(item.Password), matchRule(item.MatchRule), item.MatchPattern)
+				if err != nil {
+					return nil, err
+				}
+				id, err := res.LastInsertId()
+				if err != nil {
+					return nil, err
+				}
+				if err := saveCredentialFields(tx, keys, id, item.Fields); err != nil {
+					return nil, err
 				}
 			}
-			creds = append(creds, cred)
 		}
+		result.Items = append(result.Items, entry)
 	}
-	return creds, nil
+
+	if !preview {
+		if err = tx.Commit(); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// Health 生成保险库健康报告，列出弱密码、已泄露的密码、重复使用的密码和超过 maxAge 未修改（按 updated_at）的密码，
+// 按 domains 表中的顶级域名分组；同时补齐或更新数据库中的密码强度评分和泄露检查结果
+// （导入泄露数据集之前保存的凭证在这里完成检查）
+func (r *CredentialRepository) Health(userID int64, maxAge time.Duration) (*model.CredentialHealthReport, error) {
+	creds, err := r.List(userID)
+	if err != nil {
+		return nil, err
+	}
+	topDomains, err := r.domainRepo.TopDomains(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 相同密码出现的次数
+	uses := make(map[string]int)
+	for _, cred := range creds {
+		if !cred.DecryptFailed && cred.Password != "" {
+			uses[cred.Password]++
+		}
+	}
+
+	report := &model.CredentialHealthReport{
+		BreachesLoaded: r.breachRepo.Loaded(),
+		MaxAgeDays:     int(maxAge.Hours() / 24),
+		Groups:         []model.CredentialHealthGroup{},
+	}
+	groups := make(map[string]*model.CredentialHealthGroup)
+	var order []string
+	cutoff := time.Now().Add(-maxAge)
+	for _, cred := range creds {
+		// 安全笔记没有密码，不计入报告
+		if cred.Type == model.CredentialTypeNote {
+			continue
+		}
+		report.Total++
+		if cred.DecryptFailed {
+			report.DecryptFailed++
+			continue
+		}
+		if cred.Password == "" {
+			continue
+		}
+
+		score := util.EstimateStrength(cred.Password, cred.Username, cred.Domain).Score
+		if cred.PasswordStrength == nil || *cred.PasswordStrength != score {
+			database.DB.Exec(`UPDATE credentials SET password_strength = ? WHERE id = ? AND user_id = ?`, score, cred.ID, userID)
+		}
+		if count, ok := r.breachCount(cred.Password).(int); ok {
+			if cred.BreachCount == nil || *cred.BreachCount != count {
+				database.DB.Exec(`UPDATE credentials SET breach_count = ? WHERE id = ? AND user_id = ?`, count, cred.ID, userID)
+			}
+			cred.BreachCount = &count
+		}
+
+		item := model.CredentialHealthItem{
+			ID:          cred.ID,
+			Domain:      cred.Domain,
+			Title:       cred.Title,
+			Username:    cred.Username,
+			Strength:    score,
+			Weak:        score < util.StrongScore,
+			BreachCount: derefInt(cred.BreachCount),
+			ReusedCount: uses[cred.Password] - 1,
+			Old:         cred.UpdatedAt.Before(cutoff),
+			AgeDays:     int(time.Since(cred.UpdatedAt).Hours() / 24),
+			UpdatedAt:   cred.UpdatedAt,
+		}
+		if item.Weak {
+			report.Weak++
+		}
+		if item.BreachCount > 0 {
+			report.Breached++
+		}
+		if item.ReusedCount > 0 {
+			report.Reused++
+		}
+		if item.Old {
+			report.Old++
+		}
+		if !item.Weak && item.BreachCount == 0 && item.ReusedCount == 0 && !item.Old {
+			continue
+		}
+
+		topDomain := topDomains[cred.Domain]
+		if topDomain == "" {
+			topDomain = GetTopDomain(cred.Domain)
+		}
+		group, ok := groups[topDomain]
+		if !ok {
+			group = &model.CredentialHealthGroup{TopDomain: topDomain}
+			groups[topDomain] = group
+			order = append(order, topDomain)
+		}
+		group.Credentials = append(group.Credentials, item)
+	}
+
+	// 问题多的域名排在前面
+	sort.SliceStable(order, func(i, j int) bool {
+		return len(groups[order[i]].Credentials) > len(groups[order[j]].Credentials)
+	})
+	for _, topDomain := range order {
+		report.Groups = append(report.Groups, *groups[topDomain])
+	}
+	return report, nil
+}
+
+func derefInt(v *int) int {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
+
+// credentialKey 凭证去重使用的键：登录为域名（不区分大小写）+ 用户名，安全笔记为标题
+func credentialKey(credType, domain, username, title string) string {
+	if credType == model.CredentialTypeNote {
+		return credType + "\x00" + title
+	}
+	return strings.ToLower(domain) + "\x00" + username
+}
+
+// credentialColumns 查询凭证时读取的列，与 scanCredential 对应
+const credentialColumns = `id, type, domain, title, username, password, notes, notes_encrypted, totp_secret, password_strength, breach_count,
+	created_at, updated_at, password_changed_at, match_rule, match_pattern`
+
+// scanCredential 读取一行凭证并解密密码、备注和 TOTP 密钥（自定义字段由 attachCredentialFields 读取）
+func scanCredential(row rowScanner, keys *util.Keyring) (*model.Credential, error) {
+	cred := &model.Credential{}
+	var encryptedPassword, notes, encryptedTOTP string
+	var notesEncrypted bool
+	var strength, breachCount sql.NullInt64
+	if err := row.Scan(&cred.ID, &cred.Type, &cred.Domain, &cred.Title, &cred.Username, &encryptedPassword, &notes, &notesEncrypted,
+		&encryptedTOTP, &strength, &breachCount, &cred.CreatedAt, &cred.UpdatedAt, &cred.PasswordChangedAt,
+		&cred.MatchRule, &cred.MatchPattern); err != nil {
+		return nil, err
+	}
+	if strength.Valid {
+		score := int(strength.Int64)
+		cred.PasswordStrength = &score
+	}
+	if breachCount.Valid {
+		count := int(breachCount.Int64)
+		cred.BreachCount = &count
+	}
+	cred.Password, cred.DecryptFailed = decryptOptional(keys, encryptedPassword)
+	cred.TOTPSecret, cred.TOTPDecryptFailed = decryptOptional(keys, encryptedTOTP)
+	if notesEncrypted {
+		cred.Notes, cred.NotesDecryptFailed = decryptOptional(keys, notes)
+	} else {
+		// 尚未加密的旧备注
+		cred.Notes = notes
+	}
+	return cred, nil
+}
+
+// encryptedCredential 凭证中加密保存的列
+type encryptedCredential struct {
+	password   string
+	notes      string
+	totpSecret string
+}
+
+func encryptCredential(keys *util.Keyring, cred *model.Credential) (*encryptedCredential, error) {
+	password, err := keys.Encrypt(cred.Password)
+	if err != nil {
+		return nil, err
+	}
+	notes, err := encryptOptional(keys, cred.Notes)
+	if err != nil {
+		return nil, err
+	}
+	totpSecret, err := encryptOptional(keys, cred.TOTPSecret)
+	if err != nil {
+		return nil, err
+	}
+	return &encryptedCredential{password: password, notes: notes, totpSecret: totpSecret}, nil
+}
+
+// encryptOptional 加密可以为空的值，空值保存为空字符串
+func encryptOptional(keys *util.Keyring, value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	return keys.Encrypt(value)
+}
+
+// decryptOptional 解密可以为空的值，返回明文和是否解密失败
+func decryptOptional(keys *util.Keyring, ciphertext string) (string, bool) {
+	if ciphertext == "" {
+		return "", false
+	}
+	value, err := keys.Decrypt(ciphertext)
+	if err != nil {
+		return "", true
+	}
+	return value, false
+}
+
+// saveCredentialFields 替换凭证的自定义字段，hidden 字段的值加密保存
+func saveCredentialFields(tx *sql.Tx, keys *util.Keyring, credentialID int64, fields []model.CredentialField) error {
+	if _, err := tx.Exec(`DELETE FROM credential_fields WHERE credential_id = ?`, credentialID); err != nil {
+		return err
+	}
+	for i, field := range fields {
+		value := field.Value
+		if field.Type == model.FieldTypeHidden {
+			var err error
+			if value, err = encryptOptional(keys, value); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.Exec(`INSERT INTO credential_fields (credential_id, position, name, type, value) VALUES (?, ?, ?, ?, ?)`,
+			credentialID, i, field.Name, field.Type, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attachCredentialFields 读取满足 where（credentials 表别名为 c）的凭证的自定义字段并填入 creds
+func attachCredentialFields(keys *util.Keyring, creds []model.Credential, where string, args ...interface{}) error {
+	if len(creds) == 0 {
+		return nil
+	}
+	rows, err := database.DB.Query(`
+		SELECT f.credential_id, f.name, f.type, f.value
+		FROM credential_fields f JOIN credentials c ON c.id = f.credential_id
+		WHERE c.deleted_at IS NULL AND `+where+`
+		ORDER BY f.credential_id, f.position
+	`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	fields := make(map[int64][]model.CredentialField)
+	for rows.Next() {
+		var credentialID int64
+		var field model.CredentialField
+		if err := rows.Scan(&credentialID, &field.Name, &field.Type, &field.Value); err != nil {
+			return err
+		}
+		if field.Type == model.FieldTypeHidden {
+			field.Value, field.DecryptFailed = decryptOptional(keys, field.Value)
+		}
+		fields[credentialID] = append(fields[credentialID], field)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range creds {
+		creds[i].Fields = fields[creds[i].ID]
+		if creds[i].Fields == nil {
+			creds[i].Fields = []model.CredentialField{}
+		}
+	}
+	return nil
+}
+
+// EncryptLegacyNotes 加密用户尚未加密的旧备注（保险库解锁后调用），返回加密的数量
+func (r *CredentialRepository) EncryptLegacyNotes(userID int64) (int, error) {
+	keys, err := util.VaultKeyring(userID)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT id, notes FROM credentials WHERE user_id = ? AND notes_encrypted = 0`, userID)
+	if err != nil {
+		return 0, err
+	}
+	notes := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var plaintext string
+		if err := rows.Scan(&id, &plaintext); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		notes[id] = plaintext
+	}
+	rows.Close()
+	if len(notes) == 0 {
+		return 0, nil
+	}
+
+	for id, plaintext := range notes {
+		encrypted, err := encryptOptional(keys, plaintext)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(`UPDATE credentials SET notes = ?, notes_encrypted = 1 WHERE id = ?`, encrypted, id); err != nil {
+			return 0, err
+		}
+	}
+	return len(notes), tx.Commit()
+}
+
+// passwordStrength 计算保存到数据库的密码强度评分，空密码不评估
+func passwordStrength(password, username, domain string) interface{} {
+	if password == "" {
+		return nil
+	}
+	return util.EstimateStrength(password, username, domain).Score
+}
+
+// breachCount 在本地泄露数据集中检查密码，未导入数据集、查询失败或空密码时不保存结果
+func (r *CredentialRepository) breachCount(password string) interface{} {
+	if password == "" {
+		return nil
+	}
+	count, checked, err := r.breachRepo.Count(password)
+	if err != nil || !checked {
+		return nil
+	}
+	return count
 }
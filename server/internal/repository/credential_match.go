@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"Nibstash_v2_server/database"
+	"Nibstash_v2_server/internal/model"
+	"Nibstash_v2_server/internal/util"
+)
+
+// Match 按凭证的匹配规则查找与网页地址匹配的登录凭证，按匹配质量从高到低排列（质量相同时按创建顺序）
+// domain 规则下等价域名组内的顶级域名视为相同
+func (r *CredentialRepository) Match(userID int64, pageURL string) ([]model.CredentialMatch, error) {
+	page := parsePageURL(pageURL)
+	equivalents, err := r.equivRepo.resolve(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT id, domain, match_rule, match_pattern FROM credentials
+		WHERE user_id = ? AND type = ? AND match_rule != ? AND deleted_at IS NULL
+		ORDER BY id ASC
+	`, userID, model.CredentialTypeLogin, model.MatchNever)
+	if err != nil {
+		return nil, err
+	}
+
+	type candidate struct {
+		id      int64
+		match   string
+		quality int
+	}
+	var candidates []candidate
+	for rows.Next() {
+		var id int64
+		var domain, rule, pattern string
+		if err := rows.Scan(&id, &domain, &rule, &pattern); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if match, quality := matchCredential(rule, pattern, domain, pageURL, page, equivalents); quality > 0 {
+			candidates = append(candidates, candidate{id: id, match: match, quality: quality})
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].quality > candidates[j].quality
+	})
+
+	matches := []model.CredentialMatch{}
+	for _, c := range candidates {
+		cred, err := r.GetByID(userID, c.id)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, model.CredentialMatch{Credential: *cred, Match: c.match, Quality: c.quality})
+	}
+	return matches, nil
+}
+
+// matchCredential 判断网页是否匹配凭证，返回匹配方式和质量，不匹配时质量为 0
+// page 为解析后的网页地址（不是 http/https 地址时为空），只有 starts_with、regex 规则能匹配其他协议
+func matchCredential(rule, pattern, domain, pageURL string, page *url.URL, equivalents equivalentDomains) (string, int) {
+	switch rule {
+	case model.MatchStartsWith:
+		if pattern != "" && strings.HasPrefix(pageURL, pattern) {
+			return "pattern", model.MatchQualityPattern
+		}
+		return "", 0
+	case model.MatchRegex:
+		re, err := regexp.Compile(pattern)
+		if err == nil && re.MatchString(pageURL) {
+			return "pattern", model.MatchQualityPattern
+		}
+		return "", 0
+	case model.MatchNever:
+		return "", 0
+	}
+
+	if page == nil {
+		return "", 0
+	}
+	host := util.NormalizeHost(page.Hostname())
+	domain = util.NormalizeHost(domain)
+	if host == domain {
+		return "host", model.MatchQualityHost
+	}
+	// IP 地址没有子域名和顶级域名，只能按主机名匹配
+	if rule == model.MatchHost || net.ParseIP(host) != nil {
+		return "", 0
+	}
+	if strings.HasSuffix(host, "."+domain) || strings.HasSuffix(domain, "."+host) {
+		return "subdomain", model.MatchQualitySubdomain
+	}
+	hostTop, domainTop := GetTopDomain(host), GetTopDomain(domain)
+	if hostTop == domainTop {
+		return "domain", model.MatchQualityBaseDomain
+	}
+	if equivalents.equivalent(hostTop, domainTop) {
+		return "equivalent", model.MatchQualityBaseDomain
+	}
+	return "", 0
+}
+
+// parsePageURL 解析网页地址，兼容没有协议的地址；不是 http/https 地址时返回 nil
+func parsePageURL(pageURL string) *url.URL {
+	raw := strings.TrimSpace(pageURL)
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return nil
+	}
+	return u
+}
+
+// matchRule 未设置匹配规则时使用默认规则
+func matchRule(rule string) string {
+	if rule == "" {
+		return model.MatchBaseDomain
+	}
+	return rule
+}
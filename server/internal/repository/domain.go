@@ -3,18 +3,26 @@ package repository
 import (
 	"Nibstash_v2_server/database"
 	"Nibstash_v2_server/internal/model"
-	"sort"
+	"Nibstash_v2_server/internal/util"
+	"encoding/json"
+	"net"
+	"net/url"
+	"slices"
 	"strings"
 )
 
-type DomainRepository struct{}
+type DomainRepository struct {
+	equivRepo *EquivalentDomainRepository
+}
 
 func NewDomainRepository() *DomainRepository {
-	return &DomainRepository{}
+	return &DomainRepository{
+		equivRepo: NewEquivalentDomainRepository(),
+	}
 }
 
-// AddDomain 添加域名到 domains 表（添加书签时调用）
-func (r *DomainRepository) AddDomain(url string) error {
+// AddDomain 添加用户的域名到 domains 表（添加书签时调用）
+func (r *DomainRepository) AddDomain(userID int64, url string) error {
 	domain := ExtractDomain(url)
 	if domain == "" {
 		return nil
@@ -27,145 +35,285 @@ func (r *DomainRepository) AddDomain(url string) error {
 
 	// 使用 INSERT OR IGNORE 避免重复
 	_, err := database.DB.Exec(`
-		INSERT OR IGNORE INTO domains (domain, top_domain) VALUES (?, ?)
-	`, domain, topDomain)
+		INSERT OR IGNORE INTO domains (user_id, domain, top_domain) VALUES (?, ?, ?)
+	`, userID, domain, topDomain)
 	return err
 }
 
 // DeleteDomain 删除域名记录（同时删除该域名下的凭证）
-func (r *DomainRepository) DeleteDomain(domain string) error {
+func (r *DomainRepository) DeleteDomain(userID int64, domain string) error {
 	// 删除域名记录
-	_, err := database.DB.Exec(`DELETE FROM domains WHERE domain = ? OR top_domain = ?`, domain, domain)
+	_, err := database.DB.Exec(`DELETE FROM domains WHERE user_id = ? AND (domain = ? OR top_domain = ?)`, userID, domain, domain)
 	return err
 }
 
-// GetAllDomains 从 domains 表获取域名列表，并计算书签数量和凭证信息
-func (r *DomainRepository) GetAllDomains() ([]model.DomainGroup, error) {
-	// 1. 从 domains 表获取所有域名
-	rows, err := database.DB.Query(`SELECT domain, top_domain FROM domains ORDER BY top_domain`)
+// ListDomains 获取按顶级域名分组的域名列表，返回当前页和分组总数，pageSize 为 0 时返回全部
+// 书签数量按 top_domain 分组统计（使用 idx_bookmarks_user_top_domain 索引），排序和分页都在 SQL 中完成：
+// 按书签数量排序，数量相同时有凭证的在前。等价域名组内任一顶级域名有凭证时组内的域名都标记为有凭证；
+// collapse 为 true 时同一等价域名组合并为书签最多的一项
+func (r *DomainRepository) ListDomains(userID int64, collapse bool, page, pageSize int) ([]model.DomainGroup, int, error) {
+	equivalents, err := r.equivRepo.resolve(userID)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-	defer rows.Close()
 
-	// 收集所有域名
-	allDomains := make(map[string]string) // domain -> top_domain
-	for rows.Next() {
-		var domain, topDomain string
-		if err := rows.Scan(&domain, &topDomain); err == nil {
-			allDomains[domain] = topDomain
+	// 等价域名组内的顶级域名 -> 组的第一个域名，不属于任何组的顶级域名自成一组
+	groupKeys := make(map[string]string)
+	for domain, members := range equivalents {
+		groupKeys[domain] = members[0]
+	}
+	groupKey := func(topDomain string) string {
+		if key, ok := groupKeys[topDomain]; ok {
+			return key
 		}
+		return topDomain
 	}
 
-	// 2. 计算每个域名的书签数量
-	domainCount := make(map[string]int)
-	bookmarkRows, err := database.DB.Query(`
-		SELECT url FROM bookmarks
-		WHERE url NOT LIKE 'nibstash://folder-placeholder/%'
-		AND url LIKE 'http%'
-	`)
+	// 有凭证的组
+	credRows, err := database.DB.Query(`SELECT DISTINCT domain FROM credentials WHERE user_id = ? AND deleted_at IS NULL AND domain != ''`, userID)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-	defer bookmarkRows.Close()
-
-	for bookmarkRows.Next() {
-		var url string
-		if err := bookmarkRows.Scan(&url); err == nil {
-			domain := ExtractDomain(url)
-			if domain != "" {
-				domainCount[domain]++
-			}
+	credentialGroups := []string{}
+	for credRows.Next() {
+		var domain string
+		if err := credRows.Scan(&domain); err == nil {
+			credentialGroups = append(credentialGroups, groupKey(GetTopDomain(domain)))
 		}
 	}
+	credRows.Close()
 
-	// 3. 获取有凭证的域名
-	credRows, err := database.DB.Query(`SELECT DISTINCT domain FROM credentials`)
+	groupKeysJSON, err := json.Marshal(groupKeys)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+	credentialGroupsJSON, err := json.Marshal(credentialGroups)
+	if err != nil {
+		return nil, 0, err
 	}
-	defer credRows.Close()
 
-	credentialDomains := make(map[string]bool)
-	for credRows.Next() {
-		var domain string
-		if err := credRows.Scan(&domain); err == nil && domain != "" {
-			credentialDomains[domain] = true
+	var total, collapsedTotal int
+	err = database.DB.QueryRow(`
+		SELECT COUNT(DISTINCT d.top_domain), COUNT(DISTINCT IFNULL(k.value, d.top_domain))
+		FROM domains d LEFT JOIN json_each(?) k ON k.key = d.top_domain
+		WHERE d.user_id = ? AND d.top_domain != ''
+	`, string(groupKeysJSON), userID).Scan(&total, &collapsedTotal)
+	if err != nil {
+		return nil, 0, err
+	}
+	if collapse {
+		total = collapsedTotal
+	}
+
+	limit, offset := -1, 0
+	if pageSize > 0 {
+		limit, offset = pageSize, (page-1)*pageSize
+	}
+
+	// 合并时每组取书签最多的顶级域名，书签数量为组内之和
+	query := `
+		SELECT top_domain, bookmark_count, has_credentials FROM counted
+		ORDER BY bookmark_count DESC, has_credentials DESC, top_domain
+		LIMIT ? OFFSET ?
+	`
+	if collapse {
+		query = `
+			SELECT top_domain, group_count, has_credentials FROM (
+				SELECT top_domain, has_credentials,
+					SUM(bookmark_count) OVER (PARTITION BY group_key) AS group_count,
+					ROW_NUMBER() OVER (PARTITION BY group_key ORDER BY bookmark_count DESC, top_domain) AS seq
+				FROM counted
+			) WHERE seq = 1
+			ORDER BY group_count DESC, has_credentials DESC, top_domain
+			LIMIT ? OFFSET ?
+		`
+	}
+	rows, err := database.DB.Query(`
+		WITH top_domains AS (
+			SELECT d.top_domain, IFNULL(k.value, d.top_domain) AS group_key
+			FROM (SELECT DISTINCT top_domain FROM domains WHERE user_id = ? AND top_domain != '') d
+			LEFT JOIN json_each(?) k ON k.key = d.top_domain
+		), counted AS (
+			SELECT t.top_domain, t.group_key, IFNULL(c.bookmark_count, 0) AS bookmark_count,
+				t.group_key IN (SELECT value FROM json_each(?)) AS has_credentials
+			FROM top_domains t
+			LEFT JOIN (
+				SELECT top_domain, COUNT(*) AS bookmark_count FROM bookmarks
+				WHERE user_id = ? AND deleted_at IS NULL
+				GROUP BY top_domain
+			) c ON c.top_domain = t.top_domain
+		)
+	`+query, userID, string(groupKeysJSON), string(credentialGroupsJSON), userID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	result := []model.DomainGroup{}
+	index := make(map[string]int) // 组 -> 在 result 中的下标
+	var topDomains []string       // 当前页需要读取子域名的顶级域名
+	for rows.Next() {
+		group := model.DomainGroup{SubDomains: []string{}}
+		if err := rows.Scan(&group.TopDomain, &group.BookmarkCount, &group.HasCredentials); err != nil {
+			return nil, 0, err
 		}
+		if collapse {
+			index[groupKey(group.TopDomain)] = len(result)
+			topDomains = append(topDomains, equivalents.of(group.TopDomain)...)
+		} else {
+			index[group.TopDomain] = len(result)
+			topDomains = append(topDomains, group.TopDomain)
+		}
+		result = append(result, group)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(result) == 0 {
+		return result, total, err
 	}
 
-	// 4. 按顶级域名分组
-	groups := make(map[string]*model.DomainGroup)
-	for domain, topDomain := range allDomains {
-		if _, exists := groups[topDomain]; !exists {
-			groups[topDomain] = &model.DomainGroup{
-				TopDomain:      topDomain,
-				SubDomains:     []string{},
-				BookmarkCount:  0,
-				HasCredentials: false,
-			}
+	// 只读取当前页的子域名和等价域名
+	topDomainsJSON, err := json.Marshal(topDomains)
+	if err != nil {
+		return nil, 0, err
+	}
+	domainRows, err := database.DB.Query(`
+		SELECT domain, top_domain FROM domains
+		WHERE user_id = ? AND top_domain IN (SELECT value FROM json_each(?))
+		ORDER BY top_domain, domain
+	`, userID, string(topDomainsJSON))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer domainRows.Close()
+
+	for domainRows.Next() {
+		var domain, topDomain string
+		if err := domainRows.Scan(&domain, &topDomain); err != nil {
+			return nil, 0, err
 		}
-		groups[topDomain].BookmarkCount += domainCount[domain]
+		key := topDomain
+		if collapse {
+			key = groupKey(topDomain)
+		}
+		i, ok := index[key]
+		if !ok {
+			continue
+		}
+		group := &result[i]
 		if domain != topDomain {
-			groups[topDomain].SubDomains = append(groups[topDomain].SubDomains, domain)
+			group.SubDomains = append(group.SubDomains, domain)
 		}
-		// 标记该域名组是否有凭证
-		if credentialDomains[domain] || credentialDomains[topDomain] {
-			groups[topDomain].HasCredentials = true
+		if topDomain != group.TopDomain && !slices.Contains(group.EquivalentDomains, topDomain) {
+			group.EquivalentDomains = append(group.EquivalentDomains, topDomain)
 		}
 	}
+	return result, total, domainRows.Err()
+}
 
-	result := make([]model.DomainGroup, 0, len(groups))
-	for _, group := range groups {
-		result = append(result, *group)
+// TopDomains 获取用户 domains 表中域名到顶级域名的映射
+func (r *DomainRepository) TopDomains(userID int64) (map[string]string, error) {
+	rows, err := database.DB.Query(`SELECT domain, top_domain FROM domains WHERE user_id = ?`, userID)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	// 按书签数量排序
-	sort.Slice(result, func(i, j int) bool {
-		if result[i].BookmarkCount == result[j].BookmarkCount {
-			if result[i].HasCredentials != result[j].HasCredentials {
-				return result[i].HasCredentials
-			}
-			return result[i].TopDomain < result[j].TopDomain
+	topDomains := make(map[string]string)
+	for rows.Next() {
+		var domain, topDomain string
+		if err := rows.Scan(&domain, &topDomain); err == nil {
+			topDomains[domain] = topDomain
 		}
-		return result[i].BookmarkCount > result[j].BookmarkCount
-	})
-
-	return result, nil
+	}
+	return topDomains, nil
 }
 
-// SyncDomainsFromBookmarks 从现有书签同步域名到 domains 表（用于初始化或修复）
+// SyncDomainsFromBookmarks 从现有书签同步域名到 domains 表（用于初始化或修复），书签的 host 需已补全
 func (r *DomainRepository) SyncDomainsFromBookmarks() error {
-	rows, err := database.DB.Query(`
-		SELECT DISTINCT url FROM bookmarks
-		WHERE url NOT LIKE 'nibstash://folder-placeholder/%'
-		AND url LIKE 'http%'
+	_, err := database.DB.Exec(`
+		INSERT OR IGNORE INTO domains (user_id, domain, top_domain)
+		SELECT DISTINCT user_id, host, top_domain FROM bookmarks
+		WHERE host != '' AND top_domain != ''
 	`)
+	return err
+}
+
+// SyncTopDomains 按当前的 Public Suffix List 重新计算 domains 表的域名写法和顶级域名，返回修改的行数
+// all 为 false 时只处理顶级域名为空的行（迁移 0017 清空了旧规则计算的结果）；规范化后重复的域名只保留一条
+func (r *DomainRepository) SyncTopDomains(all bool) (int, error) {
+	query := `SELECT id, domain, top_domain FROM domains`
+	if !all {
+		query += ` WHERE top_domain = ''`
+	}
+	rows, err := database.DB.Query(query)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	defer rows.Close()
-
+	type domainRow struct {
+		id                int64
+		domain, topDomain string
+	}
+	var stale []domainRow
 	for rows.Next() {
-		var url string
-		if err := rows.Scan(&url); err == nil {
-			r.AddDomain(url)
+		var row domainRow
+		if err := rows.Scan(&row.id, &row.domain, &row.topDomain); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		domain := util.NormalizeHost(row.domain)
+		if topDomain := GetTopDomain(domain); domain != row.domain || topDomain != row.topDomain {
+			stale = append(stale, domainRow{id: row.id, domain: domain, topDomain: topDomain})
 		}
 	}
-	return nil
+	rows.Close()
+	if err := rows.Err(); err != nil || len(stale) == 0 {
+		return 0, err
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	for _, row := range stale {
+		result, err := tx.Exec(`UPDATE OR IGNORE domains SET domain = ?, top_domain = ? WHERE id = ?`, row.domain, row.topDomain, row.id)
+		if err != nil {
+			return 0, err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			if _, err := tx.Exec(`DELETE FROM domains WHERE id = ?`, row.id); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return len(stale), tx.Commit()
 }
 
-// GetBookmarksByDomain 获取指定域名的所有书签
-func (r *DomainRepository) GetBookmarksByDomain(domain string) ([]model.Bookmark, error) {
+// GetBookmarksByDomain 分页获取指定域名的书签：主机名相同，或顶级域名相同（domain 为顶级域名时包括所有子域名）
+func (r *DomainRepository) GetBookmarksByDomain(userID int64, domain string, page, pageSize int) ([]model.Bookmark, int, error) {
+	// 子域名的顶级域名一定与其相同，因此顶级域名只需按 top_domain 查找，子域名只需按 host 查找
+	domain = util.NormalizeHost(domain)
+	column := "b.host"
+	if GetTopDomain(domain) == domain {
+		column = "b.top_domain"
+	}
+	where := "b.user_id = ? AND " + column + " = ? AND b.deleted_at IS NULL"
+	args := []interface{}{userID, domain}
+
+	var total int
+	if err := database.DB.QueryRow(`SELECT COUNT(*) FROM bookmarks b WHERE `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
 	rows, err := database.DB.Query(`
-		SELECT id, url, title, description, folder_path, favicon, created_at, updated_at
-		FROM bookmarks
-		WHERE (url LIKE ? OR url LIKE ?)
-		AND url NOT LIKE 'nibstash://folder-placeholder/%'
-		ORDER BY created_at DESC
-	`, "http://%"+domain+"%", "https://%"+domain+"%")
+		SELECT `+bookmarkSelectColumns+`
+		FROM `+bookmarkFromClause+`
+		WHERE `+where+`
+		ORDER BY b.created_at DESC, b.id DESC
+		LIMIT ? OFFSET ?
+	`, append(args, pageSize, (page-1)*pageSize)...)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
@@ -176,59 +324,31 @@ func (r *DomainRepository) GetBookmarksByDomain(domain string) ([]model.Bookmark
 			bookmarks = append(bookmarks, b)
 		}
 	}
-	return bookmarks, nil
+	return bookmarks, total, rows.Err()
 }
 
-// ExtractDomain 从URL中提取域名（导出供其他包使用）
-func ExtractDomain(url string) string {
-	var domain string
-	if len(url) > 8 && url[:8] == "https://" {
-		domain = url[8:]
-	} else if len(url) > 7 && url[:7] == "http://" {
-		domain = url[7:]
-	} else {
-		return ""
-	}
-
-	// 找到第一个 / 或字符串结束
-	for i := 0; i < len(domain); i++ {
-		if domain[i] == '/' || domain[i] == '?' || domain[i] == '#' {
-			domain = domain[:i]
-			break
-		}
-	}
+// bookmarkDomain 书签网址的主机名和顶级域名，非 http/https 网址都为空
+func bookmarkDomain(url string) (host, topDomain string) {
+	host = ExtractDomain(url)
+	return host, GetTopDomain(host)
+}
 
-	// 移除端口号
-	if idx := strings.Index(domain, ":"); idx != -1 {
-		domain = domain[:idx]
+// ExtractDomain 从 http/https 网址中提取主机名（导出供其他包使用），忽略用户信息和端口，
+// 结果经过 util.NormalizeHost 处理（小写，国际化域名为 Unicode 形式，IPv6 地址不带方括号）
+func ExtractDomain(rawURL string) string {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
 	}
-
-	return domain
+	return util.NormalizeHost(u.Hostname())
 }
 
-// GetTopDomain 获取顶级域名（导出供其他包使用）
+// GetTopDomain 按 Public Suffix List 获取可注册的顶级域名（导出供其他包使用），
+// 如 foo.github.io、bar.com.au；IP 地址和公共后缀本身原样返回
 func GetTopDomain(domain string) string {
-	parts := strings.Split(domain, ".")
-	if len(parts) < 2 {
+	domain = util.NormalizeHost(domain)
+	if domain == "" || net.ParseIP(domain) != nil {
 		return domain
 	}
-
-	// 常见的二级顶级域名
-	secondLevelTLDs := map[string]bool{
-		"com.cn": true, "net.cn": true, "org.cn": true, "gov.cn": true,
-		"co.uk": true, "co.jp": true, "co.kr": true,
-		"com.hk": true, "com.tw": true,
-	}
-
-	if len(parts) >= 3 {
-		possibleSecondLevel := parts[len(parts)-2] + "." + parts[len(parts)-1]
-		if secondLevelTLDs[possibleSecondLevel] {
-			if len(parts) >= 3 {
-				return parts[len(parts)-3] + "." + possibleSecondLevel
-			}
-			return possibleSecondLevel
-		}
-	}
-
-	return parts[len(parts)-2] + "." + parts[len(parts)-1]
+	return util.RegistrableDomain(domain)
 }
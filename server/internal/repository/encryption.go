@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"Nibstash_v2_server/database"
+	"Nibstash_v2_server/internal/model"
+	"Nibstash_v2_server/internal/util"
+	"database/sql"
+)
+
+type EncryptionRepository struct{}
+
+func NewEncryptionRepository() *EncryptionRepository {
+	return &EncryptionRepository{}
+}
+
+// ReencryptServerData 在一个事务中用当前服务器密钥重新加密两步验证密钥和未设置主密码的用户的凭证
+// 无法解密的数据保持原样，记录在报告中
+func (r *EncryptionRepository) ReencryptServerData() (*model.KeyRotationReport, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	report := newRotationReport(util.ServerKeyID())
+	if err := reencryptColumn(tx, "users", "totp_secret", "1 = 1", nil,
+		util.Decrypt, util.Encrypt, report); err != nil {
+		return nil, err
+	}
+	for _, column := range []string{"password", "totp_secret"} {
+		if err := reencryptColumn(tx, "credentials", column, "user_id NOT IN (SELECT user_id FROM vaults)", nil,
+			util.Decrypt, util.Encrypt, report); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func newRotationReport(kid string) *model.KeyRotationReport {
+	return &model.KeyRotationReport{KeyID: kid, Failed: []model.DecryptFailure{}, RetiredKeys: []string{}}
+}
+
+// reencryptColumn 解密 table 中满足 where 的行的 column 列并重新加密写回（跳过空值）
+// 无法解密的行保持原样，记录在报告中
+func reencryptColumn(tx *sql.Tx, table, column, where string, args []interface{},
+	decrypt, encrypt func(string) (string, error), report *model.KeyRotationReport) error {
+	rows, err := tx.Query(`SELECT id, `+column+` FROM `+table+` WHERE `+column+` != '' AND `+where, args...)
+	if err != nil {
+		return err
+	}
+
+	encrypted := map[int64]string{}
+	for rows.Next() {
+		var id int64
+		var ciphertext string
+		if err := rows.Scan(&id, &ciphertext); err != nil {
+			rows.Close()
+			return err
+		}
+		plaintext, err := decrypt(ciphertext)
+		if err != nil {
+			report.Failed = append(report.Failed, model.DecryptFailure{Table: table, ID: id, Error: err.Error()})
+			continue
+		}
+		if encrypted[id], err = encrypt(plaintext); err != nil {
+			rows.Close()
+			return err
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, ciphertext := range encrypted {
+		if _, err := tx.Exec(`UPDATE `+table+` SET `+column+` = ? WHERE id = ?`, ciphertext, id); err != nil {
+			return err
+		}
+	}
+	report.Reencrypted += len(encrypted)
+	return nil
+}
@@ -0,0 +1,252 @@
+package repository
+
+import (
+	"database/sql"
+	"strings"
+
+	"Nibstash_v2_server/database"
+	"Nibstash_v2_server/internal/model"
+)
+
+// globalEquivalentDomains 内置等价域名组，用户可以单独停用
+var globalEquivalentDomains = []model.EquivalentDomainGroup{
+	{Key: "google", Domains: []string{"google.com", "youtube.com", "gmail.com", "google.com.hk", "google.co.uk", "google.co.jp", "blogger.com"}},
+	{Key: "microsoft", Domains: []string{"microsoft.com", "live.com", "outlook.com", "office.com", "microsoft365.com", "xbox.com", "skype.com", "bing.com", "msn.com"}},
+	{Key: "apple", Domains: []string{"apple.com", "icloud.com"}},
+	{Key: "amazon", Domains: []string{"amazon.com", "amazon.co.uk", "amazon.co.jp", "amazon.de", "amazon.fr", "amazon.cn"}},
+	{Key: "alibaba", Domains: []string{"taobao.com", "tmall.com", "alipay.com", "1688.com", "aliyun.com", "alibaba.com"}},
+	{Key: "tencent", Domains: []string{"qq.com", "tencent.com", "wechat.com"}},
+	{Key: "netease", Domains: []string{"163.com", "126.com", "yeah.net", "netease.com"}},
+	{Key: "baidu", Domains: []string{"baidu.com", "hao123.com"}},
+	{Key: "jd", Domains: []string{"jd.com", "jd.hk"}},
+	{Key: "atlassian", Domains: []string{"atlassian.com", "atlassian.net", "bitbucket.org", "trello.com"}},
+	{Key: "steam", Domains: []string{"steampowered.com", "steamcommunity.com"}},
+	{Key: "sony", Domains: []string{"sony.com", "playstation.com"}},
+	{Key: "paypal", Domains: []string{"paypal.com", "paypal.me"}},
+	{Key: "yahoo", Domains: []string{"yahoo.com", "flickr.com"}},
+}
+
+// EquivalentDomainRepository 等价域名组：内置组和用户自定义的组
+type EquivalentDomainRepository struct{}
+
+func NewEquivalentDomainRepository() *EquivalentDomainRepository {
+	return &EquivalentDomainRepository{}
+}
+
+// List 获取内置组（含是否停用）和用户自定义的组
+func (r *EquivalentDomainRepository) List(userID int64) (*model.EquivalentDomainList, error) {
+	excluded, err := r.excludedKeys(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &model.EquivalentDomainList{
+		Global: make([]model.EquivalentDomainGroup, 0, len(globalEquivalentDomains)),
+		Custom: []model.EquivalentDomainGroup{},
+	}
+	for _, group := range globalEquivalentDomains {
+		group.Excluded = excluded[group.Key]
+		list.Global = append(list.Global, group)
+	}
+
+	rows, err := database.DB.Query(`SELECT id, domains FROM equivalent_domains WHERE user_id = ? ORDER BY id ASC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var group model.EquivalentDomainGroup
+		var domains string
+		if err := rows.Scan(&group.ID, &domains); err != nil {
+			return nil, err
+		}
+		group.Domains = strings.Fields(domains)
+		list.Custom = append(list.Custom, group)
+	}
+	return list, rows.Err()
+}
+
+// GetByID 获取用户自定义的组
+func (r *EquivalentDomainRepository) GetByID(userID, id int64) (*model.EquivalentDomainGroup, error) {
+	group := &model.EquivalentDomainGroup{}
+	var domains string
+	err := database.DB.QueryRow(`SELECT id, domains FROM equivalent_domains WHERE id = ? AND user_id = ?`, id, userID).Scan(&group.ID, &domains)
+	if err != nil {
+		return nil, err
+	}
+	group.Domains = strings.Fields(domains)
+	return group, nil
+}
+
+// Create 创建自定义组，domains 需先经过 NormalizeEquivalentDomains 处理
+func (r *EquivalentDomainRepository) Create(userID int64, domains []string) (*model.EquivalentDomainGroup, error) {
+	result, err := database.DB.Exec(`INSERT INTO equivalent_domains (user_id, domains) VALUES (?, ?)`, userID, strings.Join(domains, " "))
+	if err != nil {
+		return nil, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return r.GetByID(userID, id)
+}
+
+// Update 修改自定义组的域名
+func (r *EquivalentDomainRepository) Update(userID, id int64, domains []string) error {
+	result, err := database.DB.Exec(`UPDATE equivalent_domains SET domains = ? WHERE id = ? AND user_id = ?`, strings.Join(domains, " "), id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Delete 删除自定义组
+func (r *EquivalentDomainRepository) Delete(userID, id int64) error {
+	result, err := database.DB.Exec(`DELETE FROM equivalent_domains WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetExcluded 停用或重新启用内置组，key 不存在时返回 sql.ErrNoRows
+func (r *EquivalentDomainRepository) SetExcluded(userID int64, key string, excluded bool) error {
+	found := false
+	for _, group := range globalEquivalentDomains {
+		if group.Key == key {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return sql.ErrNoRows
+	}
+
+	var err error
+	if excluded {
+		_, err = database.DB.Exec(`INSERT OR IGNORE INTO excluded_equivalent_domains (user_id, group_key) VALUES (?, ?)`, userID, key)
+	} else {
+		_, err = database.DB.Exec(`DELETE FROM excluded_equivalent_domains WHERE user_id = ? AND group_key = ?`, userID, key)
+	}
+	return err
+}
+
+func (r *EquivalentDomainRepository) excludedKeys(userID int64) (map[string]bool, error) {
+	rows, err := database.DB.Query(`SELECT group_key FROM excluded_equivalent_domains WHERE user_id = ?`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	excluded := make(map[string]bool)
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		excluded[key] = true
+	}
+	return excluded, rows.Err()
+}
+
+// equivalentDomains 顶级域名到其所在等价域名组全部顶级域名的映射，不属于任何组的域名不在映射中
+type equivalentDomains map[string][]string
+
+// resolve 合并用户启用的内置组和自定义组，有共同域名的组合并为一组
+func (r *EquivalentDomainRepository) resolve(userID int64) (equivalentDomains, error) {
+	list, err := r.List(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 并查集：每个域名指向所在组的第一个域名
+	parent := make(map[string]string)
+	var find func(string) string
+	find = func(domain string) string {
+		if parent[domain] == domain {
+			return domain
+		}
+		root := find(parent[domain])
+		parent[domain] = root
+		return root
+	}
+	var order []string
+	for _, group := range append(list.Global, list.Custom...) {
+		if group.Excluded || len(group.Domains) == 0 {
+			continue
+		}
+		for _, domain := range group.Domains {
+			if _, ok := parent[domain]; !ok {
+				parent[domain] = domain
+				order = append(order, domain)
+			}
+		}
+		root := find(group.Domains[0])
+		for _, domain := range group.Domains[1:] {
+			if other := find(domain); other != root {
+				parent[other] = root
+			}
+		}
+	}
+
+	members := make(map[string][]string)
+	for _, domain := range order {
+		root := find(domain)
+		members[root] = append(members[root], domain)
+	}
+	equivalents := make(equivalentDomains)
+	for _, domain := range order {
+		equivalents[domain] = members[find(domain)]
+	}
+	return equivalents, nil
+}
+
+// of 返回与顶级域名等价的全部顶级域名（包括自身）
+func (e equivalentDomains) of(topDomain string) []string {
+	if group, ok := e[topDomain]; ok {
+		return group
+	}
+	return []string{topDomain}
+}
+
+// equivalent 两个顶级域名是否属于同一等价域名组
+func (e equivalentDomains) equivalent(a, b string) bool {
+	if a == b {
+		return true
+	}
+	for _, domain := range e[a] {
+		if domain == b {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeEquivalentDomains 把网址或主机名转换为小写的顶级域名并去重，忽略无效的域名
+func NormalizeEquivalentDomains(domains []string) []string {
+	seen := make(map[string]bool)
+	result := []string{}
+	for _, domain := range domains {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if strings.Contains(domain, "://") {
+			domain = ExtractDomain(domain)
+		}
+		domain = strings.TrimSuffix(domain, ".")
+		if !strings.Contains(domain, ".") || strings.ContainsAny(domain, " /?#:@") {
+			continue
+		}
+		domain = GetTopDomain(domain)
+		if !seen[domain] {
+			seen[domain] = true
+			result = append(result, domain)
+		}
+	}
+	return result
+}
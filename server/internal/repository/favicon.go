@@ -0,0 +1,211 @@
+package repository
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"time"
+
+	"Nibstash_v2_server/config"
+	"Nibstash_v2_server/database"
+	"Nibstash_v2_server/internal/model"
+)
+
+// FaviconRepository 服务器获取的网站图标：每个主机一条获取状态，图标内容按哈希去重
+type FaviconRepository struct{}
+
+func NewFaviconRepository() *FaviconRepository {
+	return &FaviconRepository{}
+}
+
+// EnqueueHosts 把书签中还没有记录的主机加入获取队列，返回新增的主机数
+func (r *FaviconRepository) EnqueueHosts() (int64, error) {
+	result, err := database.DB.Exec(`
+		INSERT OR IGNORE INTO favicon_hosts (host)
+		SELECT DISTINCT host FROM bookmarks WHERE host != '' AND deleted_at IS NULL
+	`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// DueHosts 获取已到重试或刷新时间的主机，最早到期的在前
+func (r *FaviconRepository) DueHosts(limit int) ([]model.FaviconHost, error) {
+	rows, err := database.DB.Query(`
+		SELECT host, attempts, blob_hash IS NOT NULL FROM favicon_hosts
+		WHERE next_attempt_at <= CURRENT_TIMESTAMP
+		ORDER BY next_attempt_at ASC LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hosts []model.FaviconHost
+	for rows.Next() {
+		var h model.FaviconHost
+		if err := rows.Scan(&h.Host, &h.Attempts, &h.HasIcon); err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts, rows.Err()
+}
+
+// SaveIcon 保存主机的图标，refreshAfter 后重新获取；替换下来的图标没有其他主机使用时删除，
+// 该主机下还没有图标的书签改为使用服务器缓存的图标
+func (r *FaviconRepository) SaveIcon(host, sourceURL, contentType string, data []byte, refreshAfter time.Duration) error {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var oldHash sql.NullString
+	tx.QueryRow(`SELECT blob_hash FROM favicon_hosts WHERE host = ?`, host).Scan(&oldHash)
+
+	if _, err := tx.Exec(`INSERT OR IGNORE INTO favicon_blobs (hash, content_type, data) VALUES (?, ?, ?)`, hash, contentType, data); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO favicon_hosts (host, blob_hash, source_url, attempts, last_error, next_attempt_at, fetched_at)
+		VALUES (?, ?, ?, 0, '', datetime('now', ?), CURRENT_TIMESTAMP)
+		ON CONFLICT(host) DO UPDATE SET blob_hash = excluded.blob_hash, source_url = excluded.source_url, attempts = 0,
+			last_error = '', next_attempt_at = excluded.next_attempt_at, fetched_at = excluded.fetched_at
+	`, host, hash, sourceURL, sqliteSeconds(refreshAfter)); err != nil {
+		return err
+	}
+	if oldHash.Valid && oldHash.String != hash {
+		if _, err := tx.Exec(`
+			DELETE FROM favicon_blobs WHERE hash = ? AND NOT EXISTS (SELECT 1 FROM favicon_hosts WHERE blob_hash = ?)
+		`, oldHash.String, oldHash.String); err != nil {
+			return err
+		}
+	}
+	if _, err := applyIcon(tx, host); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ApplyCachedIcons 已缓存图标的主机下新增的、还没有图标的书签改为使用服务器缓存的图标，返回更新的书签数
+func (r *FaviconRepository) ApplyCachedIcons() (int64, error) {
+	rows, err := database.DB.Query(`
+		SELECT DISTINCT b.host FROM bookmarks b
+		JOIN favicon_hosts h ON h.host = b.host AND h.blob_hash IS NOT NULL
+		WHERE (b.favicon = '' OR b.favicon IS NULL) AND b.deleted_at IS NULL
+	`)
+	if err != nil {
+		return 0, err
+	}
+	var hosts []string
+	for rows.Next() {
+		var host string
+		if err := rows.Scan(&host); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		hosts = append(hosts, host)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, host := range hosts {
+		n, err := applyIcon(database.DB, host)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// applyIcon 主机下还没有图标的书签改为使用服务器缓存的图标（地址按用户签名），返回更新的书签数
+func applyIcon(q dbExecutor, host string) (int64, error) {
+	rows, err := q.Query(`SELECT DISTINCT user_id FROM bookmarks WHERE host = ? AND (favicon = '' OR favicon IS NULL)`, host)
+	if err != nil {
+		return 0, err
+	}
+	var userIDs []int64
+	for rows.Next() {
+		var userID int64
+		if err := rows.Scan(&userID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, userID := range userIDs {
+		result, err := q.Exec(`
+			UPDATE bookmarks SET favicon = ? WHERE user_id = ? AND host = ? AND (favicon = '' OR favicon IS NULL)
+		`, FaviconURL(userID, host), userID, host)
+		if err != nil {
+			return total, err
+		}
+		n, _ := result.RowsAffected()
+		total += n
+	}
+	return total, nil
+}
+
+// FaviconURL 书签使用的服务器缓存图标地址。<img> 无法携带访问令牌，地址中带有用户 ID 和签名，
+// 只有服务器为该用户生成的地址才能取得图标，其他人无法借此探测用户收藏了哪些网站
+func FaviconURL(userID int64, host string) string {
+	return fmt.Sprintf("%s%s?u=%d&sig=%s", model.FaviconURLPrefix, url.PathEscape(host), userID, faviconSignature(userID, host))
+}
+
+// ValidFaviconSignature 校验图标地址中的签名
+func ValidFaviconSignature(userID int64, host, sig string) bool {
+	return hmac.Equal([]byte(sig), []byte(faviconSignature(userID, host)))
+}
+
+func faviconSignature(userID int64, host string) string {
+	mac := hmac.New(sha256.New, []byte(config.App.FaviconSecret))
+	fmt.Fprintf(mac, "%d\n%s", userID, host)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// RecordFailure 记录获取失败，retryAfter 后重试；已有的图标保留
+func (r *FaviconRepository) RecordFailure(host, message string, retryAfter time.Duration) error {
+	_, err := database.DB.Exec(`
+		UPDATE favicon_hosts SET attempts = attempts + 1, last_error = ?, next_attempt_at = datetime('now', ?)
+		WHERE host = ?
+	`, message, sqliteSeconds(retryAfter), host)
+	return err
+}
+
+// GetByHost 获取用户书签中主机的图标（包括回收站中的书签），没有图标或用户没有该主机的书签时返回 sql.ErrNoRows
+func (r *FaviconRepository) GetByHost(userID int64, host string) (*model.Favicon, error) {
+	icon := &model.Favicon{}
+	err := database.DB.QueryRow(`
+		SELECT b.hash, b.content_type, b.data
+		FROM favicon_hosts h JOIN favicon_blobs b ON b.hash = h.blob_hash
+		WHERE h.host = ? AND EXISTS (SELECT 1 FROM bookmarks WHERE user_id = ? AND host = ?)
+	`, host, userID, host).Scan(&icon.Hash, &icon.ContentType, &icon.Data)
+	if err != nil {
+		return nil, err
+	}
+	return icon, nil
+}
+
+// sqliteSeconds SQLite datetime() 的时间偏移参数
+func sqliteSeconds(d time.Duration) string {
+	return fmt.Sprintf("+%d seconds", int64(d/time.Second))
+}
@@ -3,7 +3,8 @@ package repository
 import (
 	"Nibstash_v2_server/database"
 	"Nibstash_v2_server/internal/model"
-	"sort"
+	"Nibstash_v2_server/internal/util"
+	"database/sql"
 	"strings"
 )
 
@@ -13,126 +14,207 @@ func NewFolderRepository() *FolderRepository {
 	return &FolderRepository{}
 }
 
-// GetFolderTree 获取文件夹树结构
-func (r *FolderRepository) GetFolderTree() ([]model.FolderNode, error) {
-	// 获取所有文件夹路径及其书签数量
-	rows, err := database.DB.Query(`
-		SELECT folder_path, COUNT(*) as count
-		FROM bookmarks
-		WHERE url NOT LIKE 'nibstash://folder-placeholder/%'
-		GROUP BY folder_path
-	`)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+// dbExecutor *sql.DB 和 *sql.Tx 的公共方法，便于同一逻辑在事务内外复用
+type dbExecutor interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
 
-	pathCounts := make(map[string]int)
-	for rows.Next() {
-		var path string
-		var count int
-		if err := rows.Scan(&path, &count); err == nil {
-			pathCounts[path] = count
-		}
+// nullableFolderID 根目录（ID 为 0）在数据库中存为 NULL
+func nullableFolderID(id int64) interface{} {
+	if id == 0 {
+		return nil
 	}
+	return id
+}
 
-	// 构建所有节点（使用指针）
-	nodeMap := make(map[string]*model.FolderNode)
-
-	for path := range pathCounts {
-		if path == "" {
-			continue
+// normalizeFolderPath 去掉多余的斜杠和空白，如 " 工作//后台/ " -> "工作/后台"
+func normalizeFolderPath(path string) string {
+	var parts []string
+	for _, part := range strings.Split(path, "/") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
 		}
+	}
+	return strings.Join(parts, "/")
+}
 
-		parts := strings.Split(path, "/")
-		currentPath := ""
+// ensureFolderPath 按路径逐级查找或创建用户的文件夹，返回最末级文件夹 ID（根目录返回 0）
+func ensureFolderPath(q dbExecutor, userID int64, path string) (int64, error) {
+	path = normalizeFolderPath(path)
+	if path == "" {
+		return 0, nil
+	}
 
-		for i, part := range parts {
-			if i > 0 {
-				currentPath += "/"
+	var parentID int64
+	names := strings.Split(path, "/")
+	for i, name := range names {
+		id, err := findChildFolder(q, userID, parentID, name)
+		if err == sql.ErrNoRows {
+			position, err := nextFolderPosition(q, userID, parentID)
+			if err != nil {
+				return 0, err
 			}
-			currentPath += part
-
-			if _, exists := nodeMap[currentPath]; !exists {
-				nodeMap[currentPath] = &model.FolderNode{
-					Name:     part,
-					Path:     currentPath,
-					Count:    0,
-					Children: []model.FolderNode{},
-				}
+			result, err := q.Exec(`INSERT INTO folders (user_id, parent_id, name, position, pinyin) VALUES (?, ?, ?, ?, ?)`,
+				userID, nullableFolderID(parentID), name, position, util.PathPinyinIndex(strings.Join(names[:i+1], "/")))
+			if err != nil {
+				return 0, err
 			}
+			if id, err = result.LastInsertId(); err != nil {
+				return 0, err
+			}
+		} else if err != nil {
+			return 0, err
 		}
+		parentID = id
+	}
+	return parentID, nil
+}
+
+// nextFolderPosition 返回 parentID（0 表示根目录）下新文件夹的排序位置，新建或移入的文件夹排在最后
+func nextFolderPosition(q dbExecutor, userID, parentID int64) (int, error) {
+	var position int
+	err := q.QueryRow(`SELECT IFNULL(MAX(position), -1) + 1 FROM folders WHERE user_id = ? AND IFNULL(parent_id, 0) = ? AND deleted_at IS NULL`,
+		userID, parentID).Scan(&position)
+	return position, err
+}
+
+// moveFolderTo 把文件夹挂到 parentID（0 表示根目录）下，排在最后
+func moveFolderTo(tx *sql.Tx, userID, folderID, parentID int64) error {
+	position, err := nextFolderPosition(tx, userID, parentID)
+	if err != nil {
+		return err
 	}
+	_, err = tx.Exec(`UPDATE folders SET parent_id = ?, position = ? WHERE id = ?`, nullableFolderID(parentID), position, folderID)
+	return err
+}
 
-	// 设置每个节点的书签数量
-	for path, count := range pathCounts {
-		if node, exists := nodeMap[path]; exists {
-			node.Count = count
-		}
+// findFolderID 按路径查找用户未删除的文件夹 ID，根目录返回 0，不存在返回 sql.ErrNoRows
+func findFolderID(q dbExecutor, userID int64, path string) (int64, error) {
+	path = normalizeFolderPath(path)
+	if path == "" {
+		return 0, nil
 	}
 
-	// 构建父子关系 - 先收集所有根节点和子节点关系
-	rootNodes := []*model.FolderNode{}
-	childrenMap := make(map[string][]*model.FolderNode)
+	var id int64
+	err := q.QueryRow(`SELECT id FROM folder_paths WHERE user_id = ? AND path = ? AND deleted_at IS NULL`, userID, path).Scan(&id)
+	return id, err
+}
 
-	for path, node := range nodeMap {
-		parentPath := getParentPath(path)
-		if parentPath == "" {
-			rootNodes = append(rootNodes, node)
-		} else {
-			childrenMap[parentPath] = append(childrenMap[parentPath], node)
+// findChildFolder 查找用户在 parentID（0 表示根目录）下未删除的同名文件夹，不存在返回 sql.ErrNoRows
+func findChildFolder(q dbExecutor, userID, parentID int64, name string) (int64, error) {
+	var id int64
+	err := q.QueryRow(`SELECT id FROM folders WHERE user_id = ? AND IFNULL(parent_id, 0) = ? AND name = ? AND deleted_at IS NULL`,
+		userID, parentID, name).Scan(&id)
+	return id, err
+}
+
+// syncFolderPinyin 按当前路径重新生成用户所有文件夹（包括回收站中的）的拼音索引，只更新有变化的
+// 移动、合并和恢复文件夹会改变子文件夹的路径，这些操作之后需要调用
+func syncFolderPinyin(q dbExecutor, userID int64) error {
+	rows, err := q.Query(`
+		SELECT f.id, fp.path, f.pinyin FROM folders f JOIN folder_paths fp ON fp.id = f.id
+		WHERE f.user_id = ?
+	`, userID)
+	if err != nil {
+		return err
+	}
+	changed := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var path string
+		var pinyin sql.NullString
+		if err := rows.Scan(&id, &path, &pinyin); err != nil {
+			rows.Close()
+			return err
+		}
+		if index := util.PathPinyinIndex(path); !pinyin.Valid || pinyin.String != index {
+			changed[id] = index
 		}
 	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
 
-	// 递归构建树
-	var buildTree func(node *model.FolderNode)
-	buildTree = func(node *model.FolderNode) {
-		children := childrenMap[node.Path]
-		for _, child := range children {
-			buildTree(child)
-			node.Children = append(node.Children, *child)
+	for id, index := range changed {
+		if _, err := q.Exec(`UPDATE folders SET pinyin = ? WHERE id = ?`, index, id); err != nil {
+			return err
 		}
-		// 排序子节点
-		sort.Slice(node.Children, func(i, j int) bool {
-			return node.Children[i].Name < node.Children[j].Name
-		})
 	}
+	return nil
+}
 
-	// 构建每个根节点的子树
-	result := []model.FolderNode{}
-	for _, root := range rootNodes {
-		buildTree(root)
-		result = append(result, *root)
+// SyncPinyin 为尚未生成拼音索引的文件夹补全 pinyin（用于升级后的首次启动）
+func (r *FolderRepository) SyncPinyin() error {
+	rows, err := database.DB.Query(`SELECT DISTINCT user_id FROM folders WHERE pinyin IS NULL`)
+	if err != nil {
+		return err
 	}
+	var userIDs []int64
+	for rows.Next() {
+		var userID int64
+		if err := rows.Scan(&userID); err == nil {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	rows.Close()
 
-	// 排序根节点
-	sort.Slice(result, func(i, j int) bool {
-		return result[i].Name < result[j].Name
-	})
-
-	return result, nil
+	for _, userID := range userIDs {
+		if err := syncFolderPinyin(database.DB, userID); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func getParentPath(path string) string {
-	idx := strings.LastIndex(path, "/")
-	if idx == -1 {
-		return ""
+// GetFolderTree 获取文件夹树结构
+func (r *FolderRepository) GetFolderTree(userID int64) ([]model.FolderNode, error) {
+	rows, err := database.DB.Query(`
+		SELECT f.id, IFNULL(f.parent_id, 0), f.name, fp.path,
+			(SELECT COUNT(*) FROM bookmarks b WHERE b.folder_id = f.id AND b.deleted_at IS NULL) AS count
+		FROM folders f
+		JOIN folder_paths fp ON fp.id = f.id
+		WHERE f.user_id = ? AND f.deleted_at IS NULL
+		ORDER BY f.position, f.name
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	nodeMap := make(map[int64]*model.FolderNode)
+	childrenMap := make(map[int64][]int64) // parent_id -> 子文件夹 ID（已排序）
+	for rows.Next() {
+		var id, parentID int64
+		node := &model.FolderNode{Children: []model.FolderNode{}}
+		if err := rows.Scan(&id, &parentID, &node.Name, &node.Path, &node.Count); err != nil {
+			continue
+		}
+		node.Pinyin = util.PathPinyinIndex(node.Path)
+		nodeMap[id] = node
+		childrenMap[parentID] = append(childrenMap[parentID], id)
 	}
-	return path[:idx]
-}
 
-func sortFolderNodes(nodes []model.FolderNode) {
-	sort.Slice(nodes, func(i, j int) bool {
-		return nodes[i].Name < nodes[j].Name
-	})
-	for i := range nodes {
-		sortFolderNodes(nodes[i].Children)
+	// 递归构建树
+	var buildTree func(parentID int64) []model.FolderNode
+	buildTree = func(parentID int64) []model.FolderNode {
+		result := []model.FolderNode{}
+		for _, id := range childrenMap[parentID] {
+			node := nodeMap[id]
+			node.Children = buildTree(id)
+			result = append(result, *node)
+		}
+		return result
 	}
+
+	return buildTree(0), nil
 }
 
 // ListPaths 获取所有文件夹路径
-func (r *FolderRepository) ListPaths() ([]string, error) {
-	rows, err := database.DB.Query(`SELECT DISTINCT folder_path FROM bookmarks WHERE folder_path != '' ORDER BY folder_path`)
+func (r *FolderRepository) ListPaths(userID int64) ([]string, error) {
+	rows, err := database.DB.Query(`SELECT path FROM folder_paths WHERE user_id = ? AND deleted_at IS NULL ORDER BY path`, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -148,168 +230,236 @@ func (r *FolderRepository) ListPaths() ([]string, error) {
 	return paths, nil
 }
 
-// Create 创建文件夹（通过创建占位书签）
-func (r *FolderRepository) Create(path string) error {
-	// 检查是否已存在
-	var count int
-	database.DB.QueryRow(`SELECT COUNT(*) FROM bookmarks WHERE folder_path = ?`, path).Scan(&count)
-	if count > 0 {
-		return nil // 已存在
-	}
-
-	// 创建占位书签
-	_, err := database.DB.Exec(`
-		INSERT INTO bookmarks (url, title, description, folder_path, favicon)
-		VALUES (?, ?, '', ?, '')
-	`, "nibstash://folder-placeholder/"+path, path, path)
+// Create 创建文件夹（自动创建缺失的上级文件夹）
+func (r *FolderRepository) Create(userID int64, path string) error {
+	_, err := ensureFolderPath(database.DB, userID, path)
 	return err
 }
 
-// Move 移动文件夹
-func (r *FolderRepository) Move(sourceFolder, targetFolder string) error {
+// Move 移动文件夹到目标文件夹下（目标下已有同名文件夹时合并）
+func (r *FolderRepository) Move(userID int64, sourceFolder, targetFolder string) error {
+	sourceFolder = normalizeFolderPath(sourceFolder)
+	targetFolder = normalizeFolderPath(targetFolder)
 	if sourceFolder == "" {
 		return nil
 	}
 
-	parts := strings.Split(sourceFolder, "/")
-	folderName := parts[len(parts)-1]
-
-	var newFolderBase string
-	if targetFolder == "" {
-		newFolderBase = folderName
-	} else {
-		newFolderBase = targetFolder + "/" + folderName
+	// 不能移动到自身或自己的子文件夹下
+	if targetFolder == sourceFolder || strings.HasPrefix(targetFolder, sourceFolder+"/") {
+		return nil
 	}
 
-	if sourceFolder == newFolderBase {
+	sourceID, err := findFolderID(database.DB, userID, sourceFolder)
+	if err == sql.ErrNoRows {
 		return nil
 	}
+	if err != nil {
+		return err
+	}
 
-	if strings.HasPrefix(newFolderBase, sourceFolder+"/") {
-		return nil
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback()
 
-	// 更新精确匹配源文件夹的书签
-	_, err := database.DB.Exec(`UPDATE OR IGNORE bookmarks SET folder_path = ?, updated_at = CURRENT_TIMESTAMP WHERE folder_path = ?`,
-		newFolderBase, sourceFolder)
+	targetID, err := ensureFolderPath(tx, userID, targetFolder)
 	if err != nil {
 		return err
 	}
 
-	// 更新源文件夹子目录下的书签
-	oldPrefix := sourceFolder + "/"
-	newPrefix := newFolderBase + "/"
+	var parentID int64
+	var name string
+	if err := tx.QueryRow(`SELECT IFNULL(parent_id, 0), name FROM folders WHERE id = ?`, sourceID).Scan(&parentID, &name); err != nil {
+		return err
+	}
+	if parentID == targetID {
+		return nil
+	}
 
-	_, err = database.DB.Exec(`UPDATE OR IGNORE bookmarks SET folder_path = ? || SUBSTR(folder_path, ?), updated_at = CURRENT_TIMESTAMP WHERE folder_path LIKE ?`,
-		newPrefix, len(oldPrefix)+1, oldPrefix+"%")
+	existingID, err := findChildFolder(tx, userID, targetID, name)
+	switch {
+	case err == sql.ErrNoRows:
+		err = moveFolderTo(tx, userID, sourceID, targetID)
+	case err == nil:
+		err = mergeFolder(tx, userID, sourceID, existingID, trashStamp())
+	}
+	if err != nil {
+		return err
+	}
+	if err := syncFolderPinyin(tx, userID); err != nil {
+		return err
+	}
 
-	return err
+	return tx.Commit()
 }
 
-// Merge 合并文件夹
-func (r *FolderRepository) Merge(sourceFolder, targetFolder string) error {
-	if sourceFolder == "" {
+// Merge 合并文件夹：源文件夹的书签和子文件夹并入目标文件夹，然后删除源文件夹
+func (r *FolderRepository) Merge(userID int64, sourceFolder, targetFolder string) error {
+	sourceFolder = normalizeFolderPath(sourceFolder)
+	targetFolder = normalizeFolderPath(targetFolder)
+	if sourceFolder == "" || sourceFolder == targetFolder {
 		return nil
 	}
 
-	if sourceFolder == targetFolder {
+	if strings.HasPrefix(targetFolder, sourceFolder+"/") {
 		return nil
 	}
 
-	if targetFolder != "" && strings.HasPrefix(targetFolder, sourceFolder+"/") {
+	sourceID, err := findFolderID(database.DB, userID, sourceFolder)
+	if err == sql.ErrNoRows {
 		return nil
 	}
+	if err != nil {
+		return err
+	}
 
-	// 先尝试更新，冲突的会被跳过
-	_, err := database.DB.Exec(`UPDATE OR IGNORE bookmarks SET folder_path = ?, updated_at = CURRENT_TIMESTAMP WHERE folder_path = ?`,
-		targetFolder, sourceFolder)
+	tx, err := database.DB.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	// 删除源文件夹中剩余的书签
-	_, err = database.DB.Exec(`DELETE FROM bookmarks WHERE folder_path = ?`, sourceFolder)
+	targetID, err := ensureFolderPath(tx, userID, targetFolder)
 	if err != nil {
 		return err
 	}
 
-	// 处理子文件夹
-	oldPrefix := sourceFolder + "/"
-	var newPrefix string
-	if targetFolder == "" {
-		newPrefix = ""
-	} else {
-		newPrefix = targetFolder + "/"
+	if err := mergeFolder(tx, userID, sourceID, targetID, trashStamp()); err != nil {
+		return err
+	}
+	if err := syncFolderPinyin(tx, userID); err != nil {
+		return err
 	}
 
-	rows, err := database.DB.Query(`SELECT DISTINCT folder_path FROM bookmarks WHERE folder_path LIKE ?`, oldPrefix+"%")
-	if err != nil {
+	return tx.Commit()
+}
+
+// mergeFolder 将 sourceID 合并到 targetID（0 表示根目录）
+// 目标中已存在的同网址书签移入回收站（删除时间为 stamp），回收站中的书签和子文件夹随之挂到目标下
+func mergeFolder(tx *sql.Tx, userID, sourceID, targetID int64, stamp string) error {
+	if _, err := tx.Exec(`UPDATE OR IGNORE bookmarks SET folder_id = ?, updated_at = CURRENT_TIMESTAMP WHERE folder_id = ?`,
+		nullableFolderID(targetID), sourceID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE bookmarks SET folder_id = ?, deleted_at = ? WHERE folder_id = ? AND deleted_at IS NULL`,
+		nullableFolderID(targetID), stamp, sourceID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE bookmarks SET folder_id = ? WHERE folder_id = ?`, nullableFolderID(targetID), sourceID); err != nil {
 		return err
 	}
 
-	var subFolders []string
+	// 处理子文件夹：目标下有同名文件夹则递归合并，否则直接挂到目标下
+	// 按原顺序处理，移入的子文件夹依次排在目标的最后
+	rows, err := tx.Query(`SELECT id, name FROM folders WHERE parent_id = ? AND deleted_at IS NULL ORDER BY position, name`, sourceID)
+	if err != nil {
+		return err
+	}
+	type child struct {
+		id   int64
+		name string
+	}
+	var children []child
 	for rows.Next() {
-		var path string
-		if err := rows.Scan(&path); err == nil {
-			subFolders = append(subFolders, path)
+		var c child
+		if err := rows.Scan(&c.id, &c.name); err == nil {
+			children = append(children, c)
 		}
 	}
 	rows.Close()
 
-	for _, oldPath := range subFolders {
-		var newPath string
-		if newPrefix == "" {
-			newPath = strings.TrimPrefix(oldPath, oldPrefix)
-		} else {
-			newPath = newPrefix + strings.TrimPrefix(oldPath, oldPrefix)
+	for _, c := range children {
+		existingID, err := findChildFolder(tx, userID, targetID, c.name)
+		switch {
+		case err == sql.ErrNoRows:
+			err = moveFolderTo(tx, userID, c.id, targetID)
+		case err == nil:
+			err = mergeFolder(tx, userID, c.id, existingID, stamp)
 		}
-
-		_, err = database.DB.Exec(`UPDATE OR IGNORE bookmarks SET folder_path = ?, updated_at = CURRENT_TIMESTAMP WHERE folder_path = ?`,
-			newPath, oldPath)
 		if err != nil {
 			return err
 		}
+	}
 
-		_, err = database.DB.Exec(`DELETE FROM bookmarks WHERE folder_path = ?`, oldPath)
-		if err != nil {
-			return err
-		}
+	// 回收站中的子文件夹不参与唯一约束，直接挂到目标下
+	if _, err := tx.Exec(`UPDATE folders SET parent_id = ? WHERE parent_id = ?`, nullableFolderID(targetID), sourceID); err != nil {
+		return err
 	}
 
-	return nil
+	// 源文件夹此时已为空，直接删除
+	_, err = tx.Exec(`DELETE FROM folders WHERE id = ?`, sourceID)
+	return err
 }
 
-// Delete 删除文件夹及其所有书签
-func (r *FolderRepository) Delete(folderPath string) error {
-	if folderPath == "" {
-		_, err := database.DB.Exec(`DELETE FROM bookmarks WHERE folder_path = ''`)
+// trashFolder 将文件夹及其子文件夹、书签一并移入回收站
+func trashFolder(q dbExecutor, folderID int64, stamp string) error {
+	const subtree = `
+		WITH RECURSIVE sub(id) AS (
+			SELECT ?
+			UNION ALL
+			SELECT f.id FROM folders f JOIN sub ON f.parent_id = sub.id WHERE f.deleted_at IS NULL
+		)`
+	if _, err := q.Exec(subtree+`
+		UPDATE bookmarks SET deleted_at = ? WHERE deleted_at IS NULL AND folder_id IN (SELECT id FROM sub)
+	`, folderID, stamp); err != nil {
 		return err
 	}
-	_, err := database.DB.Exec(`DELETE FROM bookmarks WHERE folder_path = ? OR folder_path LIKE ?`, folderPath, folderPath+"/%")
+	_, err := q.Exec(subtree+`
+		UPDATE folders SET deleted_at = ? WHERE deleted_at IS NULL AND id IN (SELECT id FROM sub)
+	`, folderID, stamp)
 	return err
 }
 
+// Delete 将文件夹及其子文件夹、书签移入回收站
+func (r *FolderRepository) Delete(userID int64, folderPath string) error {
+	folderID, err := findFolderID(database.DB, userID, folderPath)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if folderID == 0 {
+		_, err := database.DB.Exec(`UPDATE bookmarks SET deleted_at = ? WHERE user_id = ? AND folder_id IS NULL AND deleted_at IS NULL`, trashStamp(), userID)
+		return err
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := trashFolder(tx, folderID, trashStamp()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
 // HasUncategorized 检查是否有未分类书签
-func (r *FolderRepository) HasUncategorized() bool {
+func (r *FolderRepository) HasUncategorized(userID int64) bool {
 	var count int
-	database.DB.QueryRow(`SELECT COUNT(*) FROM bookmarks WHERE folder_path = '' AND url NOT LIKE 'nibstash://folder-placeholder/%'`).Scan(&count)
+	database.DB.QueryRow(`SELECT COUNT(*) FROM bookmarks WHERE user_id = ? AND folder_id IS NULL AND deleted_at IS NULL`, userID).Scan(&count)
 	return count > 0
 }
 
-// GetAllPaths 获取所有文件夹路径（用于 bookmarklet）
-func (r *FolderRepository) GetAllPaths() []string {
-	rows, err := database.DB.Query(`SELECT DISTINCT folder_path FROM bookmarks WHERE folder_path != '' ORDER BY folder_path`)
-	if err != nil {
-		return []string{}
+// GetOptions 获取所有文件夹路径及其拼音索引（用于 bookmarklet 文件夹选择器）
+func (r *FolderRepository) GetOptions(userID int64) []model.FolderOption {
+	paths := r.GetAllPaths(userID)
+	options := make([]model.FolderOption, 0, len(paths))
+	for _, path := range paths {
+		options = append(options, model.FolderOption{Path: path, Pinyin: util.PathPinyinIndex(path)})
 	}
-	defer rows.Close()
+	return options
+}
 
-	var paths []string
-	for rows.Next() {
-		var path string
-		if err := rows.Scan(&path); err == nil {
-			paths = append(paths, path)
-		}
+// GetAllPaths 获取所有文件夹路径（用于 bookmarklet）
+func (r *FolderRepository) GetAllPaths(userID int64) []string {
+	paths, err := r.ListPaths(userID)
+	if err != nil || paths == nil {
+		return []string{}
 	}
 	return paths
 }
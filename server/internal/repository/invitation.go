@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"Nibstash_v2_server/database"
+	"Nibstash_v2_server/internal/model"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"time"
+)
+
+type InvitationRepository struct{}
+
+func NewInvitationRepository() *InvitationRepository {
+	return &InvitationRepository{}
+}
+
+// Create 生成邀请码，返回记录和明文（明文不入库，和 API Token 一样只保存哈希）
+func (r *InvitationRepository) Create(createdBy int64, expiresInDays int) (*model.Invitation, string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, "", err
+	}
+	code := base64.RawURLEncoding.EncodeToString(buf)
+
+	var expiresAt interface{}
+	if expiresInDays > 0 {
+		expiresAt = time.Now().UTC().AddDate(0, 0, expiresInDays)
+	}
+
+	result, err := database.DB.Exec(`
+		INSERT INTO invitations (code_hash, prefix, created_by, expires_at) VALUES (?, ?, ?, ?)
+	`, hashToken(code), code[:6], createdBy, expiresAt)
+	if err != nil {
+		return nil, "", err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, "", err
+	}
+
+	invitation, err := scanInvitation(database.DB.QueryRow(`
+		SELECT id, prefix, created_by, expires_at, used_by, used_at, created_at
+		FROM invitations WHERE id = ?
+	`, id))
+	if err != nil {
+		return nil, "", err
+	}
+	return invitation, code, nil
+}
+
+// List 获取所有邀请码
+func (r *InvitationRepository) List() ([]model.Invitation, error) {
+	rows, err := database.DB.Query(`
+		SELECT id, prefix, created_by, expires_at, used_by, used_at, created_at
+		FROM invitations ORDER BY created_at DESC, id DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invitations []model.Invitation
+	for rows.Next() {
+		if inv, err := scanInvitation(rows); err == nil {
+			invitations = append(invitations, *inv)
+		}
+	}
+	return invitations, nil
+}
+
+// Delete 删除邀请码（已使用的邀请码删除后不影响已注册的用户）
+func (r *InvitationRepository) Delete(id int64) error {
+	result, err := database.DB.Exec(`DELETE FROM invitations WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func scanInvitation(row rowScanner) (*model.Invitation, error) {
+	inv := &model.Invitation{}
+	var expiresAt, usedAt sql.NullTime
+	var usedBy sql.NullInt64
+	if err := row.Scan(&inv.ID, &inv.Prefix, &inv.CreatedBy, &expiresAt, &usedBy, &usedAt, &inv.CreatedAt); err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid {
+		inv.ExpiresAt = &expiresAt.Time
+	}
+	if usedBy.Valid {
+		inv.UsedBy = &usedBy.Int64
+	}
+	if usedAt.Valid {
+		inv.UsedAt = &usedAt.Time
+	}
+	return inv, nil
+}
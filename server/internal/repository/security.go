@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"Nibstash_v2_server/database"
+	"Nibstash_v2_server/internal/model"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// 登录限流：在 loginFailureWindow 内按账号和 IP 分别统计失败次数
+// 超过免费次数后每次失败的等待时间翻倍（指数退避），达到上限后锁定 LoginLockoutDuration
+const (
+	loginFailureWindow   = 15 * time.Minute
+	loginBackoffBase     = time.Second
+	LoginLockoutDuration = 15 * time.Minute
+
+	// SecurityEventRetention 安全事件保留期限
+	SecurityEventRetention = 90 * 24 * time.Hour
+)
+
+// throttlePolicy 限流策略；IP 可能是多人共用的出口，阈值比账号宽松
+type throttlePolicy struct {
+	column          string
+	freeAttempts    int
+	lockoutAttempts int
+}
+
+var (
+	accountThrottle = throttlePolicy{column: "username", freeAttempts: 3, lockoutAttempts: 10}
+	ipThrottle      = throttlePolicy{column: "ip", freeAttempts: 10, lockoutAttempts: 50}
+)
+
+// delay 失败 failures 次后需要等待的时间（从最近一次失败开始计算）
+func (p throttlePolicy) delay(failures int) time.Duration {
+	switch {
+	case failures >= p.lockoutAttempts:
+		return LoginLockoutDuration
+	case failures >= p.freeAttempts:
+		// 先限制指数再移位，移位过多会溢出成负数（等于不限流）
+		n := failures - p.freeAttempts
+		if n >= 20 {
+			return LoginLockoutDuration
+		}
+		if d := loginBackoffBase << n; d < LoginLockoutDuration {
+			return d
+		}
+		return LoginLockoutDuration
+	}
+	return 0
+}
+
+type SecurityRepository struct{}
+
+func NewSecurityRepository() *SecurityRepository {
+	return &SecurityRepository{}
+}
+
+// Record 记录安全事件，userID 为 0 表示用户不存在
+func (r *SecurityRepository) Record(event string, userID int64, username, ip, userAgent string) error {
+	var uid interface{}
+	if userID > 0 {
+		uid = userID
+	}
+	_, err := database.DB.Exec(`
+		INSERT INTO security_events (user_id, username, event, ip, user_agent) VALUES (?, ?, ?, ?, ?)
+	`, uid, username, event, ip, userAgent)
+	return err
+}
+
+// RecordFailure 记录登录或验证码失败，失败次数刚达到锁定阈值时额外记录账号锁定事件
+func (r *SecurityRepository) RecordFailure(event string, userID int64, username, ip, userAgent string) error {
+	if err := r.Record(event, userID, username, ip, userAgent); err != nil {
+		return err
+	}
+	failures, _, err := r.recentFailures(accountThrottle, username)
+	if err != nil {
+		return err
+	}
+	if failures == accountThrottle.lockoutAttempts {
+		return r.Record(model.SecurityEventAccountLocked, userID, username, ip, userAgent)
+	}
+	return nil
+}
+
+// LoginRetryAfter 账号或 IP 当前需要等待的时间，返回 0 表示可以尝试
+func (r *SecurityRepository) LoginRetryAfter(ip, username string) (time.Duration, error) {
+	var wait time.Duration
+	for _, check := range []struct {
+		policy throttlePolicy
+		value  string
+	}{{accountThrottle, username}, {ipThrottle, ip}} {
+		failures, lastFailure, err := r.recentFailures(check.policy, check.value)
+		if err != nil {
+			return 0, err
+		}
+		if d := time.Until(lastFailure.Add(check.policy.delay(failures))); d > wait {
+			wait = d
+		}
+	}
+	return wait, nil
+}
+
+// recentFailures 统计窗口期内的失败次数和最近一次失败时间
+// 账号的计数在登录成功或重置密码后清零；IP 的计数不清零，避免攻击者用自己的账号登录来重置
+func (r *SecurityRepository) recentFailures(policy throttlePolicy, value string) (int, time.Time, error) {
+	resetClause := ""
+	args := []interface{}{value, model.SecurityEventLoginFailed, model.SecurityEventTOTPFailed, model.SecurityEventVaultFailed,
+		fmt.Sprintf("-%d seconds", int(loginFailureWindow.Seconds()))}
+	if policy.column == accountThrottle.column {
+		resetClause = ` AND id > IFNULL((SELECT MAX(id) FROM security_events WHERE username = ? AND event IN (?, ?)), 0)`
+		args = append(args, value, model.SecurityEventLoginSucceeded, model.SecurityEventPasswordChange)
+	}
+
+	var failures int
+	var lastFailure int64
+	err := database.DB.QueryRow(`
+		SELECT COUNT(*), CAST(IFNULL(MAX(strftime('%s', created_at)), 0) AS INTEGER)
+		FROM security_events
+		WHERE `+policy.column+` = ? AND event IN (?, ?, ?) AND created_at > datetime('now', ?)`+resetClause,
+		args...).Scan(&failures, &lastFailure)
+	return failures, time.Unix(lastFailure, 0), err
+}
+
+// List 分页获取安全事件，userID 为 0 表示所有用户
+func (r *SecurityRepository) List(userID int64, page, pageSize int) ([]model.SecurityEvent, int, error) {
+	whereClause := ""
+	var args []interface{}
+	if userID > 0 {
+		whereClause = "WHERE user_id = ?"
+		args = append(args, userID)
+	}
+
+	var total int
+	database.DB.QueryRow(`SELECT COUNT(*) FROM security_events `+whereClause, args...).Scan(&total)
+
+	listArgs := append(args, pageSize, (page-1)*pageSize)
+	rows, err := database.DB.Query(`
+		SELECT id, user_id, username, event, ip, user_agent, created_at
+		FROM security_events `+whereClause+`
+		ORDER BY id DESC
+		LIMIT ? OFFSET ?
+	`, listArgs...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var events []model.SecurityEvent
+	for rows.Next() {
+		var e model.SecurityEvent
+		var uid sql.NullInt64
+		if err := rows.Scan(&e.ID, &uid, &e.Username, &e.Event, &e.IP, &e.UserAgent, &e.CreatedAt); err != nil {
+			continue
+		}
+		if uid.Valid {
+			e.UserID = &uid.Int64
+		}
+		events = append(events, e)
+	}
+	return events, total, nil
+}
+
+// PurgeExpired 删除超过保留期限的安全事件
+func (r *SecurityRepository) PurgeExpired() (int64, error) {
+	result, err := database.DB.Exec(`DELETE FROM security_events WHERE created_at < datetime('now', ?)`,
+		fmt.Sprintf("-%d seconds", int(SecurityEventRetention.Seconds())))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
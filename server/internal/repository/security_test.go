@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"testing"
+	"time"
+)
+
+func TestThrottleDelay(t *testing.T) {
+	for _, policy := range []throttlePolicy{accountThrottle, ipThrottle} {
+		var prev time.Duration
+		for failures := 0; failures <= policy.lockoutAttempts; failures++ {
+			d := policy.delay(failures)
+			switch {
+			case d < 0:
+				t.Errorf("%s: delay(%d) = %v, want >= 0", policy.column, failures, d)
+			case d < prev:
+				t.Errorf("%s: delay(%d) = %v, less than delay(%d) = %v", policy.column, failures, d, failures-1, prev)
+			case d > LoginLockoutDuration:
+				t.Errorf("%s: delay(%d) = %v, more than lockout %v", policy.column, failures, d, LoginLockoutDuration)
+			}
+			if failures < policy.freeAttempts && d != 0 {
+				t.Errorf("%s: delay(%d) = %v within free attempts", policy.column, failures, d)
+			}
+			prev = d
+		}
+		if d := policy.delay(policy.lockoutAttempts); d != LoginLockoutDuration {
+			t.Errorf("%s: delay at lockout = %v, want %v", policy.column, d, LoginLockoutDuration)
+		}
+	}
+}
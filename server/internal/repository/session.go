@@ -0,0 +1,233 @@
+package repository
+
+import (
+	"Nibstash_v2_server/database"
+	"Nibstash_v2_server/internal/model"
+	"Nibstash_v2_server/internal/util"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RefreshTokenPrefix 刷新 Token 的固定前缀
+const RefreshTokenPrefix = "nbr_"
+
+// SessionTTL 会话有效期，每次刷新后重新计算，超过这个时间未使用需要重新登录
+const SessionTTL = 30 * 24 * time.Hour
+
+var ErrSessionInvalid = errors.New("登录已失效，请重新登录")
+
+type SessionRepository struct{}
+
+func NewSessionRepository() *SessionRepository {
+	return &SessionRepository{}
+}
+
+// sessionExpiry 会话过期时间的 SQL 参数，与 CURRENT_TIMESTAMP 格式一致便于比较
+func sessionExpiry() string {
+	return fmt.Sprintf("+%d seconds", int(SessionTTL.Seconds()))
+}
+
+func generateRefreshToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return RefreshTokenPrefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// Create 创建会话，返回会话和刷新 Token 明文；stepUp 表示登录时已通过两步验证
+func (r *SessionRepository) Create(userID int64, ip, userAgent string, stepUp bool) (*model.Session, string, error) {
+	token, err := generateRefreshToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	stepUpClause := "NULL"
+	if stepUp {
+		stepUpClause = "CURRENT_TIMESTAMP"
+	}
+	result, err := database.DB.Exec(`
+		INSERT INTO sessions (user_id, refresh_hash, user_agent, ip, step_up_at, expires_at)
+		VALUES (?, ?, ?, ?, `+stepUpClause+`, datetime('now', ?))
+	`, userID, hashToken(token), userAgent, ip, sessionExpiry())
+	if err != nil {
+		return nil, "", err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, "", err
+	}
+
+	session, err := r.GetByID(userID, id)
+	if err != nil {
+		return nil, "", err
+	}
+	return session, token, nil
+}
+
+// Refresh 用刷新 Token 续期会话并更换刷新 Token，旧的刷新 Token 立即失效
+func (r *SessionRepository) Refresh(token, ip, userAgent string) (*model.Session, string, error) {
+	if !strings.HasPrefix(token, RefreshTokenPrefix) {
+		return nil, "", ErrSessionInvalid
+	}
+
+	newToken, err := generateRefreshToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	// 同一个刷新 Token 并发使用时只有一个请求能更新成功
+	var id, userID int64
+	err = database.DB.QueryRow(`
+		UPDATE sessions SET refresh_hash = ?, ip = ?, user_agent = ?,
+			last_seen_at = CURRENT_TIMESTAMP, expires_at = datetime('now', ?)
+		WHERE refresh_hash = ? AND expires_at > CURRENT_TIMESTAMP
+		RETURNING id, user_id
+	`, hashToken(newToken), ip, userAgent, sessionExpiry(), hashToken(token)).Scan(&id, &userID)
+	if err == sql.ErrNoRows {
+		return nil, "", ErrSessionInvalid
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	session, err := r.GetByID(userID, id)
+	if err != nil {
+		return nil, "", err
+	}
+	return session, newToken, nil
+}
+
+// Authenticate 校验刷新 Token（不更换），用于只能携带 cookie 的 bookmarklet
+func (r *SessionRepository) Authenticate(token string) (*model.Session, error) {
+	if !strings.HasPrefix(token, RefreshTokenPrefix) {
+		return nil, ErrSessionInvalid
+	}
+
+	session, err := scanSession(database.DB.QueryRow(`
+		SELECT `+sessionColumns+` FROM sessions
+		WHERE refresh_hash = ? AND expires_at > CURRENT_TIMESTAMP
+	`, hashToken(token)))
+	if err != nil {
+		return nil, ErrSessionInvalid
+	}
+	r.touch(session.ID)
+	return session, nil
+}
+
+// Touch 校验会话仍然有效（未退出、未过期），并更新最后活跃时间
+func (r *SessionRepository) Touch(userID, id int64) (*model.Session, error) {
+	session, err := scanSession(database.DB.QueryRow(`
+		SELECT `+sessionColumns+` FROM sessions
+		WHERE id = ? AND user_id = ? AND expires_at > CURRENT_TIMESTAMP
+	`, id, userID))
+	if err != nil {
+		return nil, ErrSessionInvalid
+	}
+	r.touch(session.ID)
+	return session, nil
+}
+
+// touch 每分钟最多写一次，避免每个请求都写库
+func (r *SessionRepository) touch(id int64) {
+	database.DB.Exec(`
+		UPDATE sessions SET last_seen_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND last_seen_at < datetime('now', '-1 minute')
+	`, id)
+}
+
+// SetStepUp 记录会话通过二次验证的时间
+func (r *SessionRepository) SetStepUp(userID, id int64) error {
+	_, err := database.DB.Exec(`
+		UPDATE sessions SET step_up_at = CURRENT_TIMESTAMP WHERE id = ? AND user_id = ?
+	`, id, userID)
+	return err
+}
+
+func (r *SessionRepository) GetByID(userID, id int64) (*model.Session, error) {
+	return scanSession(database.DB.QueryRow(`
+		SELECT `+sessionColumns+` FROM sessions WHERE id = ? AND user_id = ?
+	`, id, userID))
+}
+
+// List 获取用户未过期的会话，最近活跃的在前
+func (r *SessionRepository) List(userID int64) ([]model.Session, error) {
+	rows, err := database.DB.Query(`
+		SELECT `+sessionColumns+` FROM sessions
+		WHERE user_id = ? AND expires_at > CURRENT_TIMESTAMP
+		ORDER BY last_seen_at DESC, id DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []model.Session
+	for rows.Next() {
+		if s, err := scanSession(rows); err == nil {
+			sessions = append(sessions, *s)
+		}
+	}
+	return sessions, nil
+}
+
+// Delete 退出指定会话
+func (r *SessionRepository) Delete(userID, id int64) error {
+	result, err := database.DB.Exec(`DELETE FROM sessions WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteAll 退出用户的所有会话（修改密码、退出所有设备）
+func (r *SessionRepository) DeleteAll(userID int64) (int64, error) {
+	result, err := database.DB.Exec(`DELETE FROM sessions WHERE user_id = ?`, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// PurgeExpired 删除已过期的会话，同时锁定这些会话的保险库
+func (r *SessionRepository) PurgeExpired() (int64, error) {
+	rows, err := database.DB.Query(`DELETE FROM sessions WHERE expires_at <= CURRENT_TIMESTAMP RETURNING id`)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var count int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return count, err
+		}
+		util.LockVault(id)
+		count++
+	}
+	return count, rows.Err()
+}
+
+const sessionColumns = `id, user_id, user_agent, ip, step_up_at, created_at, last_seen_at, expires_at`
+
+func scanSession(row rowScanner) (*model.Session, error) {
+	s := &model.Session{}
+	var stepUpAt sql.NullTime
+	if err := row.Scan(&s.ID, &s.UserID, &s.UserAgent, &s.IP, &stepUpAt, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt); err != nil {
+		return nil, err
+	}
+	if stepUpAt.Valid {
+		s.StepUpAt = &stepUpAt.Time
+	}
+	return s, nil
+}
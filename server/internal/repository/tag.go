@@ -3,6 +3,8 @@ package repository
 import (
 	"Nibstash_v2_server/database"
 	"Nibstash_v2_server/internal/model"
+	"Nibstash_v2_server/internal/util"
+	"strings"
 )
 
 type TagRepository struct{}
@@ -11,11 +13,11 @@ func NewTagRepository() *TagRepository {
 	return &TagRepository{}
 }
 
-func (r *TagRepository) Create(name, color string) (*model.Tag, error) {
+func (r *TagRepository) Create(userID int64, name, color string) (*model.Tag, error) {
 	if color == "" {
 		color = "#3b82f6"
 	}
-	result, err := database.DB.Exec(`INSERT INTO tags (name, color) VALUES (?, ?)`, name, color)
+	result, err := database.DB.Exec(`INSERT INTO tags (user_id, name, color, pinyin) VALUES (?, ?, ?, ?)`, userID, name, color, util.PinyinIndex(name))
 	if err != nil {
 		return nil, err
 	}
@@ -28,42 +30,53 @@ func (r *TagRepository) Create(name, color string) (*model.Tag, error) {
 	return &model.Tag{ID: id, Name: name, Color: color}, nil
 }
 
-func (r *TagRepository) GetByID(id int64) (*model.Tag, error) {
+func (r *TagRepository) GetByID(userID, id int64) (*model.Tag, error) {
 	tag := &model.Tag{}
-	err := database.DB.QueryRow(`SELECT id, name, color FROM tags WHERE id = ?`, id).Scan(&tag.ID, &tag.Name, &tag.Color)
+	err := database.DB.QueryRow(`SELECT id, name, color FROM tags WHERE id = ? AND user_id = ?`, id, userID).Scan(&tag.ID, &tag.Name, &tag.Color)
 	if err != nil {
 		return nil, err
 	}
 	return tag, nil
 }
 
-func (r *TagRepository) GetByName(name string) (*model.Tag, error) {
+func (r *TagRepository) GetByName(userID int64, name string) (*model.Tag, error) {
 	tag := &model.Tag{}
-	err := database.DB.QueryRow(`SELECT id, name, color FROM tags WHERE name = ?`, name).Scan(&tag.ID, &tag.Name, &tag.Color)
+	err := database.DB.QueryRow(`SELECT id, name, color FROM tags WHERE user_id = ? AND name = ?`, userID, name).Scan(&tag.ID, &tag.Name, &tag.Color)
 	if err != nil {
 		return nil, err
 	}
 	return tag, nil
 }
 
-func (r *TagRepository) Update(id int64, name, color string) error {
-	_, err := database.DB.Exec(`UPDATE tags SET name = ?, color = ? WHERE id = ?`, name, color, id)
+func (r *TagRepository) Update(userID, id int64, name, color string) error {
+	_, err := database.DB.Exec(`UPDATE tags SET name = ?, color = ?, pinyin = ? WHERE id = ? AND user_id = ?`, name, color, util.PinyinIndex(name), id, userID)
 	return err
 }
 
-func (r *TagRepository) Delete(id int64) error {
-	_, err := database.DB.Exec(`DELETE FROM tags WHERE id = ?`, id)
+func (r *TagRepository) Delete(userID, id int64) error {
+	_, err := database.DB.Exec(`DELETE FROM tags WHERE id = ? AND user_id = ?`, id, userID)
 	return err
 }
 
-func (r *TagRepository) List() ([]model.Tag, error) {
+// List 获取用户的标签列表，search 不为空时按名称或拼音筛选
+func (r *TagRepository) List(userID int64, search string) ([]model.Tag, error) {
+	whereClause := "WHERE t.user_id = ?"
+	args := []interface{}{userID}
+	if search != "" {
+		pattern := "%" + strings.ToLower(search) + "%"
+		whereClause += " AND (LOWER(t.name) LIKE ? OR t.pinyin LIKE ?)"
+		args = append(args, pattern, pattern)
+	}
+
 	rows, err := database.DB.Query(`
-		SELECT t.id, t.name, t.color, COUNT(bt.bookmark_id) as count
+		SELECT t.id, t.name, t.color, COALESCE(t.pinyin, ''), COUNT(bt.bookmark_id) as count
 		FROM tags t
 		LEFT JOIN bookmark_tags bt ON t.id = bt.tag_id
+			AND bt.bookmark_id IN (SELECT id FROM bookmarks WHERE deleted_at IS NULL)
+		`+whereClause+`
 		GROUP BY t.id
 		ORDER BY count DESC, t.name ASC
-	`)
+	`, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -72,7 +85,7 @@ func (r *TagRepository) List() ([]model.Tag, error) {
 	var tags []model.Tag
 	for rows.Next() {
 		var t model.Tag
-		if err := rows.Scan(&t.ID, &t.Name, &t.Color, &t.Count); err == nil {
+		if err := rows.Scan(&t.ID, &t.Name, &t.Color, &t.Pinyin, &t.Count); err == nil {
 			tags = append(tags, t)
 		}
 	}
@@ -100,3 +113,28 @@ func (r *TagRepository) GetByBookmarkID(bookmarkID int64) ([]model.Tag, error) {
 	}
 	return tags, nil
 }
+
+// SyncPinyin 为尚未生成拼音索引的标签补全 pinyin
+func (r *TagRepository) SyncPinyin() error {
+	rows, err := database.DB.Query(`SELECT id, name FROM tags WHERE pinyin IS NULL`)
+	if err != nil {
+		return err
+	}
+
+	pending := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var name string
+		if err := rows.Scan(&id, &name); err == nil {
+			pending[id] = name
+		}
+	}
+	rows.Close()
+
+	for id, name := range pending {
+		if _, err := database.DB.Exec(`UPDATE tags SET pinyin = ? WHERE id = ?`, util.PinyinIndex(name), id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
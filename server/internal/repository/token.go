@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"Nibstash_v2_server/database"
+	"Nibstash_v2_server/internal/model"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+)
+
+// APITokenPrefix API Token 的固定前缀，用于和 JWT 区分
+const APITokenPrefix = "nbs_"
+
+var ErrTokenInvalid = errors.New("无效的 API Token")
+
+type TokenRepository struct{}
+
+func NewTokenRepository() *TokenRepository {
+	return &TokenRepository{}
+}
+
+// hashToken Token 是 32 字节随机数，直接用 SHA-256 保存即可，无需慢哈希
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Create 生成新 Token，返回记录和明文（明文不入库）
+func (r *TokenRepository) Create(userID int64, name string, scopes []string, expiresInDays int) (*model.APIToken, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, "", err
+	}
+	token := APITokenPrefix + base64.RawURLEncoding.EncodeToString(buf)
+
+	var expiresAt interface{}
+	if expiresInDays > 0 {
+		expiresAt = time.Now().UTC().AddDate(0, 0, expiresInDays)
+	}
+
+	result, err := database.DB.Exec(`
+		INSERT INTO api_tokens (user_id, name, token_hash, prefix, scopes, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, userID, name, hashToken(token), token[:len(APITokenPrefix)+6], strings.Join(scopes, " "), expiresAt)
+	if err != nil {
+		return nil, "", err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, "", err
+	}
+
+	apiToken, err := r.GetByID(userID, id)
+	if err != nil {
+		return nil, "", err
+	}
+	return apiToken, token, nil
+}
+
+func (r *TokenRepository) GetByID(userID, id int64) (*model.APIToken, error) {
+	return scanToken(database.DB.QueryRow(`
+		SELECT id, user_id, name, prefix, scopes, expires_at, last_used_at, created_at
+		FROM api_tokens WHERE id = ? AND user_id = ?
+	`, id, userID))
+}
+
+// List 获取用户的所有 Token
+func (r *TokenRepository) List(userID int64) ([]model.APIToken, error) {
+	rows, err := database.DB.Query(`
+		SELECT id, user_id, name, prefix, scopes, expires_at, last_used_at, created_at
+		FROM api_tokens WHERE user_id = ? ORDER BY created_at DESC, id DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []model.APIToken
+	for rows.Next() {
+		if t, err := scanToken(rows); err == nil {
+			tokens = append(tokens, *t)
+		}
+	}
+	return tokens, nil
+}
+
+// Delete 吊销 Token
+func (r *TokenRepository) Delete(userID, id int64) error {
+	result, err := database.DB.Exec(`DELETE FROM api_tokens WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Authenticate 校验明文 Token，成功时更新最后使用时间
+func (r *TokenRepository) Authenticate(token string) (*model.APIToken, error) {
+	if !strings.HasPrefix(token, APITokenPrefix) {
+		return nil, ErrTokenInvalid
+	}
+
+	t, err := scanToken(database.DB.QueryRow(`
+		SELECT id, user_id, name, prefix, scopes, expires_at, last_used_at, created_at
+		FROM api_tokens WHERE token_hash = ?
+	`, hashToken(token)))
+	if err != nil {
+		return nil, ErrTokenInvalid
+	}
+	if t.ExpiresAt != nil && time.Now().After(*t.ExpiresAt) {
+		return nil, ErrTokenInvalid
+	}
+
+	// 每分钟最多写一次，避免每个请求都写库
+	database.DB.Exec(`
+		UPDATE api_tokens SET last_used_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND (last_used_at IS NULL OR last_used_at < datetime('now', '-1 minute'))
+	`, t.ID)
+	return t, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanToken(row rowScanner) (*model.APIToken, error) {
+	t := &model.APIToken{}
+	var scopes string
+	var expiresAt, lastUsedAt sql.NullTime
+	if err := row.Scan(&t.ID, &t.UserID, &t.Name, &t.Prefix, &scopes, &expiresAt, &lastUsedAt, &t.CreatedAt); err != nil {
+		return nil, err
+	}
+	t.Scopes = strings.Fields(scopes)
+	if expiresAt.Valid {
+		t.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		t.LastUsedAt = &lastUsedAt.Time
+	}
+	return t, nil
+}
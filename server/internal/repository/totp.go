@@ -0,0 +1,200 @@
+package repository
+
+import (
+	"Nibstash_v2_server/database"
+	"Nibstash_v2_server/internal/util"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+)
+
+// RecoveryCodeCount 每次生成的恢复码数量
+const RecoveryCodeCount = 10
+
+var (
+	ErrTOTPCodeInvalid    = errors.New("验证码错误")
+	ErrTOTPAlreadyEnabled = errors.New("两步验证已开启")
+	ErrTOTPNotEnabled     = errors.New("两步验证未开启")
+)
+
+type TOTPRepository struct{}
+
+func NewTOTPRepository() *TOTPRepository {
+	return &TOTPRepository{}
+}
+
+// SetPendingSecret 保存待确认的密钥（加密存储），确认验证码后才会开启
+func (r *TOTPRepository) SetPendingSecret(userID int64, secret string) error {
+	encrypted, err := util.Encrypt(secret)
+	if err != nil {
+		return err
+	}
+
+	result, err := database.DB.Exec(`
+		UPDATE users SET totp_secret = ?, totp_last_step = 0 WHERE id = ? AND totp_enabled = 0
+	`, encrypted, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrTOTPAlreadyEnabled
+	}
+	return nil
+}
+
+// Enable 用待确认密钥校验验证码，通过后开启两步验证并返回新的恢复码
+func (r *TOTPRepository) Enable(userID int64, code string) ([]string, error) {
+	secret, enabled, lastStep, err := r.secret(userID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+	if secret == "" {
+		return nil, ErrTOTPNotEnabled
+	}
+
+	step, ok := util.VerifyTOTP(secret, code, time.Now())
+	if !ok || step <= lastStep {
+		return nil, ErrTOTPCodeInvalid
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE users SET totp_enabled = 1, totp_last_step = ? WHERE id = ?`, step, userID); err != nil {
+		return nil, err
+	}
+	codes, err := replaceRecoveryCodes(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return codes, tx.Commit()
+}
+
+// Disable 关闭两步验证，同时删除密钥和恢复码
+func (r *TOTPRepository) Disable(userID int64) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE users SET totp_secret = '', totp_enabled = 0, totp_last_step = 0 WHERE id = ?`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Verify 校验 TOTP 验证码或恢复码；验证码通过后同一时间步内不能再次使用，恢复码使用后作废
+func (r *TOTPRepository) Verify(userID int64, code string) error {
+	secret, enabled, lastStep, err := r.secret(userID)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return ErrTOTPNotEnabled
+	}
+
+	if step, ok := util.VerifyTOTP(secret, code, time.Now()); ok {
+		if step <= lastStep {
+			return ErrTOTPCodeInvalid
+		}
+		// 条件更新防止并发请求重复使用同一验证码
+		result, err := database.DB.Exec(`UPDATE users SET totp_last_step = ? WHERE id = ? AND totp_last_step < ?`, step, userID, step)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return ErrTOTPCodeInvalid
+		}
+		return nil
+	}
+
+	result, err := database.DB.Exec(`
+		UPDATE recovery_codes SET used_at = CURRENT_TIMESTAMP
+		WHERE user_id = ? AND code_hash = ? AND used_at IS NULL
+	`, userID, hashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrTOTPCodeInvalid
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码，旧的恢复码全部作废
+func (r *TOTPRepository) RegenerateRecoveryCodes(userID int64) ([]string, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	codes, err := replaceRecoveryCodes(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return codes, tx.Commit()
+}
+
+// RemainingRecoveryCodes 未使用的恢复码数量
+func (r *TOTPRepository) RemainingRecoveryCodes(userID int64) int {
+	var count int
+	database.DB.QueryRow(`SELECT COUNT(*) FROM recovery_codes WHERE user_id = ? AND used_at IS NULL`, userID).Scan(&count)
+	return count
+}
+
+// secret 读取并解密用户的 TOTP 密钥
+func (r *TOTPRepository) secret(userID int64) (secret string, enabled bool, lastStep int64, err error) {
+	var encrypted string
+	err = database.DB.QueryRow(`SELECT totp_secret, totp_enabled, totp_last_step FROM users WHERE id = ?`, userID).
+		Scan(&encrypted, &enabled, &lastStep)
+	if err != nil {
+		return "", false, 0, err
+	}
+	if encrypted == "" {
+		return "", enabled, lastStep, nil
+	}
+	secret, err = util.Decrypt(encrypted)
+	return secret, enabled, lastStep, err
+}
+
+// replaceRecoveryCodes 删除旧恢复码并生成新的一组，返回明文（明文不入库）
+func replaceRecoveryCodes(tx *sql.Tx, userID int64) ([]string, error) {
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return nil, err
+	}
+
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		buf := make([]byte, 5)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(encoding.EncodeToString(buf))
+		if _, err := tx.Exec(`INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?)`, userID, hashToken(code)); err != nil {
+			return nil, err
+		}
+		codes[i] = code[:4] + "-" + code[4:]
+	}
+	return codes, nil
+}
+
+// normalizeRecoveryCode 忽略大小写、空格和连字符
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,348 @@
+package repository
+
+import (
+	"Nibstash_v2_server/database"
+	"Nibstash_v2_server/internal/model"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// trashStampLayout deleted_at 的格式，精确到微秒，使同一次删除操作的记录具有相同且唯一的删除时间
+const trashStampLayout = "2006-01-02 15:04:05.000000"
+
+var (
+	ErrTrashItemNotFound = errors.New("回收站中不存在该条目")
+	ErrTrashConflict     = errors.New("原位置已存在相同网址的书签")
+)
+
+// trashStamp 生成一次删除操作的删除时间
+func trashStamp() string {
+	return time.Now().UTC().Format(trashStampLayout)
+}
+
+type TrashRepository struct {
+	domainRepo *DomainRepository
+}
+
+func NewTrashRepository() *TrashRepository {
+	return &TrashRepository{
+		domainRepo: NewDomainRepository(),
+	}
+}
+
+// 回收站顶层条目：随文件夹一起删除的子文件夹和书签不单独列出
+const trashItemsQuery = `
+	SELECT b.user_id, 'bookmark' AS type, b.id, b.title, b.url AS detail, COALESCE(fp.path, '') AS folder_path, b.deleted_at
+	FROM bookmarks b
+	LEFT JOIN folders f ON f.id = b.folder_id
+	LEFT JOIN folder_paths fp ON fp.id = b.folder_id
+	WHERE b.deleted_at IS NOT NULL AND (f.deleted_at IS NULL OR f.deleted_at != b.deleted_at)
+	UNION ALL
+	SELECT f.user_id, 'folder', f.id, f.name, fp.path, COALESCE(pp.path, ''), f.deleted_at
+	FROM folders f
+	JOIN folder_paths fp ON fp.id = f.id
+	LEFT JOIN folders p ON p.id = f.parent_id
+	LEFT JOIN folder_paths pp ON pp.id = f.parent_id
+	WHERE f.deleted_at IS NOT NULL AND (p.deleted_at IS NULL OR p.deleted_at != f.deleted_at)
+	UNION ALL
+	SELECT c.user_id, 'credential', c.id, CASE WHEN c.title != '' THEN c.title ELSE c.username END, c.domain, '', c.deleted_at
+	FROM credentials c
+	WHERE c.deleted_at IS NOT NULL
+`
+
+// List 分页获取用户的回收站条目，按删除时间倒序；itemType 为空表示全部类型
+// retention 为保留期限，大于 0 时计算每个条目的到期时间
+func (r *TrashRepository) List(userID int64, page, pageSize int, itemType string, retention time.Duration) ([]model.TrashItem, int, error) {
+	whereClause := "WHERE user_id = ?"
+	args := []interface{}{userID}
+	if itemType != "" {
+		whereClause += " AND type = ?"
+		args = append(args, itemType)
+	}
+
+	var total int
+	database.DB.QueryRow(`SELECT COUNT(*) FROM (`+trashItemsQuery+`) `+whereClause, args...).Scan(&total)
+
+	listArgs := append(args, pageSize, (page-1)*pageSize)
+	rows, err := database.DB.Query(`
+		SELECT type, id, title, detail, folder_path, deleted_at
+		FROM (`+trashItemsQuery+`) `+whereClause+`
+		ORDER BY deleted_at DESC, id DESC
+		LIMIT ? OFFSET ?
+	`, listArgs...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var items []model.TrashItem
+	for rows.Next() {
+		var item model.TrashItem
+		if err := rows.Scan(&item.Type, &item.ID, &item.Title, &item.Detail, &item.FolderPath, &item.DeletedAt); err != nil {
+			continue
+		}
+		if retention > 0 {
+			expiresAt := item.DeletedAt.Add(retention)
+			item.ExpiresAt = &expiresAt
+		}
+		items = append(items, item)
+	}
+	rows.Close()
+
+	// 统计随文件夹一起删除的书签数量
+	for i := range items {
+		if items[i].Type == model.TrashTypeFolder {
+			database.DB.QueryRow(`
+				WITH RECURSIVE sub(id) AS (
+					SELECT ?
+					UNION ALL
+					SELECT f.id FROM folders f JOIN sub ON f.parent_id = sub.id
+				)
+				SELECT COUNT(*) FROM bookmarks
+				WHERE folder_id IN (SELECT id FROM sub)
+					AND deleted_at = (SELECT deleted_at FROM folders WHERE id = ?)
+			`, items[i].ID, items[i].ID).Scan(&items[i].ItemCount)
+		}
+	}
+
+	return items, total, nil
+}
+
+// Restore 恢复回收站条目（书签恢复标签和原文件夹，文件夹连同一起删除的内容恢复）
+// 原文件夹也在回收站中时会一并恢复；原位置已有同名文件夹时恢复到该文件夹中
+func (r *TrashRepository) Restore(userID int64, itemType string, id int64) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	switch itemType {
+	case model.TrashTypeBookmark:
+		err = restoreBookmark(tx, userID, id)
+	case model.TrashTypeFolder:
+		err = restoreFolder(tx, userID, id)
+	case model.TrashTypeCredential:
+		err = restoreCredential(tx, userID, id)
+	default:
+		err = ErrTrashItemNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	// 域名记录可能已随域名删除，重新同步
+	return r.domainRepo.SyncDomainsFromBookmarks()
+}
+
+func restoreBookmark(tx *sql.Tx, userID, id int64) error {
+	var url string
+	var folderID sql.NullInt64
+	err := tx.QueryRow(`SELECT url, folder_id FROM bookmarks WHERE id = ? AND user_id = ? AND deleted_at IS NOT NULL`, id, userID).Scan(&url, &folderID)
+	if err == sql.ErrNoRows {
+		return ErrTrashItemNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	var liveFolderID int64
+	if folderID.Valid {
+		if liveFolderID, err = reviveFolder(tx, userID, folderID.Int64); err != nil {
+			return err
+		}
+	}
+
+	var count int
+	tx.QueryRow(`SELECT COUNT(*) FROM bookmarks WHERE user_id = ? AND url = ? AND IFNULL(folder_id, 0) = ? AND deleted_at IS NULL`, userID, url, liveFolderID).Scan(&count)
+	if count > 0 {
+		return ErrTrashConflict
+	}
+
+	_, err = tx.Exec(`UPDATE bookmarks SET deleted_at = NULL, folder_id = ? WHERE id = ?`, nullableFolderID(liveFolderID), id)
+	return err
+}
+
+func restoreFolder(tx *sql.Tx, userID, id int64) error {
+	var parentID sql.NullInt64
+	err := tx.QueryRow(`SELECT parent_id FROM folders WHERE id = ? AND user_id = ? AND deleted_at IS NOT NULL`, id, userID).Scan(&parentID)
+	if err == sql.ErrNoRows {
+		return ErrTrashItemNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	var liveParentID int64
+	if parentID.Valid {
+		if liveParentID, err = reviveFolder(tx, userID, parentID.Int64); err != nil {
+			return err
+		}
+	}
+	return restoreFolderTree(tx, userID, id, liveParentID)
+}
+
+// reviveFolder 确保文件夹及其上级未被删除（只恢复文件夹本身，不恢复其中的内容），返回可用的文件夹 ID
+// 原位置已有同名文件夹时直接使用该文件夹
+func reviveFolder(tx *sql.Tx, userID, id int64) (int64, error) {
+	var parentID sql.NullInt64
+	var name string
+	var deleted bool
+	if err := tx.QueryRow(`SELECT parent_id, name, deleted_at IS NOT NULL FROM folders WHERE id = ?`, id).Scan(&parentID, &name, &deleted); err != nil {
+		return 0, err
+	}
+	if !deleted {
+		return id, nil
+	}
+
+	var liveParentID int64
+	if parentID.Valid {
+		var err error
+		if liveParentID, err = reviveFolder(tx, userID, parentID.Int64); err != nil {
+			return 0, err
+		}
+	}
+
+	existingID, err := findChildFolder(tx, userID, liveParentID, name)
+	if err == nil {
+		return existingID, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`UPDATE folders SET deleted_at = NULL, parent_id = ? WHERE id = ?`, nullableFolderID(liveParentID), id)
+	return id, err
+}
+
+// restoreFolderTree 恢复文件夹及与其同时删除的子文件夹和书签，恢复到 liveParentID 下
+// 目标位置已有同名文件夹时合并进去，冲突的书签留在回收站
+func restoreFolderTree(tx *sql.Tx, userID, id, liveParentID int64) error {
+	var name string
+	if err := tx.QueryRow(`SELECT name FROM folders WHERE id = ?`, id).Scan(&name); err != nil {
+		return err
+	}
+
+	liveID, err := findChildFolder(tx, userID, liveParentID, name)
+	if err == sql.ErrNoRows {
+		liveID = id
+	} else if err != nil {
+		return err
+	}
+
+	// 内容按删除时间匹配，必须在清除文件夹的 deleted_at 之前处理
+	const sameStamp = `deleted_at = (SELECT deleted_at FROM folders WHERE id = ?)`
+	if _, err := tx.Exec(`UPDATE OR IGNORE bookmarks SET deleted_at = NULL, folder_id = ? WHERE folder_id = ? AND `+sameStamp,
+		liveID, id, id); err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(`SELECT id FROM folders WHERE parent_id = ? AND `+sameStamp, id, id)
+	if err != nil {
+		return err
+	}
+	var children []int64
+	for rows.Next() {
+		var childID int64
+		if err := rows.Scan(&childID); err == nil {
+			children = append(children, childID)
+		}
+	}
+	rows.Close()
+
+	for _, childID := range children {
+		if err := restoreFolderTree(tx, userID, childID, liveID); err != nil {
+			return err
+		}
+	}
+
+	if liveID != id {
+		return nil
+	}
+	_, err = tx.Exec(`UPDATE folders SET deleted_at = NULL, parent_id = ? WHERE id = ?`, nullableFolderID(liveParentID), id)
+	return err
+}
+
+func restoreCredential(tx *sql.Tx, userID, id int64) error {
+	var domain string
+	err := tx.QueryRow(`SELECT domain FROM credentials WHERE id = ? AND user_id = ? AND deleted_at IS NOT NULL`, id, userID).Scan(&domain)
+	if err == sql.ErrNoRows {
+		return ErrTrashItemNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`UPDATE credentials SET deleted_at = NULL WHERE id = ?`, id); err != nil {
+		return err
+	}
+
+	// 删除域名时域名记录也被删除，恢复凭证时补回（安全笔记没有域名）
+	if domain == "" {
+		return nil
+	}
+	_, err = tx.Exec(`INSERT OR IGNORE INTO domains (user_id, domain, top_domain) VALUES (?, ?, ?)`, userID, domain, GetTopDomain(domain))
+	return err
+}
+
+// Purge 永久删除回收站条目（文件夹中的子文件夹和书签由外键级联删除）
+func (r *TrashRepository) Purge(userID int64, itemType string, id int64) error {
+	var query string
+	switch itemType {
+	case model.TrashTypeBookmark:
+		query = `DELETE FROM bookmarks WHERE id = ? AND user_id = ? AND deleted_at IS NOT NULL`
+	case model.TrashTypeFolder:
+		query = `DELETE FROM folders WHERE id = ? AND user_id = ? AND deleted_at IS NOT NULL`
+	case model.TrashTypeCredential:
+		query = `DELETE FROM credentials WHERE id = ? AND user_id = ? AND deleted_at IS NOT NULL`
+	default:
+		return ErrTrashItemNotFound
+	}
+
+	result, err := database.DB.Exec(query, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrTrashItemNotFound
+	}
+	return nil
+}
+
+// Empty 清空用户的回收站
+func (r *TrashRepository) Empty(userID int64) error {
+	_, err := r.purgeDeletedBefore(userID, "9999-12-31")
+	return err
+}
+
+// PurgeExpired 永久删除所有用户超过保留期限的条目，返回删除的条目数（不含随文件夹级联删除的内容）
+func (r *TrashRepository) PurgeExpired(retention time.Duration) (int64, error) {
+	if retention <= 0 {
+		return 0, nil
+	}
+	return r.purgeDeletedBefore(0, time.Now().UTC().Add(-retention).Format(trashStampLayout))
+}
+
+// purgeDeletedBefore 永久删除 cutoff 之前移入回收站的记录，userID 为 0 时处理所有用户
+func (r *TrashRepository) purgeDeletedBefore(userID int64, cutoff string) (int64, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var count int64
+	for _, table := range []string{"bookmarks", "folders", "credentials"} {
+		result, err := tx.Exec(`DELETE FROM `+table+` WHERE deleted_at < ? AND (? = 0 OR user_id = ?)`, cutoff, userID, userID)
+		if err != nil {
+			return 0, err
+		}
+		n, _ := result.RowsAffected()
+		count += n
+	}
+	return count, tx.Commit()
+}
@@ -4,10 +4,19 @@ import (
 	"Nibstash_v2_server/config"
 	"Nibstash_v2_server/database"
 	"Nibstash_v2_server/internal/model"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	ErrUsernameTaken     = errors.New("用户名已存在")
+	ErrInvitationInvalid = errors.New("邀请码无效、已过期或已被使用")
+)
+
 type UserRepository struct{}
 
 func NewUserRepository() *UserRepository {
@@ -18,9 +27,9 @@ func NewUserRepository() *UserRepository {
 func (r *UserRepository) GetByID(id int64) (*model.User, error) {
 	user := &model.User{}
 	err := database.DB.QueryRow(`
-		SELECT id, username, password, created_at, updated_at
+		SELECT id, username, password, role, totp_enabled, created_at, updated_at
 		FROM users WHERE id = ?
-	`, id).Scan(&user.ID, &user.Username, &user.Password, &user.CreatedAt, &user.UpdatedAt)
+	`, id).Scan(&user.ID, &user.Username, &user.Password, &user.Role, &user.TOTPEnabled, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -31,16 +40,16 @@ func (r *UserRepository) GetByID(id int64) (*model.User, error) {
 func (r *UserRepository) GetByUsername(username string) (*model.User, error) {
 	user := &model.User{}
 	err := database.DB.QueryRow(`
-		SELECT id, username, password, created_at, updated_at
+		SELECT id, username, password, role, totp_enabled, created_at, updated_at
 		FROM users WHERE username = ?
-	`, username).Scan(&user.ID, &user.Username, &user.Password, &user.CreatedAt, &user.UpdatedAt)
+	`, username).Scan(&user.ID, &user.Username, &user.Password, &user.Role, &user.TOTPEnabled, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
 	return user, nil
 }
 
-// EnsureDefaultUser 确保默认用户存在
+// EnsureDefaultUser 没有任何用户时创建默认管理员
 func (r *UserRepository) EnsureDefaultUser() error {
 	var count int
 	database.DB.QueryRow(`SELECT COUNT(*) FROM users`).Scan(&count)
@@ -48,18 +57,94 @@ func (r *UserRepository) EnsureDefaultUser() error {
 		return nil
 	}
 
-	// 创建默认用户
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(config.App.Password), bcrypt.DefaultCost)
+	// 创建默认用户，配置中没有初始密码时随机生成并输出到日志
+	password := config.App.Password
+	if password == "" {
+		var err error
+		if password, err = config.RandomPassword(); err != nil {
+			return err
+		}
+		log.Printf("已生成初始密码: %s（用户名 %s，登录后请尽快修改）", password, model.DefaultUsername)
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
 		return err
 	}
 
 	_, err = database.DB.Exec(`
-		INSERT INTO users (username, password) VALUES (?, ?)
-	`, "admin", string(hashedPassword))
+		INSERT INTO users (username, password, role) VALUES (?, ?, ?)
+	`, model.DefaultUsername, string(hashedPassword), model.RoleAdmin)
 	return err
 }
 
+// Register 注册普通用户；inviteCode 不为空时校验并占用邀请码，requireInvite 为 true 时必须提供邀请码
+func (r *UserRepository) Register(username, password, inviteCode string, requireInvite bool) (*model.User, error) {
+	if requireInvite && inviteCode == "" {
+		return nil, ErrInvitationInvalid
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var invitationID int64
+	if inviteCode != "" {
+		var expiresAt, usedAt sql.NullTime
+		err := tx.QueryRow(`SELECT id, expires_at, used_at FROM invitations WHERE code_hash = ?`, hashToken(inviteCode)).
+			Scan(&invitationID, &expiresAt, &usedAt)
+		if err == sql.ErrNoRows {
+			return nil, ErrInvitationInvalid
+		}
+		if err != nil {
+			return nil, err
+		}
+		if usedAt.Valid || (expiresAt.Valid && time.Now().After(expiresAt.Time)) {
+			return nil, ErrInvitationInvalid
+		}
+	}
+
+	var count int
+	tx.QueryRow(`SELECT COUNT(*) FROM users WHERE username = ?`, username).Scan(&count)
+	if count > 0 {
+		return nil, ErrUsernameTaken
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO users (username, password, role) VALUES (?, ?, ?)
+	`, username, string(hashedPassword), model.RoleUser)
+	if err != nil {
+		return nil, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	if invitationID > 0 {
+		result, err := tx.Exec(`
+			UPDATE invitations SET used_by = ?, used_at = CURRENT_TIMESTAMP WHERE id = ? AND used_at IS NULL
+		`, id, invitationID)
+		if err != nil {
+			return nil, err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return nil, ErrInvitationInvalid
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.GetByID(id)
+}
+
 // UpdatePassword 更新密码
 func (r *UserRepository) UpdatePassword(id int64, newPassword string) error {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
@@ -78,3 +163,24 @@ func (r *UserRepository) VerifyPassword(user *model.User, password string) bool
 	err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
 	return err == nil
 }
+
+// AdminsWithPassword 返回仍在使用指定密码的管理员用户名
+func (r *UserRepository) AdminsWithPassword(password string) ([]string, error) {
+	rows, err := database.DB.Query(`SELECT id, username, password FROM users WHERE role = ?`, model.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var usernames []string
+	for rows.Next() {
+		var user model.User
+		if err := rows.Scan(&user.ID, &user.Username, &user.Password); err != nil {
+			return nil, err
+		}
+		if r.VerifyPassword(&user, password) {
+			usernames = append(usernames, user.Username)
+		}
+	}
+	return usernames, rows.Err()
+}
@@ -0,0 +1,352 @@
+package repository
+
+import (
+	"Nibstash_v2_server/database"
+	"Nibstash_v2_server/internal/model"
+	"Nibstash_v2_server/internal/util"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrVaultNotInitialized     = errors.New("请先设置主密码")
+	ErrVaultAlreadyInitialized = errors.New("主密码已设置")
+)
+
+// vaultSaltSize Argon2id 盐长度
+const vaultSaltSize = 16
+
+type VaultRepository struct{}
+
+func NewVaultRepository() *VaultRepository {
+	return &VaultRepository{}
+}
+
+// vaultKDF 主密码的 KDF 参数和盐，所有保险库密钥都用同一个包装密钥包装
+type vaultKDF struct {
+	salt   []byte
+	params util.KDFParams
+}
+
+// Initialized 用户是否已设置主密码
+func (r *VaultRepository) Initialized(userID int64) bool {
+	var count int
+	database.DB.QueryRow(`SELECT COUNT(*) FROM vaults WHERE user_id = ?`, userID).Scan(&count)
+	return count > 0
+}
+
+// Setup 设置主密码：生成第一个保险库密钥，并把用户已有的凭证（含回收站中的）从服务器密钥重新加密为保险库密钥
+// 无法解密的凭证保持原样并在结果中列出，返回解锁后的保险库密钥
+func (r *VaultRepository) Setup(userID int64, masterPassword string) (*util.Keyring, []model.DecryptFailure, error) {
+	kdf, err := newVaultKDF()
+	if err != nil {
+		return nil, nil, err
+	}
+	kek := util.DeriveKEK(masterPassword, kdf.salt, kdf.params)
+	defer clear(kek)
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT OR IGNORE INTO vaults (user_id, kdf_salt, kdf_time, kdf_memory, kdf_threads, current_kid)
+		VALUES (?, ?, ?, ?, ?, '')
+	`, userID, base64.StdEncoding.EncodeToString(kdf.salt), kdf.params.Time, kdf.params.Memory, kdf.params.Threads)
+	if err != nil {
+		return nil, nil, err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, nil, ErrVaultAlreadyInitialized
+	}
+
+	kid, key, err := addVaultKey(tx, userID, kek, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	keys, err := util.NewKeyring(kid, map[string][]byte{kid: key})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	report, err := reencryptCredentials(tx, userID, util.Decrypt, keys)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+	return keys, report.Failed, nil
+}
+
+// Unlock 用主密码解开用户的所有保险库密钥
+func (r *VaultRepository) Unlock(userID int64, masterPassword string) (*util.Keyring, error) {
+	kdf, current, err := r.get(userID)
+	if err != nil {
+		return nil, err
+	}
+	kek := util.DeriveKEK(masterPassword, kdf.salt, kdf.params)
+	defer clear(kek)
+
+	keys, err := unwrapVaultKeys(database.DB, userID, kek)
+	if err != nil {
+		return nil, err
+	}
+	return util.NewKeyring(current, keys)
+}
+
+// ChangeMasterPassword 修改主密码：只重新包装保险库密钥，凭证无需重新加密
+func (r *VaultRepository) ChangeMasterPassword(userID int64, oldPassword, newPassword string) error {
+	kdf, _, err := r.get(userID)
+	if err != nil {
+		return err
+	}
+	oldKEK := util.DeriveKEK(oldPassword, kdf.salt, kdf.params)
+	defer clear(oldKEK)
+
+	keys, err := unwrapVaultKeys(database.DB, userID, oldKEK)
+	if err != nil {
+		return err
+	}
+
+	newKDF, err := newVaultKDF()
+	if err != nil {
+		return err
+	}
+	newKEK := util.DeriveKEK(newPassword, newKDF.salt, newKDF.params)
+	defer clear(newKEK)
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for kid, key := range keys {
+		wrapped, err := util.WrapKey(newKEK, key)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE vault_keys SET wrapped_key = ? WHERE user_id = ? AND kid = ?`,
+			base64.StdEncoding.EncodeToString(wrapped), userID, kid); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE vaults SET kdf_salt = ?, kdf_time = ?, kdf_memory = ?, kdf_threads = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE user_id = ?
+	`, base64.StdEncoding.EncodeToString(newKDF.salt), newKDF.params.Time, newKDF.params.Memory,
+		newKDF.params.Threads, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Rotate 轮换保险库密钥：生成新密钥并在一个事务中重新加密用户的所有凭证
+// 无法解密的凭证保持原样并在报告中列出，其使用的旧密钥不会被删除；返回轮换后的保险库密钥
+func (r *VaultRepository) Rotate(userID int64, masterPassword string) (*util.Keyring, *model.KeyRotationReport, error) {
+	kdf, current, err := r.get(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	kek := util.DeriveKEK(masterPassword, kdf.salt, kdf.params)
+	defer clear(kek)
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	oldKeys, err := unwrapVaultKeys(tx, userID, kek)
+	if err != nil {
+		return nil, nil, err
+	}
+	oldRing, err := util.NewKeyring(current, oldKeys)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	kid, key, err := addVaultKey(tx, userID, kek, oldKeys)
+	if err != nil {
+		return nil, nil, err
+	}
+	newKeys := map[string][]byte{kid: key}
+	for k, v := range oldKeys {
+		newKeys[k] = v
+	}
+	newRing, err := util.NewKeyring(kid, newKeys)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	report, err := reencryptCredentials(tx, userID, oldRing.Decrypt, newRing)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 删除已没有凭证使用的旧密钥
+	remaining := map[string][]byte{kid: key}
+	for _, oldKid := range oldRing.IDs() {
+		inUse, err := vaultKeyInUse(tx, userID, oldKid)
+		if err != nil {
+			return nil, nil, err
+		}
+		if inUse {
+			remaining[oldKid] = oldKeys[oldKid]
+			continue
+		}
+		if _, err := tx.Exec(`DELETE FROM vault_keys WHERE user_id = ? AND kid = ?`, userID, oldKid); err != nil {
+			return nil, nil, err
+		}
+		report.RetiredKeys = append(report.RetiredKeys, oldKid)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+
+	keys, err := util.NewKeyring(kid, remaining)
+	if err != nil {
+		return nil, nil, err
+	}
+	return keys, report, nil
+}
+
+func (r *VaultRepository) get(userID int64) (*vaultKDF, string, error) {
+	var salt, current string
+	kdf := &vaultKDF{}
+	err := database.DB.QueryRow(`
+		SELECT kdf_salt, kdf_time, kdf_memory, kdf_threads, current_kid FROM vaults WHERE user_id = ?
+	`, userID).Scan(&salt, &kdf.params.Time, &kdf.params.Memory, &kdf.params.Threads, &current)
+	if err == sql.ErrNoRows {
+		return nil, "", ErrVaultNotInitialized
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	if kdf.salt, err = base64.StdEncoding.DecodeString(salt); err != nil {
+		return nil, "", err
+	}
+	return kdf, current, nil
+}
+
+// newVaultKDF 生成新的随机盐，使用默认 KDF 参数
+func newVaultKDF() (*vaultKDF, error) {
+	salt, err := util.RandomBytes(vaultSaltSize)
+	if err != nil {
+		return nil, err
+	}
+	return &vaultKDF{salt: salt, params: util.DefaultKDFParams}, nil
+}
+
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// unwrapVaultKeys 解开用户的所有保险库密钥，主密码错误时返回 util.ErrMasterPasswordFail
+func unwrapVaultKeys(q queryer, userID int64, kek []byte) (map[string][]byte, error) {
+	rows, err := q.Query(`SELECT kid, wrapped_key FROM vault_keys WHERE user_id = ?`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := map[string][]byte{}
+	for rows.Next() {
+		var kid, wrapped string
+		if err := rows.Scan(&kid, &wrapped); err != nil {
+			return nil, err
+		}
+		data, err := base64.StdEncoding.DecodeString(wrapped)
+		if err != nil {
+			return nil, err
+		}
+		if keys[kid], err = util.UnwrapKey(kek, data); err != nil {
+			return nil, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, ErrVaultNotInitialized
+	}
+	return keys, nil
+}
+
+// addVaultKey 生成新的保险库密钥（ID 为 k1、k2……，接在 existing 之后）并设为当前密钥
+func addVaultKey(tx *sql.Tx, userID int64, kek []byte, existing map[string][]byte) (string, []byte, error) {
+	next := 1
+	for kid := range existing {
+		if n, err := strconv.Atoi(strings.TrimPrefix(kid, "k")); err == nil && n >= next {
+			next = n + 1
+		}
+	}
+	kid := fmt.Sprintf("k%d", next)
+
+	key, err := util.RandomBytes(util.VaultKeySize)
+	if err != nil {
+		return "", nil, err
+	}
+	wrapped, err := util.WrapKey(kek, key)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if _, err := tx.Exec(`INSERT INTO vault_keys (user_id, kid, wrapped_key) VALUES (?, ?, ?)`,
+		userID, kid, base64.StdEncoding.EncodeToString(wrapped)); err != nil {
+		return "", nil, err
+	}
+	if _, err := tx.Exec(`UPDATE vaults SET current_kid = ?, updated_at = CURRENT_TIMESTAMP WHERE user_id = ?`,
+		kid, userID); err != nil {
+		return "", nil, err
+	}
+	return kid, key, nil
+}
+
+// reencryptCredentials 用 decrypt 解密用户凭证中加密保存的内容（密码、TOTP 密钥、备注、hidden 字段、历史密码）并用 keys 的当前密钥重新加密
+func reencryptCredentials(tx *sql.Tx, userID int64, decrypt func(string) (string, error), keys *util.Keyring) (*model.KeyRotationReport, error) {
+	report := newRotationReport(keys.CurrentID())
+	for _, target := range []struct{ table, column, where string }{
+		{"credentials", "password", "user_id = ?"},
+		{"credentials", "totp_secret", "user_id = ?"},
+		{"credentials", "notes", "user_id = ? AND notes_encrypted = 1"},
+		{"credential_fields", "value", "type = 'hidden' AND credential_id IN (SELECT id FROM credentials WHERE user_id = ?)"},
+		{"credential_history", "password", "credential_id IN (SELECT id FROM credentials WHERE user_id = ?)"},
+	} {
+		if err := reencryptColumn(tx, target.table, target.column, target.where, []interface{}{userID},
+			decrypt, keys.Encrypt, report); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// vaultKeyInUse 是否还有凭证内容使用保险库密钥 kid 加密
+func vaultKeyInUse(tx *sql.Tx, userID int64, kid string) (bool, error) {
+	prefix := "v1:" + kid + ":%"
+	var inUse bool
+	err := tx.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM credentials WHERE user_id = ?
+				AND (password LIKE ? OR totp_secret LIKE ? OR (notes_encrypted = 1 AND notes LIKE ?))
+		) OR EXISTS (
+			SELECT 1 FROM credential_fields f JOIN credentials c ON c.id = f.credential_id
+			WHERE c.user_id = ? AND f.type = 'hidden' AND f.value LIKE ?
+		) OR EXISTS (
+			SELECT 1 FROM credential_history h JOIN credentials c ON c.id = h.credential_id
+			WHERE c.user_id = ? AND h.password LIKE ?
+		)
+	`, userID, prefix, prefix, prefix, userID, prefix, userID, prefix).Scan(&inUse)
+	return inUse, err
+}
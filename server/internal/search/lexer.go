@@ -0,0 +1,188 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokTerm
+	tokAnd
+	tokOr
+	tokNot
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+	term *Term
+}
+
+// startsUnary 判断该 token 能否作为一个条件的开头（用于隐式 AND）
+func (t token) startsUnary() bool {
+	return t.kind == tokTerm || t.kind == tokNot || t.kind == tokLParen
+}
+
+// lex 将查询串切分为 token，末尾总是附加一个 tokEOF
+func lex(query string) ([]token, error) {
+	runes := []rune(query)
+	var tokens []token
+	i := 0
+
+	for i < len(runes) {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{kind: tokLParen, text: "(", pos: i})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokRParen, text: ")", pos: i})
+			i++
+		case r == '-' && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]) && runes[i+1] != ')':
+			tokens = append(tokens, token{kind: tokNot, text: "-", pos: i})
+			i++
+		case r == '"':
+			value, end, err := readQuoted(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			term := &Term{Field: FieldText, Value: value, Phrase: true, Pos: i}
+			text := string(runes[i:end])
+			if err := validateTerm(term, text); err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokTerm, text: text, pos: i, term: term})
+			i = end
+		default:
+			tok, end, err := readWord(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, tok)
+			i = end
+		}
+	}
+
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(runes)}), nil
+}
+
+// readQuoted 读取从 start 处开始的双引号字符串，返回内容和结束位置
+// 字符串内用 \" 表示引号本身
+func readQuoted(runes []rune, start int) (string, int, error) {
+	var sb strings.Builder
+	for i := start + 1; i < len(runes); i++ {
+		switch runes[i] {
+		case '\\':
+			if i+1 < len(runes) && runes[i+1] == '"' {
+				sb.WriteRune('"')
+				i++
+				continue
+			}
+			sb.WriteRune(runes[i])
+		case '"':
+			return sb.String(), i + 1, nil
+		default:
+			sb.WriteRune(runes[i])
+		}
+	}
+	return "", 0, &ParseError{Pos: start, Token: string(runes[start:]), Message: "引号未闭合"}
+}
+
+// readWord 读取普通词、关键字（AND/OR/NOT）或 field:value 形式的条件；
+// 冒号前不是已知字段时（如 localhost:8080、Re:）整体作为普通词
+func readWord(runes []rune, start int) (token, int, error) {
+	end := start
+	for end < len(runes) && !isWordBoundary(runes[end]) {
+		if runes[end] == ':' && isFieldName(runes[start:end]) && !isURLScheme(runes, end) {
+			return readField(runes, start, end)
+		}
+		end++
+	}
+
+	text := string(runes[start:end])
+	switch text {
+	case "AND":
+		return token{kind: tokAnd, text: text, pos: start}, end, nil
+	case "OR", "|":
+		return token{kind: tokOr, text: text, pos: start}, end, nil
+	case "NOT":
+		return token{kind: tokNot, text: text, pos: start}, end, nil
+	}
+
+	term := &Term{Field: FieldText, Value: text, Pos: start}
+	return token{kind: tokTerm, text: text, pos: start, term: term}, end, nil
+}
+
+// readField 读取 field:value，colon 为冒号所在位置
+func readField(runes []rune, start, colon int) (token, int, error) {
+	term := &Term{Field: strings.ToLower(string(runes[start:colon])), Pos: start}
+
+	i := colon + 1
+	for _, op := range []string{">=", "<=", ">", "<", "="} {
+		if hasPrefix(runes[i:], op) {
+			term.Op = op
+			i += len(op)
+			break
+		}
+	}
+
+	end := i
+	if i < len(runes) && runes[i] == '"' {
+		value, quotedEnd, err := readQuoted(runes, i)
+		if err != nil {
+			return token{}, 0, err
+		}
+		term.Value = value
+		term.Phrase = true
+		end = quotedEnd
+	} else {
+		for end < len(runes) && !isWordBoundary(runes[end]) {
+			end++
+		}
+		term.Value = string(runes[i:end])
+	}
+
+	text := string(runes[start:end])
+	if err := validateTerm(term, text); err != nil {
+		return token{}, 0, err
+	}
+	return token{kind: tokTerm, text: text, pos: start, term: term}, end, nil
+}
+
+func isWordBoundary(r rune) bool {
+	return unicode.IsSpace(r) || r == '(' || r == ')' || r == '"'
+}
+
+// isFieldName 判断是否为已知的字段名（不区分大小写）
+func isFieldName(runes []rune) bool {
+	return knownFields[strings.ToLower(string(runes))]
+}
+
+// isURLScheme 判断冒号后是否为 //，如 https://，这种情况按普通词处理
+func isURLScheme(runes []rune, colon int) bool {
+	return hasPrefix(runes[colon+1:], "//")
+}
+
+func hasPrefix(runes []rune, prefix string) bool {
+	p := []rune(prefix)
+	if len(runes) < len(p) {
+		return false
+	}
+	for i := range p {
+		if runes[i] != p[i] {
+			return false
+		}
+	}
+	return true
+}
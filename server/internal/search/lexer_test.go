@@ -0,0 +1,107 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLex(t *testing.T) {
+	tests := []struct {
+		query string
+		kinds []tokenKind
+		texts []string
+	}{
+		{"", nil, nil},
+		{"   ", nil, nil},
+		{"golang", []tokenKind{tokTerm, tokEOF}, []string{"golang", ""}},
+		{"a AND b", []tokenKind{tokTerm, tokAnd, tokTerm, tokEOF}, []string{"a", "AND", "b", ""}},
+		{"a OR b | c", []tokenKind{tokTerm, tokOr, tokTerm, tokOr, tokTerm, tokEOF}, []string{"a", "OR", "b", "|", "c", ""}},
+		{"NOT a -b", []tokenKind{tokNot, tokTerm, tokNot, tokTerm, tokEOF}, []string{"NOT", "a", "-", "b", ""}},
+		{"(a)", []tokenKind{tokLParen, tokTerm, tokRParen, tokEOF}, []string{"(", "a", ")", ""}},
+		{"and or", []tokenKind{tokTerm, tokTerm, tokEOF}, []string{"and", "or", ""}},
+		{"a - b", []tokenKind{tokTerm, tokTerm, tokTerm, tokEOF}, []string{"a", "-", "b", ""}},
+		{`"exact phrase" tag:go`, []tokenKind{tokTerm, tokTerm, tokEOF}, []string{`"exact phrase"`, "tag:go", ""}},
+		{`folder:"Work/Docs"`, []tokenKind{tokTerm, tokEOF}, []string{`folder:"Work/Docs"`, ""}},
+		{"https://example.com/a", []tokenKind{tokTerm, tokEOF}, []string{"https://example.com/a", ""}},
+		{"localhost:8080", []tokenKind{tokTerm, tokEOF}, []string{"localhost:8080", ""}},
+		{"Re: meeting", []tokenKind{tokTerm, tokTerm, tokEOF}, []string{"Re:", "meeting", ""}},
+	}
+
+	for _, tt := range tests {
+		tokens, err := lex(tt.query)
+		if err != nil {
+			t.Errorf("lex(%q) error: %v", tt.query, err)
+			continue
+		}
+		var kinds []tokenKind
+		var texts []string
+		for _, tok := range tokens {
+			kinds = append(kinds, tok.kind)
+			texts = append(texts, tok.text)
+		}
+		if !reflect.DeepEqual(kinds, tt.kinds) || !reflect.DeepEqual(texts, tt.texts) {
+			t.Errorf("lex(%q) = %v %q, want %v %q", tt.query, kinds, texts, tt.kinds, tt.texts)
+		}
+	}
+}
+
+func TestLexTerms(t *testing.T) {
+	tests := []struct {
+		query string
+		want  Term
+	}{
+		{"golang", Term{Field: FieldText, Value: "golang"}},
+		{`"exact phrase"`, Term{Field: FieldText, Value: "exact phrase", Phrase: true}},
+		{`"say \"hi\""`, Term{Field: FieldText, Value: `say "hi"`, Phrase: true}},
+		{"TAG:Go", Term{Field: FieldTag, Value: "Go"}},
+		{`folder:"Work/Docs"`, Term{Field: FieldFolder, Value: "Work/Docs", Phrase: true}},
+		{"created:>=2025-01-01", Term{Field: FieldCreated, Op: ">=", Value: "2025-01-01"}},
+		{"updated:2025-01-01", Term{Field: FieldUpdated, Op: "=", Value: "2025-01-01"}},
+		{"localhost:8080", Term{Field: FieldText, Value: "localhost:8080"}},
+		{"re:", Term{Field: FieldText, Value: "re:"}},
+		{"note:tag:go", Term{Field: FieldText, Value: "note:tag:go"}},
+		{"https://github.com", Term{Field: FieldText, Value: "https://github.com"}},
+		{"url:https://github.com", Term{Field: FieldURL, Value: "https://github.com"}},
+	}
+
+	for _, tt := range tests {
+		tokens, err := lex(tt.query)
+		if err != nil {
+			t.Errorf("lex(%q) error: %v", tt.query, err)
+			continue
+		}
+		if len(tokens) != 2 || tokens[0].kind != tokTerm {
+			t.Errorf("lex(%q) = %d tokens, want one term", tt.query, len(tokens)-1)
+			continue
+		}
+		if got := *tokens[0].term; got != tt.want {
+			t.Errorf("lex(%q) term = %+v, want %+v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestLexErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		pos   int
+	}{
+		{`"unclosed`, 0},
+		{`a folder:"unclosed`, 9},
+		{"tag:", 0},
+		{"x created:yesterday", 2},
+		{"created:~2025-01-01", 0},
+		{"tag:>go", 0},
+	}
+
+	for _, tt := range tests {
+		_, err := lex(tt.query)
+		parseErr, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("lex(%q) error = %v, want *ParseError", tt.query, err)
+			continue
+		}
+		if parseErr.Pos != tt.pos {
+			t.Errorf("lex(%q) error position = %d, want %d", tt.query, parseErr.Pos, tt.pos)
+		}
+	}
+}
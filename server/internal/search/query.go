@@ -0,0 +1,255 @@
+package search
+
+import (
+	"fmt"
+	"time"
+)
+
+// 支持的字段
+const (
+	FieldText    = ""        // 自由文本（标题、网址、描述、标题拼音）
+	FieldTag     = "tag"     // 标签名
+	FieldFolder  = "folder"  // 文件夹（含子文件夹）
+	FieldSite    = "site"    // 站点域名（含子域名）
+	FieldTitle   = "title"   // 仅标题（含拼音）
+	FieldURL     = "url"     // 仅网址
+	FieldDesc    = "desc"    // 仅描述
+	FieldCreated = "created" // 创建日期
+	FieldUpdated = "updated" // 更新日期
+)
+
+// DateLayout 日期字段的格式
+const DateLayout = "2006-01-02"
+
+var knownFields = map[string]bool{
+	FieldTag: true, FieldFolder: true, FieldSite: true,
+	FieldTitle: true, FieldURL: true, FieldDesc: true,
+	FieldCreated: true, FieldUpdated: true,
+}
+
+var dateFields = map[string]bool{
+	FieldCreated: true, FieldUpdated: true,
+}
+
+// Node 查询语法树节点
+type Node interface {
+	node()
+}
+
+// And 所有子条件同时满足
+type And struct {
+	Children []Node
+}
+
+// Or 任一子条件满足
+type Or struct {
+	Children []Node
+}
+
+// Not 子条件不满足
+type Not struct {
+	Child Node
+}
+
+// Term 单个检索条件
+type Term struct {
+	Field  string // 字段名，空字符串表示自由文本
+	Op     string // 比较运算符，仅日期字段使用：=, >, >=, <, <=
+	Value  string
+	Phrase bool // 是否为引号包裹的短语
+	Pos    int  // 在查询串中的位置（按字符计，从 0 开始）
+}
+
+func (*And) node()  {}
+func (*Or) node()   {}
+func (*Not) node()  {}
+func (*Term) node() {}
+
+// ParseError 查询语法错误，Pos/Token 指向出错的位置
+type ParseError struct {
+	Pos     int    `json:"position"`
+	Token   string `json:"token"`
+	Message string `json:"message"`
+}
+
+func (e *ParseError) Error() string {
+	if e.Token == "" {
+		return fmt.Sprintf("第 %d 个字符处: %s", e.Pos+1, e.Message)
+	}
+	return fmt.Sprintf("第 %d 个字符处 %q: %s", e.Pos+1, e.Token, e.Message)
+}
+
+// Parse 解析搜索语句，空查询返回 nil
+//
+// 语法示例：
+//
+//	tag:golang folder:"Work/Docs" site:github.com -tag:archived created:>2025-01-01 "exact phrase"
+//
+// 相邻条件默认为 AND，支持 OR、NOT（或前缀 -）和括号分组
+func Parse(query string) (Node, error) {
+	tokens, err := lex(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	p := &parser{tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, &ParseError{Pos: tok.pos, Token: tok.text, Message: "多余的内容"}
+	}
+	return node, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) parseOr() (Node, error) {
+	first, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	children := []Node{first}
+	for p.peek().kind == tokOr {
+		p.next()
+		child, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+
+	if len(children) == 1 {
+		return first, nil
+	}
+	return &Or{Children: children}, nil
+}
+
+func (p *parser) parseAnd() (Node, error) {
+	first, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	children := []Node{first}
+	for {
+		tok := p.peek()
+		if tok.kind == tokAnd {
+			p.next()
+		} else if !tok.startsUnary() {
+			break
+		}
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+
+	if len(children) == 1 {
+		return first, nil
+	}
+	return &And{Children: children}, nil
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	if p.peek().kind == tokNot {
+		p.next()
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{Child: child}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokLParen:
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, &ParseError{Pos: tok.pos, Token: tok.text, Message: "括号未闭合"}
+		}
+		return node, nil
+	case tokTerm:
+		return tok.term, nil
+	case tokEOF:
+		return nil, &ParseError{Pos: tok.pos, Message: "查询意外结束"}
+	default:
+		return nil, &ParseError{Pos: tok.pos, Token: tok.text, Message: "此处需要检索条件"}
+	}
+}
+
+// validateTerm 校验字段名、运算符和取值
+func validateTerm(t *Term, text string) error {
+	if t.Field != FieldText && !knownFields[t.Field] {
+		return &ParseError{Pos: t.Pos, Token: text, Message: "未知的搜索字段 " + t.Field}
+	}
+	if t.Value == "" {
+		return &ParseError{Pos: t.Pos, Token: text, Message: "缺少搜索内容"}
+	}
+
+	if dateFields[t.Field] {
+		if t.Op == "" {
+			t.Op = "="
+		}
+		if _, err := time.Parse(DateLayout, t.Value); err != nil {
+			return &ParseError{Pos: t.Pos, Token: text, Message: "日期格式应为 YYYY-MM-DD"}
+		}
+		return nil
+	}
+
+	if t.Op != "" {
+		return &ParseError{Pos: t.Pos, Token: text, Message: "字段 " + t.Field + " 不支持比较运算符"}
+	}
+	return nil
+}
+
+// Walk 深度优先遍历语法树，negated 表示当前节点是否处于 NOT 之下
+func Walk(node Node, fn func(t *Term, negated bool)) {
+	var walk func(n Node, negated bool)
+	walk = func(n Node, negated bool) {
+		switch v := n.(type) {
+		case *And:
+			for _, c := range v.Children {
+				walk(c, negated)
+			}
+		case *Or:
+			for _, c := range v.Children {
+				walk(c, negated)
+			}
+		case *Not:
+			walk(v.Child, !negated)
+		case *Term:
+			fn(v, negated)
+		}
+	}
+	if node != nil {
+		walk(node, false)
+	}
+}
@@ -0,0 +1,116 @@
+package search
+
+import (
+	"strings"
+	"testing"
+)
+
+// format 把语法树写成便于比较的 S 表达式
+func format(n Node) string {
+	switch v := n.(type) {
+	case nil:
+		return "<nil>"
+	case *And:
+		return "(and " + formatChildren(v.Children) + ")"
+	case *Or:
+		return "(or " + formatChildren(v.Children) + ")"
+	case *Not:
+		return "(not " + format(v.Child) + ")"
+	case *Term:
+		s := v.Value
+		if v.Phrase {
+			s = `"` + s + `"`
+		}
+		if v.Field != FieldText {
+			s = v.Field + ":" + v.Op + s
+		}
+		return s
+	}
+	return "?"
+}
+
+func formatChildren(children []Node) string {
+	parts := make([]string, len(children))
+	for i, c := range children {
+		parts[i] = format(c)
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"", "<nil>"},
+		{"golang", "golang"},
+		{"a b c", "(and a b c)"},
+		{"a AND b", "(and a b)"},
+		{"a OR b c", "(or a (and b c))"},
+		{"a b OR c", "(or (and a b) c)"},
+		{"-tag:archived golang", "(and (not tag:archived) golang)"},
+		{"NOT NOT a", "(not (not a))"},
+		{"(a OR b) c", "(and (or a b) c)"},
+		{"-(a | b)", "(not (or a b))"},
+		{`tag:golang folder:"Work/Docs" site:github.com -tag:archived created:>2025-01-01 "exact phrase"`,
+			`(and tag:golang folder:"Work/Docs" site:github.com (not tag:archived) created:>2025-01-01 "exact phrase")`},
+		{"localhost:8080 tag:dev", "(and localhost:8080 tag:dev)"},
+		{"Re: meeting", "(and Re: meeting)"},
+	}
+
+	for _, tt := range tests {
+		node, err := Parse(tt.query)
+		if err != nil {
+			t.Errorf("Parse(%q) error: %v", tt.query, err)
+			continue
+		}
+		if got := format(node); got != tt.want {
+			t.Errorf("Parse(%q) = %s, want %s", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		pos   int
+		token string
+	}{
+		{"(a b", 0, "("},
+		{"a)", 1, ")"},
+		{"a OR", 4, ""},
+		{"a AND OR b", 6, "OR"},
+		{"()", 1, ")"},
+		{"NOT", 3, ""},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.query)
+		parseErr, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("Parse(%q) error = %v, want *ParseError", tt.query, err)
+			continue
+		}
+		if parseErr.Pos != tt.pos || parseErr.Token != tt.token {
+			t.Errorf("Parse(%q) error at %d %q, want %d %q", tt.query, parseErr.Pos, parseErr.Token, tt.pos, tt.token)
+		}
+	}
+}
+
+func TestWalk(t *testing.T) {
+	node, err := Parse("a -(b OR NOT c)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	Walk(node, func(term *Term, negated bool) {
+		if negated {
+			got = append(got, "-"+term.Value)
+		} else {
+			got = append(got, term.Value)
+		}
+	})
+	if want := "a -b c"; strings.Join(got, " ") != want {
+		t.Errorf("Walk = %q, want %q", strings.Join(got, " "), want)
+	}
+}
@@ -0,0 +1,94 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+)
+
+// ArchiveFormat 加密备份文件的格式标识
+const ArchiveFormat = "nibstash-archive"
+
+var (
+	ErrArchivePassphrase = errors.New("备份文件密码错误")
+	ErrArchiveInvalid    = errors.New("备份文件格式错误")
+)
+
+// 备份文件：JSON 头部记录 Argon2id 参数和盐，data 为 AES-256-GCM 加密的内容（nonce + 密文）
+type archiveEnvelope struct {
+	Format  string     `json:"format"`
+	Version int        `json:"version"`
+	KDF     archiveKDF `json:"kdf"`
+	Cipher  string     `json:"cipher"`
+	Data    []byte     `json:"data"`
+}
+
+type archiveKDF struct {
+	Algorithm string `json:"algorithm"`
+	Salt      []byte `json:"salt"`
+	Time      uint32 `json:"time"`
+	Memory    uint32 `json:"memory"` // KiB
+	Threads   uint8  `json:"threads"`
+}
+
+// 打开备份文件时允许的最大 Argon2id 参数，避免构造的文件耗尽服务器资源
+const (
+	maxArchiveKDFTime   = 16
+	maxArchiveKDFMemory = 1024 * 1024
+)
+
+// SealArchive 用备份密码加密内容，生成备份文件
+func SealArchive(passphrase string, plaintext []byte) ([]byte, error) {
+	salt, err := RandomBytes(16)
+	if err != nil {
+		return nil, err
+	}
+
+	params := DefaultKDFParams
+	data, err := seal(DeriveKEK(passphrase, salt, params), plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(archiveEnvelope{
+		Format:  ArchiveFormat,
+		Version: 1,
+		KDF: archiveKDF{
+			Algorithm: "argon2id",
+			Salt:      salt,
+			Time:      params.Time,
+			Memory:    params.Memory,
+			Threads:   params.Threads,
+		},
+		Cipher: "aes-256-gcm",
+		Data:   data,
+	}, "", "  ")
+}
+
+// IsArchive 判断文件是否为加密备份文件
+func IsArchive(content []byte) bool {
+	var header struct {
+		Format string `json:"format"`
+	}
+	return json.Unmarshal(bytes.TrimSpace(content), &header) == nil && header.Format == ArchiveFormat
+}
+
+// OpenArchive 用备份密码解密备份文件，密码错误返回 ErrArchivePassphrase
+func OpenArchive(passphrase string, content []byte) ([]byte, error) {
+	var env archiveEnvelope
+	if err := json.Unmarshal(bytes.TrimSpace(content), &env); err != nil || env.Format != ArchiveFormat {
+		return nil, ErrArchiveInvalid
+	}
+	kdf := env.KDF
+	if env.Version != 1 || env.Cipher != "aes-256-gcm" || kdf.Algorithm != "argon2id" ||
+		kdf.Time == 0 || kdf.Time > maxArchiveKDFTime || kdf.Memory == 0 || kdf.Memory > maxArchiveKDFMemory || kdf.Threads == 0 {
+		return nil, ErrArchiveInvalid
+	}
+
+	key := DeriveKEK(passphrase, kdf.Salt, KDFParams{Time: kdf.Time, Memory: kdf.Memory, Threads: kdf.Threads})
+	plaintext, err := open(key, env.Data)
+	if err != nil {
+		return nil, ErrArchivePassphrase
+	}
+	return plaintext, nil
+}
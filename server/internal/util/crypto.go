@@ -9,73 +9,115 @@ import (
 	"io"
 )
 
-var encryptKey []byte
+// DefaultServerKeyID config.json 中 encrypt_key 的密钥 ID
+const DefaultServerKeyID = "default"
 
-// InitCrypto 初始化加密模块
-func InitCrypto(key string) error {
-	if len(key) != 32 {
-		return errors.New("encrypt key must be 32 bytes")
+// serverKeys 服务器密钥，用于两步验证密钥等登录前就需要解密的数据
+var serverKeys *Keyring
+
+// InitCrypto 初始化加密模块：defaultKey 为 encrypt_key，keys 为轮换后新增的密钥，currentID 为当前加密使用的密钥
+func InitCrypto(defaultKey string, keys map[string]string, currentID string) error {
+	ring := map[string][]byte{DefaultServerKeyID: []byte(defaultKey)}
+	for kid, key := range keys {
+		ring[kid] = []byte(key)
+	}
+	if currentID == "" {
+		currentID = DefaultServerKeyID
+	}
+
+	k, err := NewKeyring(currentID, ring)
+	if err != nil {
+		return err
 	}
-	encryptKey = []byte(key)
+	serverKeys = k
 	return nil
 }
 
-// Encrypt 使用 AES-GCM 加密字符串
+// Encrypt 使用当前服务器密钥加密字符串
 func Encrypt(plaintext string) (string, error) {
-	if len(encryptKey) == 0 {
+	if serverKeys == nil {
 		return "", errors.New("encrypt key not initialized")
 	}
+	return serverKeys.Encrypt(plaintext)
+}
+
+// Decrypt 使用服务器密钥解密字符串
+// 不带版本的密文是引入密钥 ID 之前用 encrypt_key 加密的数据
+func Decrypt(ciphertext string) (string, error) {
+	if serverKeys == nil {
+		return "", errors.New("encrypt key not initialized")
+	}
+	if _, ok := CiphertextKeyID(ciphertext); !ok {
+		return DecryptWithKey(serverKeys.keys[DefaultServerKeyID], ciphertext)
+	}
+	return serverKeys.Decrypt(ciphertext)
+}
+
+// ServerKeyID 当前服务器密钥 ID
+func ServerKeyID() string {
+	return serverKeys.CurrentID()
+}
 
-	block, err := aes.NewCipher(encryptKey)
+// EncryptWithKey 使用指定的 32 字节密钥 AES-GCM 加密字符串
+func EncryptWithKey(key []byte, plaintext string) (string, error) {
+	ciphertext, err := seal(key, []byte(plaintext))
 	if err != nil {
 		return "", err
 	}
+	return base64.StdEncoding.EncodeToString(ciphertext), nil
+}
 
-	gcm, err := cipher.NewGCM(block)
+// DecryptWithKey 使用指定的 32 字节密钥 AES-GCM 解密字符串
+func DecryptWithKey(key []byte, ciphertext string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
 	if err != nil {
 		return "", err
 	}
 
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+	plaintext, err := open(key, data)
+	if err != nil {
 		return "", err
 	}
-
-	ciphertext := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
-	return base64.StdEncoding.EncodeToString(ciphertext), nil
+	return string(plaintext), nil
 }
 
-// Decrypt 使用 AES-GCM 解密字符串
-func Decrypt(ciphertext string) (string, error) {
-	if len(encryptKey) == 0 {
-		return "", errors.New("encrypt key not initialized")
+// seal 加密，返回 nonce + 密文
+func seal(key, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
 	}
 
-	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	gcm, err := cipher.NewGCM(block)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	block, err := aes.NewCipher(encryptKey)
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// open 解密 seal 的输出
+func open(key, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	gcm, err := cipher.NewGCM(block)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	nonceSize := gcm.NonceSize()
 	if len(data) < nonceSize {
-		return "", errors.New("ciphertext too short")
+		return nil, errors.New("ciphertext too short")
 	}
 
 	nonce, ciphertextBytes := data[:nonceSize], data[nonceSize:]
-	plaintext, err := gcm.Open(nil, nonce, ciphertextBytes, nil)
-	if err != nil {
-		return "", err
-	}
-
-	return string(plaintext), nil
+	return gcm.Open(nil, nonce, ciphertextBytes, nil)
 }
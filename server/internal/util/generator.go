@@ -0,0 +1,227 @@
+package util
+
+import (
+	"crypto/rand"
+	_ "embed"
+	"errors"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// 密码生成模式
+const (
+	GenerateRandom        = "random"        // 随机字符
+	GeneratePronounceable = "pronounceable" // 辅音、元音交替的可读密码
+	GeneratePassphrase    = "passphrase"    // 随机单词组成的密码短语
+)
+
+const (
+	charsLower   = "abcdefghijklmnopqrstuvwxyz"
+	charsUpper   = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	charsDigits  = "0123456789"
+	charsSymbols = "!@#$%^&*()-_=+[]{};:,.?/~"
+	// 容易混淆的字符
+	charsAmbiguous = "Il1O0o|`'\""
+
+	consonants = "bcdfghjklmnprstvwxz"
+	vowels     = "aeiou"
+)
+
+//go:embed data/words.txt
+var wordsFile string
+
+// passphraseWords 密码短语使用的单词表，同时作为强度评估的字典
+var passphraseWords = strings.Fields(wordsFile)
+
+var ErrNoCharset = errors.New("至少选择一种字符类型")
+
+// GeneratorOptions 密码生成选项，Length 用于 random、pronounceable，Words 用于 passphrase
+type GeneratorOptions struct {
+	Mode             string
+	Length           int
+	Lowercase        bool
+	Uppercase        bool
+	Digits           bool
+	Symbols          bool
+	ExcludeAmbiguous bool
+	Words            int
+	Separator        string
+	Capitalize       bool
+	IncludeNumber    bool
+}
+
+// GeneratePassword 按选项生成密码，返回密码和熵（比特）
+func GeneratePassword(opts GeneratorOptions) (string, float64, error) {
+	switch opts.Mode {
+	case GeneratePronounceable:
+		return generatePronounceable(opts)
+	case GeneratePassphrase:
+		return generatePassphrase(opts)
+	default:
+		return generateRandom(opts)
+	}
+}
+
+// generateRandom 每种选中的字符类型至少出现一次，其余字符从所有类型中随机选取
+func generateRandom(opts GeneratorOptions) (string, float64, error) {
+	var classes []string
+	for _, class := range []struct {
+		enabled bool
+		chars   string
+	}{
+		{opts.Lowercase, charsLower},
+		{opts.Uppercase, charsUpper},
+		{opts.Digits, charsDigits},
+		{opts.Symbols, charsSymbols},
+	} {
+		if !class.enabled {
+			continue
+		}
+		chars := class.chars
+		if opts.ExcludeAmbiguous {
+			chars = removeChars(chars, charsAmbiguous)
+		}
+		classes = append(classes, chars)
+	}
+	if len(classes) == 0 {
+		return "", 0, ErrNoCharset
+	}
+	if opts.Length < len(classes) {
+		opts.Length = len(classes)
+	}
+
+	pool := strings.Join(classes, "")
+	password := make([]byte, 0, opts.Length)
+	for _, chars := range classes {
+		c, err := randomChar(chars)
+		if err != nil {
+			return "", 0, err
+		}
+		password = append(password, c)
+	}
+	for len(password) < opts.Length {
+		c, err := randomChar(pool)
+		if err != nil {
+			return "", 0, err
+		}
+		password = append(password, c)
+	}
+	if err := shuffle(password); err != nil {
+		return "", 0, err
+	}
+
+	return string(password), float64(opts.Length) * math.Log2(float64(len(pool))), nil
+}
+
+// generatePronounceable 辅音、元音交替生成，可选首字母大写，末尾追加数字和符号
+func generatePronounceable(opts GeneratorOptions) (string, float64, error) {
+	var suffix []string
+	if opts.Digits {
+		suffix = append(suffix, charsDigits, charsDigits)
+	}
+	if opts.Symbols {
+		suffix = append(suffix, charsSymbols)
+	}
+	letters := opts.Length - len(suffix)
+	if letters < 2 {
+		letters = 2
+	}
+
+	var b strings.Builder
+	var entropy float64
+	for i := 0; i < letters; i++ {
+		chars := consonants
+		if i%2 == 1 {
+			chars = vowels
+		}
+		c, err := randomChar(chars)
+		if err != nil {
+			return "", 0, err
+		}
+		b.WriteByte(c)
+		entropy += math.Log2(float64(len(chars)))
+	}
+
+	password := b.String()
+	if opts.Uppercase {
+		password = strings.ToUpper(password[:1]) + password[1:]
+	}
+	for _, chars := range suffix {
+		c, err := randomChar(chars)
+		if err != nil {
+			return "", 0, err
+		}
+		password += string(c)
+		entropy += math.Log2(float64(len(chars)))
+	}
+	return password, entropy, nil
+}
+
+// generatePassphrase 从单词表中随机选取单词，可选首字母大写和在随机位置加一个数字
+func generatePassphrase(opts GeneratorOptions) (string, float64, error) {
+	words := make([]string, opts.Words)
+	for i := range words {
+		n, err := randomInt(len(passphraseWords))
+		if err != nil {
+			return "", 0, err
+		}
+		words[i] = passphraseWords[n]
+		if opts.Capitalize {
+			words[i] = strings.ToUpper(words[i][:1]) + words[i][1:]
+		}
+	}
+	entropy := float64(opts.Words) * math.Log2(float64(len(passphraseWords)))
+
+	if opts.IncludeNumber {
+		i, err := randomInt(len(words))
+		if err != nil {
+			return "", 0, err
+		}
+		digit, err := randomChar(charsDigits)
+		if err != nil {
+			return "", 0, err
+		}
+		words[i] += string(digit)
+		entropy += math.Log2(float64(len(words) * 10))
+	}
+	return strings.Join(words, opts.Separator), entropy, nil
+}
+
+// randomInt 返回 [0, n) 内均匀分布的随机数
+func randomInt(n int) (int, error) {
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+	return int(v.Int64()), nil
+}
+
+func randomChar(chars string) (byte, error) {
+	i, err := randomInt(len(chars))
+	if err != nil {
+		return 0, err
+	}
+	return chars[i], nil
+}
+
+// shuffle Fisher-Yates 洗牌
+func shuffle(b []byte) error {
+	for i := len(b) - 1; i > 0; i-- {
+		j, err := randomInt(i + 1)
+		if err != nil {
+			return err
+		}
+		b[i], b[j] = b[j], b[i]
+	}
+	return nil
+}
+
+func removeChars(s, remove string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(remove, r) {
+			return -1
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,87 @@
+package util
+
+import (
+	"errors"
+	"strings"
+)
+
+// 带版本的密文格式：v1:<密钥 ID>:<base64(nonce + AES-GCM 密文)>
+const ciphertextVersion = "v1"
+
+var (
+	ErrUnknownKeyID = errors.New("密文使用的密钥不存在")
+	ErrUnversioned  = errors.New("密文缺少版本和密钥 ID")
+)
+
+// Keyring 一组可用于解密的密钥，新数据总是用当前密钥加密
+// 轮换密钥后旧密钥仍保留在 Keyring 中，旧数据在重新加密前保持可读
+type Keyring struct {
+	current string
+	keys    map[string][]byte
+}
+
+// NewKeyring 创建 Keyring，current 必须是 keys 中的一个，每个密钥 32 字节
+func NewKeyring(current string, keys map[string][]byte) (*Keyring, error) {
+	if _, ok := keys[current]; !ok {
+		return nil, errors.New("current key not found in keyring")
+	}
+	for kid, key := range keys {
+		if kid == "" || strings.Contains(kid, ":") {
+			return nil, errors.New("invalid key id: " + kid)
+		}
+		if len(key) != 32 {
+			return nil, errors.New("key " + kid + " must be 32 bytes")
+		}
+	}
+	return &Keyring{current: current, keys: keys}, nil
+}
+
+// CurrentID 当前用于加密的密钥 ID
+func (k *Keyring) CurrentID() string {
+	return k.current
+}
+
+// IDs 所有密钥 ID
+func (k *Keyring) IDs() []string {
+	ids := make([]string, 0, len(k.keys))
+	for kid := range k.keys {
+		ids = append(ids, kid)
+	}
+	return ids
+}
+
+// Encrypt 用当前密钥加密，返回带版本和密钥 ID 的密文
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	ciphertext, err := EncryptWithKey(k.keys[k.current], plaintext)
+	if err != nil {
+		return "", err
+	}
+	return ciphertextVersion + ":" + k.current + ":" + ciphertext, nil
+}
+
+// Decrypt 按密文中的密钥 ID 选择密钥解密，不会把无法解密的数据当作明文返回
+func (k *Keyring) Decrypt(ciphertext string) (string, error) {
+	kid, data, ok := splitCiphertext(ciphertext)
+	if !ok {
+		return "", ErrUnversioned
+	}
+	key, ok := k.keys[kid]
+	if !ok {
+		return "", ErrUnknownKeyID
+	}
+	return DecryptWithKey(key, data)
+}
+
+// CiphertextKeyID 返回密文使用的密钥 ID，不是带版本的密文时返回 false
+func CiphertextKeyID(ciphertext string) (string, bool) {
+	kid, _, ok := splitCiphertext(ciphertext)
+	return kid, ok
+}
+
+func splitCiphertext(ciphertext string) (kid, data string, ok bool) {
+	parts := strings.SplitN(ciphertext, ":", 3)
+	if len(parts) != 3 || parts[0] != ciphertextVersion || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
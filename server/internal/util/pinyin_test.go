@@ -0,0 +1,41 @@
+package util
+
+import "testing"
+
+func TestPathPinyinIndex(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"工作/后台", "gongzuohoutai gzht gh 1:gongzuo 1:gz 2:houtai 2:ht"},
+		{"Go语言", "goyuyan goyy g 1:goyuyan 1:goyy"},
+		{"Work/阅读", "workyuedu workyd wy 1:work 2:yuedu 2:yd"},
+		{"Work/Docs", ""},
+	}
+	for _, tt := range tests {
+		if got := PathPinyinIndex(tt.path); got != tt.want {
+			t.Errorf("PathPinyinIndex(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestPinyin(t *testing.T) {
+	tests := []struct {
+		s        string
+		full     string
+		initials string
+	}{
+		{"书签", "shuqian", "sq"},
+		{"Go 语言 2", "goyuyan2", "goyy2"},
+		// 二级汉字和 GBK 扩充的繁体字没有拼音，原样保留
+		{"亓官", "亓guan", "亓g"},
+		{"丞相", "丞xiang", "丞x"},
+		{"礙事", "礙shi", "礙s"},
+		{"硋", "硋", "硋"},
+	}
+	for _, tt := range tests {
+		if full, initials := Pinyin(tt.s); full != tt.full || initials != tt.initials {
+			t.Errorf("Pinyin(%q) = %q, %q, want %q, %q", tt.s, full, initials, tt.full, tt.initials)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package util
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"golang.org/x/net/idna"
+)
+
+// 内置的 Public Suffix List（https://publicsuffix.org/list/public_suffix_list.dat），
+// 包含 ICANN 和私有域名部分（如 github.io），可用 `server psl refresh` 下载新版本覆盖
+//
+//go:embed data/public_suffix_list.dat
+var embeddedPublicSuffixList string
+
+// PublicSuffixListURL 官方 Public Suffix List 下载地址
+const PublicSuffixListURL = "https://publicsuffix.org/list/public_suffix_list.dat"
+
+// 有效的列表至少包含这么多条规则，用于识别下载到的错误页面
+const minPublicSuffixRules = 1000
+
+var ErrInvalidPublicSuffixList = errors.New("不是有效的 Public Suffix List")
+
+// 规则类型，同一个名称可以同时有多种规则
+const (
+	suffixRule          = 1 << iota // example
+	suffixRuleWildcard              // *.example，保存在 example 下
+	suffixRuleException             // !www.example
+)
+
+// PublicSuffixList 解析后的规则，键为 Unicode 小写形式的后缀
+type PublicSuffixList struct {
+	rules  map[string]uint8
+	Source string // 内置或文件路径
+}
+
+var currentPublicSuffixList atomic.Pointer[PublicSuffixList]
+
+func init() {
+	list, err := ParsePublicSuffixList(embeddedPublicSuffixList)
+	if err != nil {
+		panic("内置的 Public Suffix List 无效: " + err.Error())
+	}
+	list.Source = "内置"
+	currentPublicSuffixList.Store(list)
+}
+
+// ParsePublicSuffixList 解析 Public Suffix List 文件内容，规则过少时返回 ErrInvalidPublicSuffixList
+func ParsePublicSuffixList(content string) (*PublicSuffixList, error) {
+	list := &PublicSuffixList{rules: make(map[string]uint8)}
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		// 每行第一个空白之前为规则，// 开头为注释
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "//") {
+			continue
+		}
+		rule := fields[0]
+		kind := uint8(suffixRule)
+		switch {
+		case strings.HasPrefix(rule, "!"):
+			rule, kind = rule[1:], suffixRuleException
+		case strings.HasPrefix(rule, "*."):
+			rule, kind = rule[2:], suffixRuleWildcard
+		}
+		if rule = NormalizeHost(rule); rule != "" {
+			list.rules[rule] |= kind
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(list.rules) < minPublicSuffixRules || list.rules["com"]&suffixRule == 0 {
+		return nil, ErrInvalidPublicSuffixList
+	}
+	return list, nil
+}
+
+// LoadPublicSuffixList 使用 path 处的列表（由 `server psl refresh` 下载），文件不存在时继续使用内置列表
+func LoadPublicSuffixList(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	list, err := ParsePublicSuffixList(string(content))
+	if err != nil {
+		return err
+	}
+	list.Source = path
+	currentPublicSuffixList.Store(list)
+	return nil
+}
+
+// CurrentPublicSuffixList 当前使用的列表
+func CurrentPublicSuffixList() *PublicSuffixList {
+	return currentPublicSuffixList.Load()
+}
+
+// Len 规则数量
+func (l *PublicSuffixList) Len() int {
+	return len(l.rules)
+}
+
+// PublicSuffix 返回域名的公共后缀（如 com.au、github.io），没有匹配的规则时为最后一段
+func (l *PublicSuffixList) PublicSuffix(domain string) string {
+	labels := strings.Split(domain, ".")
+	// 从最长的后缀开始找，第一个匹配的就是最长的规则；例外规则的后缀去掉最左边一段
+	for i := range labels {
+		suffix := strings.Join(labels[i:], ".")
+		kind := l.rules[suffix]
+		if kind&suffixRuleException != 0 {
+			return strings.Join(labels[i+1:], ".")
+		}
+		if kind&suffixRule != 0 {
+			return suffix
+		}
+		if i+1 < len(labels) && l.rules[strings.Join(labels[i+1:], ".")]&suffixRuleWildcard != 0 {
+			return suffix
+		}
+	}
+	return labels[len(labels)-1]
+}
+
+// RegistrableDomain 返回可注册域名（公共后缀加一段，如 foo.github.io、bar.com.au），域名本身是公共后缀时原样返回
+func (l *PublicSuffixList) RegistrableDomain(domain string) string {
+	suffix := l.PublicSuffix(domain)
+	if len(suffix) >= len(domain) {
+		return domain
+	}
+	rest := domain[:len(domain)-len(suffix)-1]
+	if i := strings.LastIndex(rest, "."); i != -1 {
+		rest = rest[i+1:]
+	}
+	return rest + "." + suffix
+}
+
+// RegistrableDomain 使用当前列表计算可注册域名，domain 需先经过 NormalizeHost 处理
+func RegistrableDomain(domain string) string {
+	return CurrentPublicSuffixList().RegistrableDomain(domain)
+}
+
+// NormalizeHost 统一主机名的写法：小写、去掉末尾的点，国际化域名（包括 punycode）转换为 Unicode 形式，
+// IP 地址去掉方括号并使用标准写法；不是合法域名时只转换为小写
+func NormalizeHost(host string) string {
+	host = strings.TrimSuffix(strings.TrimSpace(host), ".")
+	if ip := net.ParseIP(strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")); ip != nil {
+		return ip.String()
+	}
+	ascii, err := idna.Lookup.ToASCII(host)
+	if err != nil {
+		return strings.ToLower(host)
+	}
+	if unicode, err := idna.Lookup.ToUnicode(ascii); err == nil {
+		return unicode
+	}
+	return ascii
+}
@@ -0,0 +1,595 @@
+package util
+
+import (
+	_ "embed"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// 密码强度评估参考 zxcvbn：把密码拆分为常见密码、单词、键盘序列、连续字符、重复字符、日期等模式，
+// 找出猜测次数最少的组合，按猜测次数给出 0-4 分
+
+// StrongScore 达到此分数的密码视为足够强
+const StrongScore = 3
+
+// 超过此长度的部分不参与匹配，避免超长密码耗费过多计算
+const maxStrengthLength = 100
+
+// 离线破解速度（每秒猜测次数，按慢哈希估计），用于换算破解时间
+const guessesPerSecond = 1e4
+
+//go:embed data/common_passwords.txt
+var commonPasswordsFile string
+
+var (
+	commonPasswordRanks = rankedDictionary(strings.Fields(commonPasswordsFile))
+	wordSet             = rankedDictionary(passphraseWords)
+)
+
+// PasswordStrength 密码强度评估结果
+type PasswordStrength struct {
+	Score        int      `json:"score"`         // 0-4
+	GuessesLog10 float64  `json:"guesses_log10"` // 估计猜测次数的常用对数
+	CrackTime    string   `json:"crack_time"`    // 离线破解（慢哈希）所需时间
+	Warning      string   `json:"warning,omitempty"`
+	Suggestions  []string `json:"suggestions,omitempty"`
+}
+
+// strengthMatch 密码中 [i, j] 范围的字符匹配到的模式
+type strengthMatch struct {
+	i, j    int
+	pattern string
+	guesses float64
+	l33t    bool // 字典匹配使用了 l33t 替换
+}
+
+const (
+	patternCommon     = "common"
+	patternDictionary = "dictionary"
+	patternUserInput  = "user_input"
+	patternSpatial    = "spatial"
+	patternSequence   = "sequence"
+	patternRepeat     = "repeat"
+	patternDate       = "date"
+	patternBruteforce = "bruteforce"
+)
+
+// EstimateStrength 评估密码强度，userInputs 为用户名、域名等与账号相关的字符串，包含它们的密码会被扣分
+func EstimateStrength(password string, userInputs ...string) PasswordStrength {
+	runes := []rune(password)
+	if len(runes) > maxStrengthLength {
+		runes = runes[:maxStrengthLength]
+	}
+	if len(runes) == 0 {
+		return PasswordStrength{Score: 0, CrackTime: formatCrackTime(0), Warning: "密码为空"}
+	}
+
+	var inputs []string
+	for _, input := range userInputs {
+		for _, part := range strings.FieldsFunc(strings.ToLower(input), func(r rune) bool {
+			return r == '.' || r == '@' || r == '-' || r == '_' || unicode.IsSpace(r)
+		}) {
+			if len([]rune(part)) >= 3 {
+				inputs = append(inputs, part)
+			}
+		}
+	}
+
+	matches := matchPatterns(runes, rankedDictionary(inputs))
+	guesses, sequence := mostGuessableSequence(runes, matches)
+
+	strength := PasswordStrength{
+		Score:        guessesToScore(guesses),
+		GuessesLog10: math.Round(math.Log10(guesses)*100) / 100,
+		CrackTime:    formatCrackTime(guesses / guessesPerSecond),
+	}
+	strength.Warning, strength.Suggestions = strengthFeedback(strength.Score, runes, sequence)
+	return strength
+}
+
+// guessesToScore zxcvbn 的评分区间
+func guessesToScore(guesses float64) int {
+	switch {
+	case guesses < 1e3+5:
+		return 0
+	case guesses < 1e6+5:
+		return 1
+	case guesses < 1e8+5:
+		return 2
+	case guesses < 1e10+5:
+		return 3
+	default:
+		return 4
+	}
+}
+
+func rankedDictionary(words []string) map[string]int {
+	ranks := make(map[string]int, len(words))
+	for i, w := range words {
+		w = strings.ToLower(w)
+		if _, ok := ranks[w]; !ok {
+			ranks[w] = i + 1
+		}
+	}
+	return ranks
+}
+
+// matchPatterns 找出密码中所有可能的模式匹配
+func matchPatterns(runes []rune, userInputs map[string]int) []strengthMatch {
+	lower := []rune(strings.ToLower(string(runes)))
+	var matches []strengthMatch
+	matches = append(matches, dictionaryMatches(runes, lower, userInputs)...)
+	matches = append(matches, spatialMatches(lower)...)
+	matches = append(matches, sequenceMatches(runes)...)
+	matches = append(matches, repeatMatches(runes)...)
+	matches = append(matches, dateMatches(runes)...)
+	return matches
+}
+
+// l33t 常见替换字符
+var l33tTable = map[rune]rune{
+	'4': 'a', '@': 'a', '8': 'b', '(': 'c', '3': 'e', '6': 'g', '9': 'g',
+	'1': 'i', '!': 'i', '|': 'l', '0': 'o', '$': 's', '5': 's', '7': 't', '+': 't', '2': 'z',
+}
+
+func dictionaryMatches(runes, lower []rune, userInputs map[string]int) []strengthMatch {
+	dictionaries := []struct {
+		pattern string
+		ranks   map[string]int
+		minLen  int
+		size    int // 非零时所有单词按同样的猜测次数计算（单词表没有按频率排序）
+	}{
+		{patternUserInput, userInputs, 3, 0},
+		{patternCommon, commonPasswordRanks, 4, 0},
+		{patternDictionary, wordSet, 3, len(wordSet)},
+	}
+
+	var matches []strengthMatch
+	for i := range lower {
+		for j := i; j < len(lower); j++ {
+			word := string(lower[i : j+1])
+			reversed := reverseString(word)
+			unl33t, subs := translateL33t(lower[i : j+1])
+			for _, dict := range dictionaries {
+				if j-i+1 < dict.minLen {
+					continue
+				}
+				candidates := []dictionaryCandidate{{word, 1, false}, {reversed, 2, false}}
+				if subs > 0 {
+					candidates = append(candidates, dictionaryCandidate{unl33t, math.Pow(2, float64(subs)), true})
+				}
+				for _, cand := range candidates {
+					rank, ok := dict.ranks[cand.word]
+					if !ok {
+						continue
+					}
+					if dict.size > 0 {
+						rank = dict.size
+					}
+					matches = append(matches, strengthMatch{
+						i: i, j: j, pattern: dict.pattern,
+						guesses: float64(rank) * uppercaseVariations(runes[i:j+1]) * cand.factor,
+						l33t:    cand.l33t,
+					})
+					break
+				}
+			}
+		}
+	}
+	return matches
+}
+
+// dictionaryCandidate 字典查找的候选：原样、反转或还原 l33t 替换，factor 为额外的猜测倍数
+type dictionaryCandidate struct {
+	word   string
+	factor float64
+	l33t   bool
+}
+
+// translateL33t 还原 l33t 替换，返回还原后的字符串和替换的字符数（不含字母的片段不还原）
+func translateL33t(word []rune) (string, int) {
+	hasLetter := false
+	for _, r := range word {
+		if unicode.IsLetter(r) {
+			hasLetter = true
+			break
+		}
+	}
+	if !hasLetter {
+		return "", 0
+	}
+
+	subs := 0
+	out := make([]rune, len(word))
+	for i, r := range word {
+		if sub, ok := l33tTable[r]; ok {
+			out[i] = sub
+			subs++
+		} else {
+			out[i] = r
+		}
+	}
+	return string(out), subs
+}
+
+// uppercaseVariations 大小写变化带来的额外猜测次数：全小写为 1，首字母、末字母或全部大写为 2
+func uppercaseVariations(word []rune) float64 {
+	upper, lower := 0, 0
+	for _, r := range word {
+		if unicode.IsUpper(r) {
+			upper++
+		} else if unicode.IsLower(r) {
+			lower++
+		}
+	}
+	if upper == 0 {
+		return 1
+	}
+	if lower == 0 || (upper == 1 && (unicode.IsUpper(word[0]) || unicode.IsUpper(word[len(word)-1]))) {
+		return 2
+	}
+	variations := 0.0
+	for k := 1; k <= upper && k <= lower; k++ {
+		variations += binomial(upper+lower, k)
+	}
+	return variations
+}
+
+func binomial(n, k int) float64 {
+	result := 1.0
+	for i := 1; i <= k; i++ {
+		result = result * float64(n-k+i) / float64(i)
+	}
+	return result
+}
+
+func reverseString(s string) string {
+	r := []rune(s)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
+}
+
+// 键盘上相邻的按键（QWERTY 横排和竖排）
+var keyboardRows = []string{
+	"`1234567890-=", "qwertyuiop[]\\", "asdfghjkl;'", "zxcvbnm,./",
+	"1qaz", "2wsx", "3edc", "4rfv", "5tgb", "6yhn", "7ujm", "8ik,", "9ol.", "0p;/",
+	"789456123", "147258369",
+}
+
+// spatialMatches 键盘上连续的按键（正向或反向，至少 4 个）
+func spatialMatches(lower []rune) []strengthMatch {
+	var matches []strengthMatch
+	for i := range lower {
+		best := 0
+		for _, row := range keyboardRows {
+			for _, r := range []string{row, reverseString(row)} {
+				n := 0
+				for i+n < len(lower) && strings.Contains(r, string(lower[i:i+n+1])) {
+					n++
+				}
+				if n > best {
+					best = n
+				}
+			}
+		}
+		if best >= 4 {
+			l := float64(best)
+			matches = append(matches, strengthMatch{i: i, j: i + best - 1, pattern: patternSpatial, guesses: 47 * 4 * l * l})
+		}
+	}
+	return matches
+}
+
+// sequenceMatches 字母或数字的连续序列，如 abcd、4321、aceg
+func sequenceMatches(runes []rune) []strengthMatch {
+	var matches []strengthMatch
+	i := 0
+	for i < len(runes)-2 {
+		delta := runes[i+1] - runes[i]
+		if delta == 0 || delta < -5 || delta > 5 || !sameClass(runes[i], runes[i+1]) {
+			i++
+			continue
+		}
+		j := i + 1
+		for j+1 < len(runes) && runes[j+1]-runes[j] == delta && sameClass(runes[j], runes[j+1]) {
+			j++
+		}
+		if j-i+1 >= 3 {
+			base := 26.0
+			switch first := unicode.ToLower(runes[i]); {
+			case strings.ContainsRune("az019", first):
+				base = 4
+			case unicode.IsDigit(first):
+				base = 10
+			}
+			if delta < 0 {
+				base *= 2
+			}
+			if delta != 1 && delta != -1 {
+				base *= 5
+			}
+			matches = append(matches, strengthMatch{i: i, j: j, pattern: patternSequence, guesses: base * float64(j-i+1)})
+		}
+		i = j
+	}
+	return matches
+}
+
+func sameClass(a, b rune) bool {
+	return (unicode.IsDigit(a) && unicode.IsDigit(b)) ||
+		(unicode.IsLower(a) && unicode.IsLower(b)) ||
+		(unicode.IsUpper(a) && unicode.IsUpper(b))
+}
+
+// repeatMatches 重复的字符或片段，如 aaaa、abcabc
+func repeatMatches(runes []rune) []strengthMatch {
+	var matches []strengthMatch
+	baseGuesses := map[string]float64{} // 同一片段只评估一次
+	for i := range runes {
+		for size := 1; size <= (len(runes)-i)/2; size++ {
+			count := 1
+			for i+(count+1)*size <= len(runes) && string(runes[i+count*size:i+(count+1)*size]) == string(runes[i:i+size]) {
+				count++
+			}
+			if count < 2 || (size == 1 && count < 3) {
+				continue
+			}
+			// 只匹配从重复开头开始的片段
+			if i >= size && string(runes[i-size:i]) == string(runes[i:i+size]) {
+				continue
+			}
+			base, ok := baseGuesses[string(runes[i:i+size])]
+			if !ok {
+				base, _ = mostGuessableSequence(runes[i:i+size], matchPatterns(runes[i:i+size], nil))
+				baseGuesses[string(runes[i:i+size])] = base
+			}
+			matches = append(matches, strengthMatch{i: i, j: i + count*size - 1, pattern: patternRepeat, guesses: base * float64(count)})
+		}
+	}
+	return matches
+}
+
+// dateMatches 年份（1900-2099）和纯数字日期（yyyymmdd、ddmmyyyy、mmddyyyy、yymmdd 等）
+func dateMatches(runes []rune) []strengthMatch {
+	refYear := time.Now().Year()
+	yearSpace := func(year int) float64 {
+		return math.Max(math.Abs(float64(year-refYear)), 20)
+	}
+
+	var matches []strengthMatch
+	for i := range runes {
+		for _, size := range []int{4, 6, 8} {
+			if i+size > len(runes) {
+				break
+			}
+			digits := string(runes[i : i+size])
+			if _, err := strconv.Atoi(digits); err != nil {
+				continue
+			}
+			if size == 4 {
+				if year, _ := strconv.Atoi(digits); year >= 1900 && year <= 2099 {
+					matches = append(matches, strengthMatch{i: i, j: i + 3, pattern: patternDate, guesses: yearSpace(year)})
+				}
+				continue
+			}
+			if year, ok := parseDigitDate(digits); ok {
+				matches = append(matches, strengthMatch{i: i, j: i + size - 1, pattern: patternDate, guesses: 365 * yearSpace(year)})
+			}
+		}
+	}
+	return matches
+}
+
+// parseDigitDate 尝试把 6 或 8 位数字解析为日期，返回年份
+func parseDigitDate(digits string) (int, bool) {
+	n := func(s string) int {
+		v, _ := strconv.Atoi(s)
+		return v
+	}
+	valid := func(year, month, day int) bool {
+		return month >= 1 && month <= 12 && day >= 1 && day <= 31 && year >= 1900 && year <= 2099
+	}
+	expandYear := func(yy int) int {
+		if yy > 50 {
+			return 1900 + yy
+		}
+		return 2000 + yy
+	}
+
+	if len(digits) == 8 {
+		if y, m, d := n(digits[:4]), n(digits[4:6]), n(digits[6:]); valid(y, m, d) {
+			return y, true
+		}
+		for _, md := range [][2]int{{n(digits[2:4]), n(digits[:2])}, {n(digits[:2]), n(digits[2:4])}} {
+			if y := n(digits[4:]); valid(y, md[0], md[1]) {
+				return y, true
+			}
+		}
+		return 0, false
+	}
+
+	if y, m, d := expandYear(n(digits[:2])), n(digits[2:4]), n(digits[4:]); valid(y, m, d) {
+		return y, true
+	}
+	for _, md := range [][2]int{{n(digits[2:4]), n(digits[:2])}, {n(digits[:2]), n(digits[2:4])}} {
+		if y := expandYear(n(digits[4:])); valid(y, md[0], md[1]) {
+			return y, true
+		}
+	}
+	return 0, false
+}
+
+// mostGuessableSequence 动态规划找出覆盖整个密码、猜测次数最少的匹配组合
+// 组合的猜测次数为 l! × 各匹配猜测次数之积 + 10000^(l-1)，l 为匹配个数；未匹配的片段按每字符 10 种可能暴力猜测
+func mostGuessableSequence(runes []rune, matches []strengthMatch) (float64, []strengthMatch) {
+	n := len(runes)
+	if n == 0 {
+		return 1, nil
+	}
+
+	// 作为密码的一部分时，单个匹配的猜测次数不少于 10（单字符）或 50
+	byEnd := make([][]strengthMatch, n)
+	for _, m := range matches {
+		if m.j-m.i+1 < n {
+			minGuesses := 50.0
+			if m.i == m.j {
+				minGuesses = 10
+			}
+			m.guesses = math.Max(m.guesses, minGuesses)
+		}
+		byEnd[m.j] = append(byEnd[m.j], m)
+	}
+	for i := 0; i < n; i++ {
+		for j := i; j < n; j++ {
+			guesses := math.Pow(10, float64(j-i+1))
+			if j-i+1 < n {
+				guesses = math.Max(guesses, 11)
+			}
+			byEnd[j] = append(byEnd[j], strengthMatch{i: i, j: j, pattern: patternBruteforce, guesses: guesses})
+		}
+	}
+
+	// pi[k][l]：前 k+1 个字符用 l 个匹配覆盖时猜测次数之积的最小值（0 表示不可达）
+	pi := make([][]float64, n)
+	prev := make([][]strengthMatch, n)
+	for k := 0; k < n; k++ {
+		pi[k] = make([]float64, k+2)
+		prev[k] = make([]strengthMatch, k+2)
+		for _, m := range byEnd[k] {
+			if m.i == 0 {
+				if pi[k][1] == 0 || m.guesses < pi[k][1] {
+					pi[k][1] = m.guesses
+					prev[k][1] = m
+				}
+				continue
+			}
+			for l, product := range pi[m.i-1] {
+				if product == 0 {
+					continue
+				}
+				if v := product * m.guesses; pi[k][l+1] == 0 || v < pi[k][l+1] {
+					pi[k][l+1] = v
+					prev[k][l+1] = m
+				}
+			}
+		}
+	}
+
+	best, bestLen := math.Inf(1), 0
+	for l, product := range pi[n-1] {
+		if product == 0 {
+			continue
+		}
+		g := factorial(l)*product + math.Pow(10000, float64(l-1))
+		if g < best {
+			best, bestLen = g, l
+		}
+	}
+
+	sequence := make([]strengthMatch, bestLen)
+	for k, l := n-1, bestLen; l > 0; l-- {
+		m := prev[k][l]
+		sequence[l-1] = m
+		k = m.i - 1
+	}
+	return best, sequence
+}
+
+func factorial(n int) float64 {
+	result := 1.0
+	for i := 2; i <= n; i++ {
+		result *= float64(i)
+	}
+	return result
+}
+
+// strengthFeedback 根据最长的非暴力匹配给出提示
+func strengthFeedback(score int, runes []rune, sequence []strengthMatch) (string, []string) {
+	if score >= StrongScore {
+		return "", nil
+	}
+
+	suggestions := []string{"使用更长的密码，或由 4 个以上不相关的单词组成的密码短语"}
+	var longest *strengthMatch
+	for i := range sequence {
+		m := &sequence[i]
+		if m.pattern != patternBruteforce && (longest == nil || m.j-m.i > longest.j-longest.i) {
+			longest = m
+		}
+	}
+	if longest == nil {
+		return "", suggestions
+	}
+
+	var warning string
+	switch longest.pattern {
+	case patternCommon:
+		warning = "这是常见密码"
+		if len(sequence) == 1 {
+			warning = "这是最常见的密码之一"
+		}
+	case patternDictionary:
+		warning = "单个单词容易被猜到"
+		if len(sequence) > 1 {
+			warning = "常见单词容易被猜到"
+		}
+	case patternUserInput:
+		warning = "不要在密码中使用用户名或网站名称"
+	case patternSpatial:
+		warning = "键盘上相邻的按键容易被猜到"
+		suggestions = append(suggestions, "避免使用键盘上连续的按键")
+	case patternSequence:
+		warning = "abc、1234 这样的连续字符容易被猜到"
+		suggestions = append(suggestions, "避免连续的字母或数字")
+	case patternRepeat:
+		warning = "重复的字符或片段容易被猜到"
+		suggestions = append(suggestions, "避免重复的字符或片段")
+	case patternDate:
+		warning = "日期和年份容易被猜到"
+		suggestions = append(suggestions, "避免使用与自己相关的日期和年份")
+	}
+
+	word := runes[longest.i : longest.j+1]
+	if (longest.pattern == patternCommon || longest.pattern == patternDictionary) && uppercaseVariations(word) > 1 {
+		suggestions = append(suggestions, "大小写变化作用不大")
+	}
+	if longest.l33t {
+		suggestions = append(suggestions, "用 @ 代替 a 这样的替换作用不大")
+	}
+	return warning, suggestions
+}
+
+// formatCrackTime 把破解所需秒数格式化为中文描述
+func formatCrackTime(seconds float64) string {
+	const (
+		minute = 60
+		hour   = 60 * minute
+		day    = 24 * hour
+		month  = 31 * day
+		year   = 12 * month
+	)
+	switch {
+	case seconds < 1:
+		return "不到 1 秒"
+	case seconds < minute:
+		return fmt.Sprintf("%d 秒", int(seconds))
+	case seconds < hour:
+		return fmt.Sprintf("%d 分钟", int(seconds/minute))
+	case seconds < day:
+		return fmt.Sprintf("%d 小时", int(seconds/hour))
+	case seconds < month:
+		return fmt.Sprintf("%d 天", int(seconds/day))
+	case seconds < year:
+		return fmt.Sprintf("%d 个月", int(seconds/month))
+	case seconds < 100*year:
+		return fmt.Sprintf("%d 年", int(seconds/year))
+	default:
+		return "数百年以上"
+	}
+}
@@ -0,0 +1,174 @@
+package util
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TOTP 参数（RFC 6238 默认值，主流验证器 App 均支持）
+const (
+	TOTPPeriod = 30 // 时间步长（秒）
+	TOTPDigits = 6
+	totpSkew   = 1 // 允许前后各偏差一个时间步，容忍设备时钟误差
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret 生成 160 位随机密钥，返回 Base32 编码（无填充）
+func GenerateTOTPSecret() (string, error) {
+	buf := make([]byte, 20)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(buf), nil
+}
+
+// TOTPStep 返回时间 t 所在的时间步
+func TOTPStep(t time.Time) int64 {
+	return t.Unix() / TOTPPeriod
+}
+
+// TOTPCode 计算指定时间步的验证码（RFC 4226 HOTP，HMAC-SHA1）
+func TOTPCode(secret string, step int64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return "", err
+	}
+	return hotp(sha1.New, key, step, TOTPDigits), nil
+}
+
+// hotp RFC 4226 HOTP，hash 为 HMAC 使用的哈希算法
+func hotp(h func() hash.Hash, key []byte, counter int64, digits int) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+	mac := hmac.New(h, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// 动态截断
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value%mod)
+}
+
+// VerifyTOTP 校验验证码，返回匹配的时间步（调用方据此拒绝重放），不匹配时返回 false
+func VerifyTOTP(secret, code string, t time.Time) (int64, bool) {
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) != TOTPDigits {
+		return 0, false
+	}
+
+	current := TOTPStep(t)
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		expected, err := TOTPCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// TOTPURI 生成 otpauth:// URI，可直接生成二维码供验证器 App 扫描
+func TOTPURI(issuer, account, secret string) string {
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(TOTPDigits))
+	params.Set("period", fmt.Sprint(TOTPPeriod))
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+var ErrInvalidTOTPSecret = errors.New("无效的 TOTP 密钥")
+
+// TOTPConfig 凭证中保存的第三方网站 TOTP 参数
+type TOTPConfig struct {
+	Secret    string // Base32 编码的密钥（无填充）
+	Algorithm string // SHA1、SHA256 或 SHA512
+	Digits    int
+	Period    int // 时间步长（秒）
+}
+
+// ParseTOTPSecret 解析 otpauth://totp URI 或 Base32 密钥（忽略空格、短横线和大小写）
+func ParseTOTPSecret(input string) (*TOTPConfig, error) {
+	input = strings.TrimSpace(input)
+	cfg := &TOTPConfig{Algorithm: "SHA1", Digits: TOTPDigits, Period: TOTPPeriod}
+
+	secret := input
+	if strings.HasPrefix(strings.ToLower(input), "otpauth://") {
+		u, err := url.Parse(input)
+		if err != nil || !strings.EqualFold(u.Host, "totp") {
+			return nil, ErrInvalidTOTPSecret
+		}
+		query := u.Query()
+		secret = query.Get("secret")
+		if v := query.Get("algorithm"); v != "" {
+			cfg.Algorithm = strings.ToUpper(v)
+		}
+		if v := query.Get("digits"); v != "" {
+			if cfg.Digits, err = strconv.Atoi(v); err != nil {
+				return nil, ErrInvalidTOTPSecret
+			}
+		}
+		if v := query.Get("period"); v != "" {
+			if cfg.Period, err = strconv.Atoi(v); err != nil {
+				return nil, ErrInvalidTOTPSecret
+			}
+		}
+	}
+
+	secret = strings.ToUpper(strings.TrimRight(strings.NewReplacer(" ", "", "-", "").Replace(secret), "="))
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil || len(key) == 0 {
+		return nil, ErrInvalidTOTPSecret
+	}
+	if totpHash(cfg.Algorithm) == nil || cfg.Digits < 6 || cfg.Digits > 8 || cfg.Period < 1 || cfg.Period > 300 {
+		return nil, ErrInvalidTOTPSecret
+	}
+	cfg.Secret = secret
+	return cfg, nil
+}
+
+func totpHash(algorithm string) func() hash.Hash {
+	switch algorithm {
+	case "SHA1":
+		return sha1.New
+	case "SHA256":
+		return sha256.New
+	case "SHA512":
+		return sha512.New
+	}
+	return nil
+}
+
+// Code 返回时间 t 的验证码和剩余有效秒数
+func (cfg *TOTPConfig) Code(t time.Time) (string, int, error) {
+	h := totpHash(cfg.Algorithm)
+	key, err := totpEncoding.DecodeString(cfg.Secret)
+	if h == nil || err != nil {
+		return "", 0, ErrInvalidTOTPSecret
+	}
+	period := int64(cfg.Period)
+	return hotp(h, key, t.Unix()/period, cfg.Digits), int(period - t.Unix()%period), nil
+}
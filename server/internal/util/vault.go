@@ -0,0 +1,172 @@
+package util
+
+import (
+	"crypto/rand"
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+)
+
+var (
+	ErrVaultLocked        = errors.New("保险库已锁定，请输入主密码解锁")
+	ErrMasterPasswordFail = errors.New("主密码错误")
+)
+
+// VaultKeySize 保险库密钥长度（AES-256）
+const VaultKeySize = 32
+
+// KDFParams Argon2id 参数，随保险库一起保存，以后调整默认值不影响已有保险库
+type KDFParams struct {
+	Time    uint32 // 迭代次数
+	Memory  uint32 // 内存（KiB）
+	Threads uint8
+}
+
+// DefaultKDFParams 新建保险库使用的 Argon2id 参数（RFC 9106 推荐的低内存配置）
+var DefaultKDFParams = KDFParams{Time: 3, Memory: 64 * 1024, Threads: 4}
+
+// RandomBytes 生成随机字节
+func RandomBytes(n int) ([]byte, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// DeriveKEK 用 Argon2id 从主密码派生包装密钥（KEK）
+func DeriveKEK(masterPassword string, salt []byte, params KDFParams) []byte {
+	return argon2.IDKey([]byte(masterPassword), salt, params.Time, params.Memory, params.Threads, VaultKeySize)
+}
+
+// WrapKey 用包装密钥加密保险库密钥
+func WrapKey(kek, vaultKey []byte) ([]byte, error) {
+	return seal(kek, vaultKey)
+}
+
+// UnwrapKey 用包装密钥解密保险库密钥，主密码错误时返回 ErrMasterPasswordFail
+func UnwrapKey(kek, wrapped []byte) ([]byte, error) {
+	key, err := open(kek, wrapped)
+	if err != nil {
+		return nil, ErrMasterPasswordFail
+	}
+	return key, nil
+}
+
+// 已解锁的保险库密钥只保存在内存中，按登录会话分别保存：重启、手动锁定、超时、退出登录或会话被删除后需要重新输入主密码
+// API Token 没有会话，只能在该用户有已解锁的会话时读写凭证，且不会顺延自动锁定时间
+type unlockedVault struct {
+	userID    int64
+	keys      *Keyring
+	timeout   time.Duration
+	expiresAt time.Time
+}
+
+func (v *unlockedVault) expired(now time.Time) bool {
+	return v.timeout > 0 && now.After(v.expiresAt)
+}
+
+var (
+	vaultMu sync.Mutex
+	vaults  = map[int64]*unlockedVault{} // 会话 ID → 已解锁的保险库
+)
+
+// UnlockVault 保存会话已解锁的保险库密钥，timeout 内无操作自动锁定（0 表示不自动锁定）
+func UnlockVault(userID, sessionID int64, keys *Keyring, timeout time.Duration) {
+	vaultMu.Lock()
+	defer vaultMu.Unlock()
+
+	v := &unlockedVault{userID: userID, keys: keys, timeout: timeout}
+	if timeout > 0 {
+		v.expiresAt = time.Now().Add(timeout)
+	}
+	vaults[sessionID] = v
+}
+
+// LockVault 锁定会话的保险库，丢弃内存中的密钥
+func LockVault(sessionID int64) {
+	vaultMu.Lock()
+	defer vaultMu.Unlock()
+
+	delete(vaults, sessionID)
+}
+
+// LockUserVaults 锁定用户所有会话的保险库（退出所有设备、修改登录密码、轮换保险库密钥时）
+func LockUserVaults(userID int64) {
+	vaultMu.Lock()
+	defer vaultMu.Unlock()
+
+	for sessionID, v := range vaults {
+		if v.userID == userID {
+			delete(vaults, sessionID)
+		}
+	}
+}
+
+// TouchVault 检查请求能否使用保险库：登录会话要求本会话已解锁，并顺延自动锁定时间；
+// API Token 请求（sessionID 为 0）要求该用户有已解锁的会话，不顺延
+func TouchVault(userID, sessionID int64) bool {
+	vaultMu.Lock()
+	defer vaultMu.Unlock()
+
+	now := time.Now()
+	if sessionID == 0 {
+		return userVault(userID, now) != nil
+	}
+
+	v, ok := vaults[sessionID]
+	if !ok || v.userID != userID {
+		return false
+	}
+	if v.expired(now) {
+		delete(vaults, sessionID)
+		return false
+	}
+	if v.timeout > 0 {
+		v.expiresAt = now.Add(v.timeout)
+	}
+	return true
+}
+
+// VaultKeyring 获取用户已解锁的保险库密钥（同一用户各会话的密钥相同），不顺延自动锁定时间；
+// 是否允许当前请求使用保险库由 TouchVault 检查
+func VaultKeyring(userID int64) (*Keyring, error) {
+	vaultMu.Lock()
+	defer vaultMu.Unlock()
+
+	v := userVault(userID, time.Now())
+	if v == nil {
+		return nil, ErrVaultLocked
+	}
+	return v.keys, nil
+}
+
+// userVault 返回用户任一未过期的已解锁保险库，顺便清理已过期的，调用方需持有 vaultMu
+func userVault(userID int64, now time.Time) *unlockedVault {
+	var found *unlockedVault
+	for sessionID, v := range vaults {
+		if v.userID != userID {
+			continue
+		}
+		if v.expired(now) {
+			delete(vaults, sessionID)
+			continue
+		}
+		found = v
+	}
+	return found
+}
+
+// VaultUnlockedUntil 会话的保险库自动锁定的时间，未解锁返回 false，不自动锁定时返回零值
+func VaultUnlockedUntil(userID, sessionID int64) (time.Time, bool) {
+	vaultMu.Lock()
+	defer vaultMu.Unlock()
+
+	v, ok := vaults[sessionID]
+	if !ok || v.userID != userID || v.expired(time.Now()) {
+		return time.Time{}, false
+	}
+	return v.expiresAt, true
+}
@@ -1,28 +1,36 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"os"
 	"path/filepath"
+	"time"
 
 	"Nibstash_v2_server/config"
 	"Nibstash_v2_server/database"
+	"Nibstash_v2_server/internal/favicon"
 	"Nibstash_v2_server/internal/handler"
 	"Nibstash_v2_server/internal/middleware"
+	"Nibstash_v2_server/internal/model"
 	"Nibstash_v2_server/internal/repository"
 	"Nibstash_v2_server/internal/util"
 
 	"github.com/gin-gonic/gin"
 )
 
+// configPath 配置文件路径
+const configPath = "config.json"
+
 func main() {
 	// 加载配置
-	if err := config.Load("config.json"); err != nil {
+	if err := config.Load(configPath); err != nil {
 		log.Printf("加载配置失败，使用默认配置: %v", err)
 	}
 
 	// 初始化加密模块
-	if err := util.InitCrypto(config.App.EncryptKey); err != nil {
+	if err := util.InitCrypto(config.App.EncryptKey, config.App.EncryptKeys, config.App.EncryptKeyID); err != nil {
 		log.Fatalf("初始化加密模块失败: %v", err)
 	}
 
@@ -32,7 +40,18 @@ func main() {
 	}
 	defer database.Close()
 
-	// 执行数据库迁移
+	// 使用 `server psl refresh` 下载的 Public Suffix List，不存在时使用内置列表
+	if err := util.LoadPublicSuffixList(config.App.PublicSuffixList()); err != nil {
+		log.Printf("读取 Public Suffix List 失败，使用内置列表: %v", err)
+	}
+
+	// 命令行子命令（如 migrate status），执行完直接退出
+	if len(os.Args) > 1 {
+		exitOnError(runCommand(os.Args[1:]))
+		return
+	}
+
+	// 执行数据库迁移（数据库版本高于程序时拒绝启动）
 	if err := database.Migrate(); err != nil {
 		log.Fatalf("数据库迁移失败: %v", err)
 	}
@@ -43,15 +62,64 @@ func main() {
 		log.Fatalf("创建默认用户失败: %v", err)
 	}
 
-	// 同步现有书签的域名到 domains 表
+	// 管理员仍在使用默认密码时拒绝启动，除非在配置中显式允许
+	if !config.App.AllowDefaultPassword {
+		admins, err := userRepo.AdminsWithPassword(config.DefaultPassword)
+		if err != nil {
+			log.Fatalf("检查默认密码失败: %v", err)
+		}
+		if len(admins) > 0 {
+			log.Fatalf("管理员 %v 仍在使用默认密码，请执行 `server passwd <用户名> <新密码>` 修改密码，"+
+				"或在 config.json 中设置 \"allow_default_password\": true 后启动", admins)
+		}
+	}
+
+	// 按 Public Suffix List 重新计算顶级域名（迁移 0017 后首次启动时处理全部旧数据）
 	domainRepo := repository.NewDomainRepository()
+	if n, err := domainRepo.SyncTopDomains(false); err != nil {
+		log.Printf("重新计算顶级域名失败: %v", err)
+	} else if n > 0 {
+		log.Printf("已重新计算 %d 个域名的顶级域名", n)
+	}
+
+	// 补全书签的主机名和顶级域名（升级后首次启动时为已有数据计算）
+	if _, err := repository.NewBookmarkRepository().SyncDomains(false); err != nil {
+		log.Printf("同步书签域名失败: %v", err)
+	}
+
+	// 同步现有书签的域名到 domains 表
 	if err := domainRepo.SyncDomainsFromBookmarks(); err != nil {
 		log.Printf("同步域名失败: %v", err)
 	}
 
+	// 补全拼音索引（升级后首次启动时为已有数据生成）
+	if err := repository.NewBookmarkRepository().SyncPinyin(); err != nil {
+		log.Printf("同步书签拼音失败: %v", err)
+	}
+	if err := repository.NewTagRepository().SyncPinyin(); err != nil {
+		log.Printf("同步标签拼音失败: %v", err)
+	}
+	if err := repository.NewFolderRepository().SyncPinyin(); err != nil {
+		log.Printf("同步文件夹拼音失败: %v", err)
+	}
+
+	// 定期永久删除回收站中过期的条目、过期的安全事件和会话
+	go purgeExpired(repository.NewTrashRepository(), repository.NewSecurityRepository(), repository.NewSessionRepository())
+
+	// 后台获取书签主机的网站图标
+	if workers := config.App.FaviconWorkers(); workers > 0 {
+		fetcher := favicon.NewFetcher(favicon.NewClient(config.App.FaviconAllowPrivate))
+		go favicon.NewWorker(fetcher, workers).Run(context.Background())
+	}
+
 	// 创建 Gin 实例
 	r := gin.Default()
 
+	// 只信任配置的反向代理，避免客户端伪造 X-Forwarded-For 绕过登录限流
+	if err := r.SetTrustedProxies(config.App.TrustedProxies); err != nil {
+		log.Fatalf("trusted_proxies 配置错误: %v", err)
+	}
+
 	// 中间件
 	r.Use(middleware.CORS())
 
@@ -62,69 +130,152 @@ func main() {
 	tagHandler := handler.NewTagHandler()
 	domainHandler := handler.NewDomainHandler()
 	credentialHandler := handler.NewCredentialHandler()
+	generatorHandler := handler.NewGeneratorHandler()
 	faviconHandler := handler.NewFaviconHandler()
 	importHandler := handler.NewImportHandler()
 	bookmarkletHandler := handler.NewBookmarkletHandler()
+	trashHandler := handler.NewTrashHandler()
+	tokenHandler := handler.NewTokenHandler()
+	invitationHandler := handler.NewInvitationHandler()
+	totpHandler := handler.NewTOTPHandler()
+	securityHandler := handler.NewSecurityHandler()
+	sessionHandler := handler.NewSessionHandler()
+	vaultHandler := handler.NewVaultHandler()
 
 	// API 路由
 	api := r.Group("/api")
 	{
 		// 认证相关（无需登录）
 		api.POST("/auth/login", authHandler.Login)
+		api.POST("/auth/login/totp", authHandler.LoginTOTP)
+		api.POST("/auth/register", authHandler.Register)
+		api.POST("/auth/refresh", authHandler.Refresh)
 
 		// Bookmarklet（自己处理认证）
 		api.GET("/bookmarklet", bookmarkletHandler.Handle)
 		api.POST("/bookmarklet", bookmarkletHandler.Save)
 
-		// 需要认证的路由
+		// 服务器缓存的网站图标（无需登录，<img> 无法携带认证信息；只返回已缓存的图标）
+		api.GET("/favicons/host/:host", faviconHandler.Host)
+
+		// 需要认证的路由（登录会话或 API Token，API Token 受权限范围限制）
 		auth := api.Group("")
 		auth.Use(middleware.Auth())
 		{
+			bookmarksRead := middleware.RequireScope(model.ScopeBookmarksRead)
+			bookmarksWrite := middleware.RequireScope(model.ScopeBookmarksWrite)
+			credentialsRead := middleware.RequireScope(model.ScopeCredentialsRead)
+			credentialsWrite := middleware.RequireScope(model.ScopeCredentialsWrite)
+			sessionOnly := middleware.SessionOnly()
+			adminOnly := middleware.AdminOnly()
+			stepUp := middleware.RequireStepUp()
+			vaultUnlocked := middleware.RequireVaultUnlocked()
+
 			// 用户
 			auth.GET("/auth/me", authHandler.GetMe)
-			auth.PUT("/auth/password", authHandler.ChangePassword)
+			auth.PUT("/auth/password", sessionOnly, authHandler.ChangePassword)
+			auth.POST("/auth/logout", sessionOnly, authHandler.Logout)
+
+			// 登录会话
+			auth.GET("/sessions", sessionOnly, sessionHandler.List)
+			auth.DELETE("/sessions/:id", sessionOnly, sessionHandler.Delete)
+			auth.DELETE("/sessions", sessionOnly, sessionHandler.DeleteAll)
+
+			// 两步验证
+			auth.GET("/auth/totp", sessionOnly, totpHandler.Status)
+			auth.POST("/auth/totp/setup", sessionOnly, totpHandler.Setup)
+			auth.POST("/auth/totp/enable", sessionOnly, totpHandler.Enable)
+			auth.POST("/auth/totp/disable", sessionOnly, totpHandler.Disable)
+			auth.POST("/auth/totp/recovery-codes", sessionOnly, totpHandler.RegenerateRecoveryCodes)
+			auth.POST("/auth/step-up", sessionOnly, totpHandler.StepUp)
+
+			// 安全事件
+			auth.GET("/security/events", sessionOnly, securityHandler.ListEvents)
+
+			// API Token
+			auth.GET("/tokens", sessionOnly, tokenHandler.List)
+			auth.POST("/tokens", sessionOnly, tokenHandler.Create)
+			auth.DELETE("/tokens/:id", sessionOnly, tokenHandler.Delete)
+
+			// 邀请码（仅管理员）
+			auth.GET("/invitations", sessionOnly, adminOnly, invitationHandler.List)
+			auth.POST("/invitations", sessionOnly, adminOnly, invitationHandler.Create)
+			auth.DELETE("/invitations/:id", sessionOnly, adminOnly, invitationHandler.Delete)
 
 			// 书签
-			auth.GET("/bookmarks", bookmarkHandler.List)
-			auth.POST("/bookmarks", bookmarkHandler.Create)
-			auth.GET("/bookmarks/:id", bookmarkHandler.Get)
-			auth.PUT("/bookmarks/:id", bookmarkHandler.Update)
-			auth.DELETE("/bookmarks/:id", bookmarkHandler.Delete)
-			auth.POST("/bookmarks/batch", bookmarkHandler.Batch)
-			auth.GET("/bookmarks/export", bookmarkHandler.Export)
-			auth.POST("/bookmarks/import", importHandler.Import)
-			auth.DELETE("/bookmarks/clear", bookmarkHandler.ClearAll)
-			auth.POST("/bookmarks/clear-folder", bookmarkHandler.ClearFolder)
+			auth.GET("/bookmarks", bookmarksRead, bookmarkHandler.List)
+			auth.POST("/bookmarks", bookmarksWrite, bookmarkHandler.Create)
+			auth.GET("/bookmarks/:id", bookmarksRead, bookmarkHandler.Get)
+			auth.PUT("/bookmarks/:id", bookmarksWrite, bookmarkHandler.Update)
+			auth.DELETE("/bookmarks/:id", bookmarksWrite, bookmarkHandler.Delete)
+			auth.POST("/bookmarks/batch", bookmarksWrite, bookmarkHandler.Batch)
+			auth.GET("/bookmarks/export", bookmarksRead, bookmarkHandler.Export)
+			auth.POST("/bookmarks/import", bookmarksWrite, importHandler.Import)
+			auth.DELETE("/bookmarks/clear", bookmarksWrite, bookmarkHandler.ClearAll)
+			auth.POST("/bookmarks/clear-folder", bookmarksWrite, bookmarkHandler.ClearFolder)
 
 			// 文件夹
-			auth.GET("/folders", folderHandler.List)
-			auth.POST("/folders", folderHandler.Create)
-			auth.PUT("/folders/move", folderHandler.Move)
-			auth.PUT("/folders/merge", folderHandler.Merge)
-			auth.DELETE("/folders", folderHandler.Delete)
+			auth.GET("/folders", bookmarksRead, folderHandler.List)
+			auth.POST("/folders", bookmarksWrite, folderHandler.Create)
+			auth.PUT("/folders/move", bookmarksWrite, folderHandler.Move)
+			auth.PUT("/folders/merge", bookmarksWrite, folderHandler.Merge)
+			auth.DELETE("/folders", bookmarksWrite, folderHandler.Delete)
 
 			// 标签
-			auth.GET("/tags", tagHandler.List)
-			auth.POST("/tags", tagHandler.Create)
-			auth.PUT("/tags/:id", tagHandler.Update)
-			auth.DELETE("/tags/:id", tagHandler.Delete)
+			auth.GET("/tags", bookmarksRead, tagHandler.List)
+			auth.POST("/tags", bookmarksWrite, tagHandler.Create)
+			auth.PUT("/tags/:id", bookmarksWrite, tagHandler.Update)
+			auth.DELETE("/tags/:id", bookmarksWrite, tagHandler.Delete)
 
 			// 域名（实时计算）
-			auth.GET("/domains", domainHandler.List)
-			auth.GET("/domains/:domain/bookmarks", domainHandler.GetBookmarks)
-			auth.DELETE("/domains/:domain", domainHandler.Delete)
-
-			// 凭证
-			auth.GET("/credentials", credentialHandler.List)
-			auth.POST("/credentials", credentialHandler.Create)
-			auth.GET("/credentials/:id", credentialHandler.Get)
-			auth.GET("/credentials/domain/:domain", credentialHandler.GetByDomain)
-			auth.PUT("/credentials/:id", credentialHandler.Update)
-			auth.DELETE("/credentials/:id", credentialHandler.Delete)
+			auth.GET("/domains", bookmarksRead, domainHandler.List)
+			auth.GET("/domains/:domain/bookmarks", bookmarksRead, domainHandler.GetBookmarks)
+			auth.DELETE("/domains/:domain", credentialsWrite, stepUp, domainHandler.Delete)
+
+			// 等价域名（组内的顶级域名共用凭证）
+			auth.GET("/equivalent-domains", credentialsRead, domainHandler.ListEquivalents)
+			auth.POST("/equivalent-domains", credentialsWrite, domainHandler.CreateEquivalent)
+			auth.PUT("/equivalent-domains/global/:key", credentialsWrite, domainHandler.ExcludeGlobalEquivalent)
+			auth.PUT("/equivalent-domains/:id", credentialsWrite, domainHandler.UpdateEquivalent)
+			auth.DELETE("/equivalent-domains/:id", credentialsWrite, domainHandler.DeleteEquivalent)
+
+			// 密码生成和强度评估
+			auth.GET("/password/generate", credentialsRead, generatorHandler.Generate)
+			auth.POST("/password/strength", credentialsRead, generatorHandler.Strength)
+
+			// 保险库（主密码）
+			auth.GET("/vault", sessionOnly, vaultHandler.Status)
+			auth.POST("/vault/setup", sessionOnly, vaultHandler.Setup)
+			auth.POST("/vault/unlock", sessionOnly, vaultHandler.Unlock)
+			auth.POST("/vault/lock", sessionOnly, vaultHandler.Lock)
+			auth.PUT("/vault/password", sessionOnly, vaultHandler.ChangePassword)
+			auth.POST("/vault/rotate", sessionOnly, vaultHandler.Rotate)
+
+			// 凭证（返回解密后密码的接口需要二次验证，读写密码需要保险库已解锁）
+			auth.GET("/credentials", credentialsRead, stepUp, vaultUnlocked, credentialHandler.List)
+			auth.POST("/credentials", credentialsWrite, vaultUnlocked, credentialHandler.Create)
+			auth.POST("/credentials/import", credentialsWrite, vaultUnlocked, importHandler.ImportCredentials)
+			auth.POST("/credentials/export", credentialsRead, stepUp, vaultUnlocked, credentialHandler.Export)
+			auth.GET("/credentials/health", credentialsRead, stepUp, vaultUnlocked, credentialHandler.Health)
+			auth.GET("/credentials/match", credentialsRead, stepUp, vaultUnlocked, credentialHandler.Match)
+			auth.GET("/credentials/:id", credentialsRead, stepUp, vaultUnlocked, credentialHandler.Get)
+			auth.GET("/credentials/:id/totp", credentialsRead, stepUp, vaultUnlocked, credentialHandler.TOTP)
+			auth.GET("/credentials/:id/history", credentialsRead, stepUp, vaultUnlocked, credentialHandler.History)
+			auth.GET("/credentials/:id/history/:history_id", credentialsRead, stepUp, vaultUnlocked, credentialHandler.GetHistory)
+			auth.POST("/credentials/:id/restore", credentialsWrite, stepUp, vaultUnlocked, credentialHandler.Restore)
+			auth.GET("/credentials/domain/:domain", credentialsRead, stepUp, vaultUnlocked, credentialHandler.GetByDomain)
+			auth.PUT("/credentials/:id", credentialsWrite, stepUp, vaultUnlocked, credentialHandler.Update)
+			auth.DELETE("/credentials/:id", credentialsWrite, stepUp, credentialHandler.Delete)
+
+			// 回收站
+			auth.GET("/trash", sessionOnly, trashHandler.List)
+			auth.POST("/trash/restore", sessionOnly, trashHandler.Restore)
+			auth.POST("/trash/purge", sessionOnly, trashHandler.Purge)
+			auth.DELETE("/trash", sessionOnly, trashHandler.Empty)
 
 			// Favicon
-			auth.GET("/favicons/pending", faviconHandler.GetPending)
-			auth.PUT("/favicons/:id", faviconHandler.Update)
+			auth.GET("/favicons/pending", bookmarksRead, faviconHandler.GetPending)
+			auth.PUT("/favicons/:id", bookmarksWrite, faviconHandler.Update)
 		}
 	}
 
@@ -154,3 +305,22 @@ func main() {
 		log.Fatalf("启动服务器失败: %v", err)
 	}
 }
+
+// purgeExpired 启动时及之后每小时清理一次回收站中超过保留期限的条目、过期的安全事件和会话
+func purgeExpired(trashRepo *repository.TrashRepository, securityRepo *repository.SecurityRepository, sessionRepo *repository.SessionRepository) {
+	for {
+		count, err := trashRepo.PurgeExpired(config.App.TrashRetention())
+		if err != nil {
+			log.Printf("清理回收站失败: %v", err)
+		} else if count > 0 {
+			log.Printf("已永久删除回收站中 %d 个过期条目", count)
+		}
+		if _, err := securityRepo.PurgeExpired(); err != nil {
+			log.Printf("清理安全事件失败: %v", err)
+		}
+		if _, err := sessionRepo.PurgeExpired(); err != nil {
+			log.Printf("清理过期会话失败: %v", err)
+		}
+		time.Sleep(time.Hour)
+	}
+}
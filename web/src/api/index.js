@@ -22,16 +22,23 @@ api.interceptors.request.use(
   error => Promise.reject(error)
 )
 
-// 响应拦截器
+// 响应拦截器：访问 Token 过期时用刷新 Token 换取新 Token 后重试一次
 api.interceptors.response.use(
   response => response.data,
-  error => {
-    if (error.response?.status === 401) {
+  async error => {
+    const { config, response } = error
+    if (response?.status === 401) {
       const authStore = useAuthStore()
-      authStore.logout()
+      if (!config._retried && authStore.refreshToken && !config.url.startsWith('/auth/login')) {
+        config._retried = true
+        if (await authStore.refresh()) {
+          return api(config)
+        }
+      }
+      authStore.clearSession()
       router.push('/login')
     }
-    return Promise.reject(error.response?.data || error)
+    return Promise.reject(response?.data || error)
   }
 )
 
@@ -39,12 +46,24 @@ export default api
 
 // Auth API
 export const authApi = {
-  login: (password) => api.post('/auth/login', { password }),
+  login: (username, password) => api.post('/auth/login', { username, password }),
+  loginTotp: (challenge, code) => api.post('/auth/login/totp', { challenge, code }),
+  // 不经过拦截器，避免刷新失败时递归
+  refresh: (refreshToken) =>
+    axios.post('/api/auth/refresh', { refresh_token: refreshToken }).then(res => res.data),
+  logout: () => api.post('/auth/logout'),
   getMe: () => api.get('/auth/me'),
   changePassword: (oldPassword, newPassword) =>
     api.put('/auth/password', { old_password: oldPassword, new_password: newPassword })
 }
 
+// Session API
+export const sessionApi = {
+  list: () => api.get('/sessions'),
+  delete: (id) => api.delete(`/sessions/${id}`),
+  deleteAll: () => api.delete('/sessions')
+}
+
 // Bookmark API
 export const bookmarkApi = {
   list: (params) => api.get('/bookmarks', { params }),
@@ -84,19 +103,66 @@ export const tagApi = {
 
 // Domain API
 export const domainApi = {
-  list: () => api.get('/domains'),
-  getBookmarks: (domain) => api.get(`/domains/${encodeURIComponent(domain)}/bookmarks`),
+  list: (params) => api.get('/domains', { params }),
+  getBookmarks: (domain, params) => api.get(`/domains/${encodeURIComponent(domain)}/bookmarks`, { params }),
   delete: (domain) => api.delete(`/domains/${encodeURIComponent(domain)}`)
 }
 
+// Equivalent Domain API
+export const equivalentDomainApi = {
+  list: () => api.get('/equivalent-domains'),
+  create: (domains) => api.post('/equivalent-domains', { domains }),
+  update: (id, domains) => api.put(`/equivalent-domains/${id}`, { domains }),
+  delete: (id) => api.delete(`/equivalent-domains/${id}`),
+  setGlobalExcluded: (key, excluded) => api.put(`/equivalent-domains/global/${key}`, { excluded })
+}
+
 // Credential API
 export const credentialApi = {
   list: () => api.get('/credentials'),
   get: (id) => api.get(`/credentials/${id}`),
   getByDomain: (domain) => api.get(`/credentials/domain/${encodeURIComponent(domain)}`),
+  match: (url) => api.get('/credentials/match', { params: { url } }),
+  // data.type: login（默认）或 note（安全笔记）；data.fields: [{ name, type: text|hidden|url|email, value }]
   create: (data) => api.post('/credentials', data),
   update: (id, data) => api.put(`/credentials/${id}`, data),
-  delete: (id) => api.delete(`/credentials/${id}`)
+  delete: (id) => api.delete(`/credentials/${id}`),
+  totp: (id) => api.get(`/credentials/${id}/totp`),
+  history: (id) => api.get(`/credentials/${id}/history`),
+  getHistory: (id, historyId) => api.get(`/credentials/${id}/history/${historyId}`),
+  // 传 { history_id } 或 { at }（时间点）
+  restore: (id, data) => api.post(`/credentials/${id}/restore`, data),
+  // preview 为 true 时只返回预览结果，不写入；加密备份文件需要 passphrase
+  import: (file, preview = false, passphrase = '') => {
+    const formData = new FormData()
+    formData.append('file', file)
+    if (passphrase) formData.append('passphrase', passphrase)
+    return api.post('/credentials/import', formData, {
+      params: { preview },
+      headers: { 'Content-Type': 'multipart/form-data' }
+    })
+  },
+  health: (maxAgeDays) => api.get('/credentials/health', { params: { max_age_days: maxAgeDays } }),
+  // format: encrypted（需要 passphrase）、bitwarden、csv（需要 masterPassword）
+  export: (format, { passphrase, masterPassword } = {}) =>
+    api.post('/credentials/export', { format, passphrase, master_password: masterPassword }, { responseType: 'blob' })
+}
+
+// Password API（密码生成和强度评估）
+export const passwordApi = {
+  generate: (options) => api.get('/password/generate', { params: options }),
+  strength: (password, username, domain) => api.post('/password/strength', { password, username, domain })
+}
+
+// Vault API（主密码）
+export const vaultApi = {
+  status: () => api.get('/vault'),
+  setup: (masterPassword) => api.post('/vault/setup', { master_password: masterPassword }),
+  unlock: (masterPassword) => api.post('/vault/unlock', { master_password: masterPassword }),
+  lock: () => api.post('/vault/lock'),
+  changePassword: (oldPassword, newPassword) =>
+    api.put('/vault/password', { old_master_password: oldPassword, new_master_password: newPassword }),
+  rotate: (masterPassword) => api.post('/vault/rotate', { master_password: masterPassword })
 }
 
 // Favicon API
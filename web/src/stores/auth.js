@@ -4,16 +4,46 @@ import { authApi } from '@/api'
 
 export const useAuthStore = defineStore('auth', () => {
   const token = ref(localStorage.getItem('token') || '')
+  const refreshToken = ref(localStorage.getItem('refresh_token') || '')
   const user = ref(null)
 
   const isAuthenticated = computed(() => !!token.value)
 
-  async function login(password) {
-    const res = await authApi.login(password)
+  // 开启两步验证时返回 { totp_required, challenge }，需再调用 loginTotp
+  async function login(username, password) {
+    const res = await authApi.login(username, password)
+    if (res.totp_required) return res
+    setSession(res)
+    return res
+  }
+
+  async function loginTotp(challenge, code) {
+    const res = await authApi.loginTotp(challenge, code)
+    setSession(res)
+    return res
+  }
+
+  function setSession(res) {
     token.value = res.token
-    user.value = res.user
+    refreshToken.value = res.refresh_token
+    if (res.user) user.value = res.user
     localStorage.setItem('token', res.token)
-    return res
+    localStorage.setItem('refresh_token', res.refresh_token)
+  }
+
+  // 并发请求同时过期时只刷新一次
+  let refreshing = null
+  function refresh() {
+    if (!refreshing) {
+      refreshing = authApi.refresh(refreshToken.value)
+        .then(res => {
+          setSession(res)
+          return true
+        })
+        .catch(() => false)
+        .finally(() => { refreshing = null })
+    }
+    return refreshing
   }
 
   async function fetchUser() {
@@ -21,29 +51,48 @@ export const useAuthStore = defineStore('auth', () => {
     try {
       user.value = await authApi.getMe()
     } catch {
-      logout()
+      clearSession()
     }
   }
 
-  function logout() {
+  // 退出登录：服务端删除会话（同时清除 bookmarklet 使用的 cookie）
+  async function logout() {
+    if (token.value) {
+      try {
+        await authApi.logout()
+      } catch {
+        // 会话已失效时忽略
+      }
+    }
+    clearSession()
+  }
+
+  function clearSession() {
     token.value = ''
+    refreshToken.value = ''
     user.value = null
     localStorage.removeItem('token')
-    // 清除 cookie（用于 bookmarklet）
-    document.cookie = 'token=; expires=Thu, 01 Jan 1970 00:00:00 UTC; path=/;'
+    localStorage.removeItem('refresh_token')
   }
 
+  // 修改密码后其他会话全部失效，当前设备使用返回的新会话
   async function changePassword(oldPassword, newPassword) {
-    return await authApi.changePassword(oldPassword, newPassword)
+    const res = await authApi.changePassword(oldPassword, newPassword)
+    setSession(res)
+    return res
   }
 
   return {
     token,
+    refreshToken,
     user,
     isAuthenticated,
     login,
+    loginTotp,
     fetchUser,
+    refresh,
     logout,
+    clearSession,
     changePassword
   }
 })